@@ -0,0 +1,401 @@
+package store4
+
+import "sort"
+
+// Components maps each node of a graph to the index of its component.
+// Components are numbered from 0, in order of their first node,
+// with nodes ordered by their N-Quads form.
+//
+// Returned by calls to GraphView's WeaklyConnectedComponents
+// and StronglyConnectedComponents.
+type Components map[interface{}]int
+
+// Groups returns the nodes of each component, indexed by component.
+func (c Components) Groups() [][]interface{} {
+	n := 0
+	for _, i := range c {
+		if i >= n {
+			n = i + 1
+		}
+	}
+	groups := make([][]interface{}, n)
+	for node, i := range c {
+		groups[i] = append(groups[i], node)
+	}
+	for _, g := range groups {
+		sortTerms(g)
+	}
+	return groups
+}
+
+// WriteTo adds a quad to the given graph for each node, with the given
+// predicate and the node's component index as its object.
+// Returns the count of quads that were new.
+//
+// Nodes that are not strings cannot be subjects, so are skipped.
+func (c Components) WriteTo(g *GraphView, predicate string) uint64 {
+	var quads []quad
+	for node, i := range c {
+		if s, ok := node.(string); ok {
+			quads = append(quads, quad{s, predicate, i, g.Graph})
+		}
+	}
+	return g.QuadStore.addQuads(quads)
+}
+
+// Scores maps each node of a graph to a score.
+//
+// Returned by calls to GraphView's PageRank, and Degrees' Centrality.
+type Scores map[interface{}]float64
+
+// WriteTo adds a quad to the given graph for each node, with the given
+// predicate and the node's score as its object.
+// Returns the count of quads that were new.
+//
+// Nodes that are not strings cannot be subjects, so are skipped.
+func (sc Scores) WriteTo(g *GraphView, predicate string) uint64 {
+	var quads []quad
+	for node, score := range sc {
+		if s, ok := node.(string); ok {
+			quads = append(quads, quad{s, predicate, score, g.Graph})
+		}
+	}
+	return g.QuadStore.addQuads(quads)
+}
+
+// Degree holds the count of edges into and out of a node.
+type Degree struct {
+	In  int
+	Out int
+}
+
+// Degrees maps each node of a graph to its degree.
+//
+// Returned by calls to GraphView's Degrees.
+type Degrees map[interface{}]Degree
+
+// Distribution returns the count of nodes having each total degree.
+func (d Degrees) Distribution() map[int]int {
+	out := make(map[int]int)
+	for _, deg := range d {
+		out[deg.In+deg.Out]++
+	}
+	return out
+}
+
+// Centrality returns the degree centrality of each node:
+// its total degree, divided by the count of other nodes.
+func (d Degrees) Centrality() Scores {
+	out := make(Scores, len(d))
+	for node, deg := range d {
+		if len(d) > 1 {
+			out[node] = float64(deg.In+deg.Out) / float64(len(d)-1)
+		} else {
+			out[node] = 0
+		}
+	}
+	return out
+}
+
+// WriteTo adds quads to the given graph for each node, with the given
+// predicates and the node's in-degree and out-degree as their objects.
+// Either predicate may be empty, to skip it.
+// Returns the count of quads that were new.
+//
+// Nodes that are not strings cannot be subjects, so are skipped.
+func (d Degrees) WriteTo(g *GraphView, inPredicate, outPredicate string) uint64 {
+	var quads []quad
+	for node, deg := range d {
+		s, ok := node.(string)
+		if !ok {
+			continue
+		}
+		if inPredicate != "" {
+			quads = append(quads, quad{s, inPredicate, deg.In, g.Graph})
+		}
+		if outPredicate != "" {
+			quads = append(quads, quad{s, outPredicate, deg.Out, g.Graph})
+		}
+	}
+	return g.QuadStore.addQuads(quads)
+}
+
+// WeaklyConnectedComponents returns the weakly connected components
+// of the graph, treating each triple as an undirected edge between
+// its subject and object.
+//
+// If any predicates are given, only triples with those predicates
+// are considered. If the GraphView's Graph is "*" (an asterisk),
+// triples in all graphs are considered.
+func (g *GraphView) WeaklyConnectedComponents(predicates ...string) Components {
+	a := newAnalyticsGraph(g, predicates)
+	// Union-find, with path halving.
+	parent := make([]int, len(a.nodes))
+	for i := range parent {
+		parent[i] = i
+	}
+	find := func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	for i, targets := range a.out {
+		for _, j := range targets {
+			ri, rj := find(i), find(j)
+			if ri != rj {
+				parent[ri] = rj
+			}
+		}
+	}
+	roots := make([]int, len(a.nodes))
+	for i := range roots {
+		roots[i] = find(i)
+	}
+	return a.components(roots)
+}
+
+// StronglyConnectedComponents returns the strongly connected components
+// of the graph, in which every node can reach every other node
+// by following triples from subject to object.
+//
+// If any predicates are given, only triples with those predicates
+// are considered. If the GraphView's Graph is "*" (an asterisk),
+// triples in all graphs are considered.
+func (g *GraphView) StronglyConnectedComponents(predicates ...string) Components {
+	a := newAnalyticsGraph(g, predicates)
+	// Tarjan's algorithm, iteratively.
+	n := len(a.nodes)
+	index := make([]int, n)
+	lowlink := make([]int, n)
+	onStack := make([]bool, n)
+	roots := make([]int, n)
+	for i := range index {
+		index[i] = -1
+	}
+	var stack []int
+	next := 0
+	type frame struct {
+		node, edge int
+	}
+	for start := 0; start < n; start++ {
+		if index[start] >= 0 {
+			continue
+		}
+		calls := []frame{{start, 0}}
+		index[start], lowlink[start] = next, next
+		next++
+		stack = append(stack, start)
+		onStack[start] = true
+		for len(calls) > 0 {
+			f := &calls[len(calls)-1]
+			v := f.node
+			if f.edge < len(a.out[v]) {
+				w := a.out[v][f.edge]
+				f.edge++
+				if index[w] < 0 {
+					index[w], lowlink[w] = next, next
+					next++
+					stack = append(stack, w)
+					onStack[w] = true
+					calls = append(calls, frame{w, 0})
+				} else if onStack[w] && index[w] < lowlink[v] {
+					lowlink[v] = index[w]
+				}
+				continue
+			}
+			// All edges of v have been followed.
+			if lowlink[v] == index[v] {
+				for {
+					w := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[w] = false
+					roots[w] = v
+					if w == v {
+						break
+					}
+				}
+			}
+			calls = calls[:len(calls)-1]
+			if len(calls) > 0 {
+				u := calls[len(calls)-1].node
+				if lowlink[v] < lowlink[u] {
+					lowlink[u] = lowlink[v]
+				}
+			}
+		}
+	}
+	return a.components(roots)
+}
+
+// Degrees returns the in-degree and out-degree of each node of the graph,
+// counting each triple as an edge from its subject to its object.
+//
+// If any predicates are given, only triples with those predicates
+// are considered. If the GraphView's Graph is "*" (an asterisk),
+// triples in all graphs are considered, and triples held in
+// more than one graph are counted once.
+func (g *GraphView) Degrees(predicates ...string) Degrees {
+	a := newAnalyticsGraph(g, predicates)
+	out := make(Degrees, len(a.nodes))
+	for i, id := range a.nodes {
+		out[a.term(id)] = Degree{In: len(a.in[i]), Out: len(a.out[i])}
+	}
+	return out
+}
+
+// PageRank returns the PageRank of each node of the graph, counting each
+// triple as a link from its subject to its object. The scores sum to 1.
+//
+// The damping factor is typically 0.85. Iteration stops when the scores
+// converge, or after the given count of iterations. The rank of nodes
+// without outgoing links is distributed evenly across all nodes.
+//
+// If any predicates are given, only triples with those predicates
+// are considered. If the GraphView's Graph is "*" (an asterisk),
+// triples in all graphs are considered.
+func (g *GraphView) PageRank(damping float64, iterations int, predicates ...string) Scores {
+	a := newAnalyticsGraph(g, predicates)
+	n := len(a.nodes)
+	out := make(Scores, n)
+	if n == 0 {
+		return out
+	}
+	rank := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+	next := make([]float64, n)
+	for iter := 0; iter < iterations; iter++ {
+		dangling := 0.0
+		for i := range rank {
+			if len(a.out[i]) == 0 {
+				dangling += rank[i]
+			}
+		}
+		base := (1-damping)/float64(n) + damping*dangling/float64(n)
+		delta := 0.0
+		for i := range next {
+			sum := 0.0
+			for _, j := range a.in[i] {
+				sum += rank[j] / float64(len(a.out[j]))
+			}
+			next[i] = base + damping*sum
+			if d := next[i] - rank[i]; d < 0 {
+				delta -= d
+			} else {
+				delta += d
+			}
+		}
+		rank, next = next, rank
+		if delta < 1e-12 {
+			break
+		}
+	}
+	for i, id := range a.nodes {
+		out[a.term(id)] = rank[i]
+	}
+	return out
+}
+
+// analyticsGraph is a graph's nodes and edges, as adjacency lists
+// of indexes into its list of node IDs.
+type analyticsGraph struct {
+	pool  *pool
+	nodes []uint64
+	out   [][]int
+	in    [][]int
+}
+
+// newAnalyticsGraph returns the edges of the given graph with the given
+// predicates (or any predicate, if none are given), read from its SPO index.
+func newAnalyticsGraph(g *GraphView, predicates []string) *analyticsGraph {
+	s := g.QuadStore
+	a := &analyticsGraph{pool: s.pool}
+	var pids nodeSet
+	if len(predicates) > 0 {
+		pids = (&pathEval{pool: s.pool}).ids(predicates)
+	}
+	index := make(map[uint64]int)
+	node := func(id uint64) int {
+		i, ok := index[id]
+		if !ok {
+			i = len(a.nodes)
+			index[id] = i
+			a.nodes = append(a.nodes, id)
+			a.out = append(a.out, nil)
+			a.in = append(a.in, nil)
+		}
+		return i
+	}
+	graphOperand(s, g.Graph).some(func(sid, pid, oid uint64) bool {
+		if pids != nil {
+			if _, ok := pids[pid]; !ok {
+				return false
+			}
+		}
+		i, j := node(sid), node(oid)
+		a.out[i] = append(a.out[i], j)
+		a.in[j] = append(a.in[j], i)
+		return false
+	})
+	return a
+}
+
+// term returns the term for the given node ID.
+func (a *analyticsGraph) term(id uint64) interface{} {
+	return a.pool.idToAny(id)
+}
+
+// components returns the components given by the root node of each node,
+// numbered in order of their first node.
+func (a *analyticsGraph) components(roots []int) Components {
+	terms := make([]interface{}, len(a.nodes))
+	keys := make([]string, len(a.nodes))
+	for i, id := range a.nodes {
+		terms[i] = a.term(id)
+		keys[i] = formatNQuadsTerm(terms[i])
+	}
+	order := make([]int, len(a.nodes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return keys[order[i]] < keys[order[j]]
+	})
+	numbers := make(map[int]int)
+	out := make(Components, len(a.nodes))
+	for _, i := range order {
+		c, ok := numbers[roots[i]]
+		if !ok {
+			c = len(numbers)
+			numbers[roots[i]] = c
+		}
+		out[terms[i]] = c
+	}
+	return out
+}
+
+// sortTerms sorts the given terms by their N-Quads form.
+func sortTerms(terms []interface{}) {
+	// Format each term once, rather than once per comparison.
+	keys := make([]string, len(terms))
+	for i, t := range terms {
+		keys[i] = formatNQuadsTerm(t)
+	}
+	sort.Sort(termsByKey{terms, keys})
+}
+
+// termsByKey implements sort.Interface for terms with precomputed sort keys.
+type termsByKey struct {
+	terms []interface{}
+	keys  []string
+}
+
+func (x termsByKey) Len() int           { return len(x.terms) }
+func (x termsByKey) Less(i, j int) bool { return x.keys[i] < x.keys[j] }
+func (x termsByKey) Swap(i, j int) {
+	x.terms[i], x.terms[j] = x.terms[j], x.terms[i]
+	x.keys[i], x.keys[j] = x.keys[j], x.keys[i]
+}
@@ -0,0 +1,158 @@
+package store4_test
+
+import (
+	"strconv"
+
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Analytics", func() {
+
+	var g *GraphView
+
+	BeforeEach(func() {
+		// A cycle a -> b -> c -> a, with c -> d,
+		// and a separate pair e -> f (via "other").
+		g = NewGraph([][3]string{
+			{"a", "knows", "b"},
+			{"b", "knows", "c"},
+			{"c", "knows", "a"},
+			{"c", "knows", "d"},
+			{"e", "other", "f"},
+		})
+	})
+
+	Describe("WeaklyConnectedComponents", func() {
+
+		It("should group connected nodes", func() {
+			c := g.WeaklyConnectedComponents()
+			Expect(c).To(Equal(Components{
+				"a": 0, "b": 0, "c": 0, "d": 0,
+				"e": 1, "f": 1,
+			}))
+			Expect(c.Groups()).To(Equal([][]interface{}{
+				{"a", "b", "c", "d"},
+				{"e", "f"},
+			}))
+		})
+
+		It("should only follow the given predicates", func() {
+			c := g.WeaklyConnectedComponents("knows")
+			Expect(c).To(HaveLen(4))
+			Expect(c).NotTo(HaveKey("e"))
+		})
+
+		It("should include non-string objects", func() {
+			g.Add("d", "age", 42)
+			c := g.WeaklyConnectedComponents()
+			Expect(c).To(HaveKeyWithValue(42, c["d"]))
+		})
+
+		It("should span graphs when given a wildcard", func() {
+			s := g.QuadStore
+			s.Add("d", "knows", "e", "g2")
+			Expect(s.GraphView("").WeaklyConnectedComponents().Groups()).To(HaveLen(2))
+			Expect(s.GraphView("*").WeaklyConnectedComponents().Groups()).To(HaveLen(1))
+		})
+
+		It("should return an empty map for an empty graph", func() {
+			Expect(NewGraph().WeaklyConnectedComponents()).To(BeEmpty())
+		})
+	})
+
+	Describe("StronglyConnectedComponents", func() {
+
+		It("should group mutually reachable nodes", func() {
+			c := g.StronglyConnectedComponents()
+			Expect(c.Groups()).To(Equal([][]interface{}{
+				{"a", "b", "c"},
+				{"d"},
+				{"e"},
+				{"f"},
+			}))
+		})
+
+		It("should handle long chains without recursion", func() {
+			h := NewGraph()
+			for i := 0; i < 100000; i++ {
+				h.Add("n"+strconv.Itoa(i), "next", "n"+strconv.Itoa(i+1))
+			}
+			h.Add("n100000", "next", "n0")
+			Expect(h.StronglyConnectedComponents().Groups()).To(HaveLen(1))
+		})
+	})
+
+	Describe("Degrees", func() {
+
+		It("should count edges into and out of each node", func() {
+			d := g.Degrees()
+			Expect(d["a"]).To(Equal(Degree{In: 1, Out: 1}))
+			Expect(d["c"]).To(Equal(Degree{In: 1, Out: 2}))
+			Expect(d["d"]).To(Equal(Degree{In: 1, Out: 0}))
+			Expect(d.Distribution()).To(Equal(map[int]int{1: 3, 2: 2, 3: 1}))
+		})
+
+		It("should compute degree centrality", func() {
+			sc := g.Degrees("knows").Centrality()
+			Expect(sc["c"]).To(BeNumerically("~", 1.0))
+			Expect(sc["d"]).To(BeNumerically("~", 1.0/3))
+		})
+
+		It("should count triples held in several graphs once", func() {
+			s := g.QuadStore
+			s.Add("a", "knows", "b", "g2")
+			Expect(s.GraphView("*").Degrees()["a"]).To(Equal(Degree{In: 1, Out: 1}))
+		})
+
+		It("should write degrees back as quads", func() {
+			dst := NewGraph()
+			n := g.Degrees().WriteTo(dst, "in", "")
+			Expect(n).To(BeEquivalentTo(6))
+			Expect(dst.Count("c", "in", 1)).To(BeEquivalentTo(1))
+		})
+	})
+
+	Describe("PageRank", func() {
+
+		It("should rank nodes by their links", func() {
+			sc := g.PageRank(0.85, 100, "knows")
+			sum := 0.0
+			for _, v := range sc {
+				sum += v
+			}
+			Expect(sum).To(BeNumerically("~", 1.0, 1e-9))
+			Expect(sc["b"]).To(BeNumerically(">", sc["a"]))
+			Expect(sc["c"]).To(BeNumerically(">", sc["d"]))
+		})
+
+		It("should rank a symmetric cycle evenly", func() {
+			sc := NewGraph([][3]string{
+				{"x", "p", "y"},
+				{"y", "p", "z"},
+				{"z", "p", "x"},
+			}).PageRank(0.85, 100)
+			for _, v := range sc {
+				Expect(v).To(BeNumerically("~", 1.0/3, 1e-9))
+			}
+		})
+
+		It("should write scores back as quads", func() {
+			s := NewQuadStore()
+			n := g.PageRank(0.85, 10).WriteTo(s.GraphView("stats"), "rank")
+			Expect(n).To(BeEquivalentTo(6))
+			Expect(s.Count("a", "rank", "*", "stats")).To(BeEquivalentTo(1))
+		})
+	})
+
+	Describe("Components.WriteTo", func() {
+
+		It("should write component indexes back as quads", func() {
+			n := g.WeaklyConnectedComponents().WriteTo(g, "component")
+			Expect(n).To(BeEquivalentTo(6))
+			Expect(g.Count("f", "component", 1)).To(BeEquivalentTo(1))
+		})
+	})
+})
@@ -0,0 +1,103 @@
+package store4
+
+// AddAll adds quads to the store in bulk. Returns the count of quads
+// that were new, and the count of quads that already existed.
+//
+// AddAll is faster than adding the quads one at a time: the store's
+// term pool, and the first graph added to, are presized for the given
+// quads, and term records are allocated in blocks.
+//
+// Quads can be provided using any of the types accepted by NewQuadStore,
+// and AddAll will panic if the type of any of the given args cannot be handled.
+//
+// If provided, the OnBatch callback is called once, after all of the
+// quads have been added, with the list of changes made. The per-quad
+// OnAdd callback and any subscriptions are still called for each new quad,
+// so they can be left unset when only batch notifications are wanted.
+//
+// As with Add, AddAll will panic if any quad contains a wildcard term,
+// or is rejected by the BeforeAdd callback. In which case, any quads
+// already added by the call remain in the store, and are
+// reported to OnBatch.
+func (s *QuadStore) AddAll(args ...interface{}) (added, duplicates uint64) {
+	n := 0
+	for _, arg := range args {
+		n += countQuadArg(arg)
+	}
+	defer s.beginBatch(n)()
+	addFn := func(subject, predicate string, object interface{}, graph string) {
+		if s.Add(subject, predicate, object, graph) {
+			added++
+		} else {
+			duplicates++
+		}
+	}
+	for _, arg := range args {
+		forEachQuadArg(arg, addFn)
+	}
+	return added, duplicates
+}
+
+// RemoveAll removes quads from the store in bulk. Returns the count of
+// quads removed, and the count of given quads that matched nothing in the store.
+//
+// Quads can be provided using any of the types accepted by NewQuadStore,
+// and RemoveAll will panic if the type of any of the given args cannot be handled.
+// Passing "*" (an asterisk) for any term acts as a
+// match-everything wildcard for that term, as with Remove.
+//
+// If provided, the OnBatch callback is called once, after all of the
+// quads have been removed, with the list of changes made. The per-quad
+// OnRemove callback and any subscriptions are still called for each removed quad.
+//
+// As with Remove, RemoveAll will panic if any quad is rejected by
+// the BeforeRemove callback. In which case, any quads already removed
+// by the call remain removed, and are reported to OnBatch.
+func (s *QuadStore) RemoveAll(args ...interface{}) (removed, missing uint64) {
+	defer s.beginBatch(0)()
+	removeFn := func(subject, predicate string, object interface{}, graph string) {
+		n := s.Remove(subject, predicate, object, graph)
+		if n == 0 {
+			missing++
+		}
+		removed += n
+	}
+	for _, arg := range args {
+		forEachQuadArg(arg, removeFn)
+	}
+	return removed, missing
+}
+
+// beginBatch starts a bulk operation, presizing the store's term pool,
+// and the first graph added to, for n quads. If there is an OnBatch
+// callback, the changes made are collected for it. It returns a function
+// that ends the batch, calling OnBatch with the collected changes.
+//
+// Nested batches are folded into the outermost batch.
+func (s *QuadStore) beginBatch(n int) func() {
+	s.batchDepth++
+	if s.batchDepth > 1 {
+		return func() {
+			s.batchDepth--
+		}
+	}
+	// Each quad holds at most three new terms, besides its graph.
+	s.pool.reserve(3 * n)
+	s.sizeHint = n
+	// The whole batch is undone as a single step.
+	s.BeginGroup()
+	var changes []Change
+	if s.OnBatch != nil {
+		s.batch = &changes
+	}
+	return func() {
+		s.EndGroup()
+		s.batchDepth--
+		s.batch = nil
+		s.sizeHint = 0
+		s.pool.release()
+		if s.OnBatch != nil && len(changes) > 0 {
+			s.OnBatch(changes)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package store4_test
+
+import (
+	"strconv"
+
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Batches", func() {
+
+	Describe("AddAll", func() {
+
+		It("should add quads from all supported types", func() {
+			store := NewQuadStore()
+			added, duplicates := store.AddAll(
+				[][4]string{
+					{"s1", "p1", "o1", ""},
+					{"s1", "p1", "o2", "g1"},
+				},
+				[3]string{"s1", "p1", "o1"},
+				[]*Quad{
+					{"s2", "p2", 42, ""},
+				},
+			)
+			Expect(added).To(Equal(uint64(3)))
+			Expect(duplicates).To(Equal(uint64(1)))
+			Expect(iterResults(store)).To(ConsistOf([]*Quad{
+				{"s1", "p1", "o1", ""},
+				{"s1", "p1", "o2", "g1"},
+				{"s2", "p2", 42, ""},
+			}))
+		})
+
+		It("should add quads to a store that already has contents", func() {
+			store := NewQuadStore([4]string{"s1", "p1", "o1", ""})
+			added, duplicates := store.AddAll([][3]string{
+				{"s1", "p1", "o1"},
+				{"s2", "p2", "o2"},
+			})
+			Expect(added).To(Equal(uint64(1)))
+			Expect(duplicates).To(Equal(uint64(1)))
+			Expect(store.Size()).To(Equal(uint64(2)))
+		})
+
+		It("should call OnBatch once with all new quads", func() {
+			store := NewQuadStore([4]string{"s1", "p1", "o1", ""})
+			var batches [][]Change
+			store.OnBatch = func(changes []Change) {
+				batches = append(batches, changes)
+			}
+			store.AddAll([][4]string{
+				{"s1", "p1", "o1", ""},
+				{"s2", "p2", "o2", ""},
+				{"s3", "p3", "o3", "g1"},
+			})
+			Expect(batches).To(Equal([][]Change{{
+				{Added, "s2", "p2", "o2", ""},
+				{Added, "s3", "p3", "o3", "g1"},
+			}}))
+		})
+
+		It("should not call OnBatch when nothing changed", func() {
+			store := NewQuadStore([4]string{"s1", "p1", "o1", ""})
+			called := false
+			store.OnBatch = func(changes []Change) {
+				called = true
+			}
+			store.AddAll([4]string{"s1", "p1", "o1", ""})
+			Expect(called).To(BeFalse())
+		})
+
+		It("should still call per-quad hooks", func() {
+			store := NewQuadStore()
+			count := 0
+			store.OnAdd = func(s, p string, o interface{}, g string) {
+				count++
+			}
+			store.Subscribe("*", "*", "*", "*", func(c Change) {
+				count++
+			})
+			store.AddAll([][3]string{
+				{"s1", "p1", "o1"},
+				{"s2", "p2", "o2"},
+			})
+			Expect(count).To(Equal(4))
+		})
+
+		It("should fold changes made by hooks into the same batch", func() {
+			store := NewQuadStore()
+			store.OnAdd = func(s, p string, o interface{}, g string) {
+				if p == "knows" {
+					store.Add(o.(string), "knownBy", s, g)
+				}
+			}
+			var batches [][]Change
+			store.OnBatch = func(changes []Change) {
+				batches = append(batches, changes)
+			}
+			store.AddAll([3]string{"Alice", "knows", "Bob"})
+			Expect(batches).To(HaveLen(1))
+			Expect(batches[0]).To(HaveLen(2))
+		})
+
+		It("should fold nested bulk calls into the same batch", func() {
+			store := NewQuadStore()
+			store.OnAdd = func(s, p string, o interface{}, g string) {
+				if p == "knows" {
+					store.AddAll([3]string{o.(string), "knownBy", s})
+				}
+			}
+			var batches [][]Change
+			store.OnBatch = func(changes []Change) {
+				batches = append(batches, changes)
+			}
+			store.AddAll([][3]string{
+				{"Alice", "knows", "Bob"},
+				{"Bob", "knows", "Carol"},
+			})
+			Expect(batches).To(HaveLen(1))
+			Expect(batches[0]).To(HaveLen(4))
+		})
+
+		It("should add many quads to existing graphs", func() {
+			store := NewQuadStore([][4]string{
+				{"s0", "p", "o0", "g1"},
+				{"s0", "p", "o0", ""},
+			})
+			quads := make([][4]string, 1000)
+			for i := range quads {
+				quads[i] = [4]string{"s" + strconv.Itoa(i), "p", "o" + strconv.Itoa(i%10), "g1"}
+			}
+			added, duplicates := store.AddAll(quads)
+			Expect(added).To(Equal(uint64(999)))
+			Expect(duplicates).To(Equal(uint64(1)))
+			Expect(store.Count("*", "*", "*", "g1")).To(Equal(uint64(1000)))
+			Expect(store.FindSubjects("p", "o3", "g1")).To(HaveLen(100))
+			Expect(store.RemoveAll(quads)).To(Equal(uint64(1000)))
+			Expect(store.Size()).To(Equal(uint64(1)))
+			Expect(store.FindSubjects("p", "o0", "")).To(ConsistOf("s0"))
+		})
+
+		It("should panic when a quad is rejected, reporting the changes made", func() {
+			store := NewQuadStore()
+			store.BeforeAdd = func(s, p string, o interface{}, g string) error {
+				if s == "bad" {
+					return ErrWildcardTerm
+				}
+				return nil
+			}
+			var batch []Change
+			store.OnBatch = func(changes []Change) {
+				batch = changes
+			}
+			Expect(func() {
+				store.AddAll([][3]string{
+					{"s1", "p1", "o1"},
+					{"bad", "p1", "o1"},
+					{"s2", "p1", "o1"},
+				})
+			}).To(Panic())
+			Expect(store.Size()).To(Equal(uint64(1)))
+			Expect(batch).To(HaveLen(1))
+			// The store is usable afterwards.
+			store.AddAll([3]string{"s3", "p1", "o1"})
+			Expect(batch).To(HaveLen(1))
+			Expect(batch[0].Subject).To(Equal("s3"))
+		})
+
+		It("should panic for unknown types", func() {
+			store := NewQuadStore()
+			Expect(func() { store.AddAll(true) }).To(Panic())
+		})
+	})
+
+	Describe("RemoveAll", func() {
+
+		newStore := func() *QuadStore {
+			return NewQuadStore([][4]string{
+				{"s1", "p1", "o1", ""},
+				{"s1", "p1", "o2", ""},
+				{"s1", "p2", "o2", ""},
+				{"s2", "p1", "o1", "g1"},
+			})
+		}
+
+		It("should remove the given quads", func() {
+			store := newStore()
+			removed, missing := store.RemoveAll([][4]string{
+				{"s1", "p1", "o1", ""},
+				{"s2", "p1", "o1", "g1"},
+				{"s9", "p1", "o1", ""},
+			})
+			Expect(removed).To(Equal(uint64(2)))
+			Expect(missing).To(Equal(uint64(1)))
+			Expect(store.Size()).To(Equal(uint64(2)))
+		})
+
+		It("should accept wildcards", func() {
+			store := newStore()
+			removed, missing := store.RemoveAll([4]string{"s1", "*", "*", "*"})
+			Expect(removed).To(Equal(uint64(3)))
+			Expect(missing).To(BeZero())
+		})
+
+		It("should call OnBatch once with all removed quads", func() {
+			store := newStore()
+			var batches [][]Change
+			store.OnBatch = func(changes []Change) {
+				batches = append(batches, changes)
+			}
+			store.RemoveAll([][4]string{
+				{"s1", "p1", "o1", ""},
+				{"s2", "p1", "o1", "g1"},
+			})
+			Expect(batches).To(Equal([][]Change{{
+				{Removed, "s1", "p1", "o1", ""},
+				{Removed, "s2", "p1", "o1", "g1"},
+			}}))
+		})
+	})
+})
@@ -78,6 +78,38 @@ func BenchmarkAddRemoveRandomsTo100kRandoms(b *testing.B) { benchmarkAddRemoveRa
 func BenchmarkAddRemoveRandomsTo500kRandoms(b *testing.B) { benchmarkAddRemoveRandomsTo(500000, b) }
 func BenchmarkAddRemoveRandomsTo1mRandoms(b *testing.B)   { benchmarkAddRemoveRandomsTo(1000000, b) }
 
+func benchmarkAddAllRandoms(count int, b *testing.B) {
+	quads := make([][4]string, count)
+	for i := range quads {
+		quads[i] = randomQuad("")
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		s := store4.NewQuadStore()
+		s.AddAll(quads)
+	}
+}
+
+func BenchmarkAddAll10kRandoms(b *testing.B)  { benchmarkAddAllRandoms(10000, b) }
+func BenchmarkAddAll100kRandoms(b *testing.B) { benchmarkAddAllRandoms(100000, b) }
+
+func benchmarkAddRandoms(count int, b *testing.B) {
+	quads := make([][4]string, count)
+	for i := range quads {
+		quads[i] = randomQuad("")
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		s := store4.NewQuadStore()
+		for _, q := range quads {
+			s.Add(q[0], q[1], q[2], q[3])
+		}
+	}
+}
+
+func BenchmarkAdd10kRandoms(b *testing.B)  { benchmarkAddRandoms(10000, b) }
+func BenchmarkAdd100kRandoms(b *testing.B) { benchmarkAddRandoms(100000, b) }
+
 // // func BenchmarkAddRemoveRandomsTo10mRandoms(b *testing.B)  { benchmarkAddRemoveRandomsTo(10000000, b) }
 // // func BenchmarkAddRemoveRandomsTo50mRandoms(b *testing.B)  { benchmarkAddRemoveRandomsTo(50000000, b) }
 // // func BenchmarkAddRemoveRandomsTo100mRandoms(b *testing.B) { benchmarkAddRemoveRandomsTo(100000000, b) }
@@ -0,0 +1,467 @@
+package store4
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrCanonicalizationLimit is returned when canonicalizing a dataset
+// would take an excessive amount of work. Such datasets are typically
+// crafted to exhaust resources, and can not be canonicalized safely.
+var ErrCanonicalizationLimit = errors.New("canonicalization work limit exceeded")
+
+// maxCanonicalizationWork limits the number of blank node path
+// permutations explored while canonicalizing a single dataset.
+const maxCanonicalizationWork = 1 << 20
+
+// CanonicalLabels canonicalizes the store using the RDF Dataset
+// Canonicalization algorithm (RDFC-1.0), and returns a map from each
+// blank node in the store to its canonical label ("_:c14n0", "_:c14n1",
+// and so on). Blank nodes are strings beginning with "_:".
+//
+// Returns ErrCanonicalizationLimit if the store's blank nodes are
+// too complex to canonicalize.
+func (s *QuadStore) CanonicalLabels() (map[string]string, error) {
+	return canonicalLabels(s.quads())
+}
+
+// CanonicalNQuads returns the canonical N-Quads serialization of the store,
+// using RDFC-1.0: blank nodes are relabelled with their canonical labels,
+// and lines are sorted. Quads in the default graph ("") are written without
+// a graph name.
+//
+// Plain string objects are written as IRIs (or blank nodes). String
+// literals should be held as Literals. See Literal for the datatypes
+// of other object values.
+//
+// Returns ErrCanonicalizationLimit if the store's blank nodes are
+// too complex to canonicalize.
+func (s *QuadStore) CanonicalNQuads() (string, error) {
+	return canonicalNQuads(s.quads())
+}
+
+// CanonicalHash returns the hex-encoded SHA-256 hash of the store's
+// canonical N-Quads. Isomorphic stores have the same hash.
+func (s *QuadStore) CanonicalHash() (string, error) {
+	return canonicalHash(s.quads())
+}
+
+// CanonicalLabels canonicalizes the graph's triples using RDFC-1.0,
+// and returns a map from each blank node in the graph to its canonical label.
+// The graph's name does not affect the result.
+func (g *GraphView) CanonicalLabels() (map[string]string, error) {
+	return canonicalLabels(g.quads())
+}
+
+// CanonicalNQuads returns the canonical serialization of the graph's
+// triples, using RDFC-1.0. The triples are written without a graph name,
+// so the result is valid N-Triples.
+func (g *GraphView) CanonicalNQuads() (string, error) {
+	return canonicalNQuads(g.quads())
+}
+
+// CanonicalHash returns the hex-encoded SHA-256 hash of the graph's
+// canonical N-Quads. Isomorphic graphs have the same hash,
+// whatever their names.
+func (g *GraphView) CanonicalHash() (string, error) {
+	return canonicalHash(g.quads())
+}
+
+// Isomorphic returns true if the given datasets are the same,
+// other than for the labelling of their blank nodes. The arguments
+// must both be either *QuadStore or *GraphView, otherwise
+// Isomorphic will panic.
+func Isomorphic(a, b interface{}) (bool, error) {
+	qa, qb := quadsOf(a), quadsOf(b)
+	if len(qa) != len(qb) {
+		return false, nil
+	}
+	ca, err := canonicalNQuads(qa)
+	if err != nil {
+		return false, err
+	}
+	cb, err := canonicalNQuads(qb)
+	if err != nil {
+		return false, err
+	}
+	return ca == cb, nil
+}
+
+// quadsOf returns all quads of the given *QuadStore or *GraphView.
+func quadsOf(x interface{}) []quad {
+	switch v := x.(type) {
+	case *QuadStore:
+		return v.quads()
+	case *GraphView:
+		return v.quads()
+	}
+	panic(fmt.Sprintf("Unexpected type %T, expected *QuadStore or *GraphView", x))
+}
+
+// quads returns all quads in the store.
+func (s *QuadStore) quads() []quad {
+	out := make([]quad, 0, s.Size())
+	s.ForEach(func(s, p string, o interface{}, g string) {
+		out = append(out, quad{s, p, o, g})
+	})
+	return out
+}
+
+// quads returns all triples in the graph, as quads in the default graph.
+func (g *GraphView) quads() []quad {
+	var out []quad
+	g.ForEach(func(s, p string, o interface{}) {
+		out = append(out, quad{s, p, o, ""})
+	})
+	return out
+}
+
+func canonicalLabels(quads []quad) (map[string]string, error) {
+	c := newCanonicalizer(quads)
+	if err := c.run(); err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(c.canonical.issued))
+	for b, id := range c.canonical.issued {
+		out["_:"+b] = "_:" + id
+	}
+	return out, nil
+}
+
+func canonicalNQuads(quads []quad) (string, error) {
+	c := newCanonicalizer(quads)
+	if err := c.run(); err != nil {
+		return "", err
+	}
+	relabel := func(t interface{}) interface{} {
+		if b, ok := blankNodeLabel(t); ok {
+			return "_:" + c.canonical.issued[b]
+		}
+		return t
+	}
+	lines := make([]string, len(quads))
+	for i, q := range quads {
+		lines[i] = formatNQuad(relabel(q.s), q.p, relabel(q.o), relabel(q.g))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, ""), nil
+}
+
+func canonicalHash(quads []quad) (string, error) {
+	nq, err := canonicalNQuads(quads)
+	if err != nil {
+		return "", err
+	}
+	return hashString(nq), nil
+}
+
+// formatNQuad returns a single N-Quads line, including its terminating newline.
+func formatNQuad(s, p, o, g interface{}) string {
+	var b strings.Builder
+	b.WriteString(formatNQuadsTerm(s))
+	b.WriteByte(' ')
+	b.WriteString(formatNQuadsTerm(p))
+	b.WriteByte(' ')
+	b.WriteString(formatNQuadsTerm(o))
+	if g != "" {
+		b.WriteByte(' ')
+		b.WriteString(formatNQuadsTerm(g))
+	}
+	b.WriteString(" .\n")
+	return b.String()
+}
+
+// blankNodeLabel returns the label of the given term (without its "_:"
+// prefix), if it is a blank node.
+func blankNodeLabel(t interface{}) (string, bool) {
+	if !isBlankNode(t) {
+		return "", false
+	}
+	return t.(string)[2:], true
+}
+
+// hashString returns the hex-encoded SHA-256 hash of the given string.
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// identifierIssuer issues identifiers for blank nodes, as described by RDFC-1.0.
+type identifierIssuer struct {
+	prefix  string
+	counter int
+	// issued maps blank node labels to their issued identifiers.
+	issued map[string]string
+	// order holds blank node labels in the order that identifiers were issued.
+	order []string
+}
+
+func newIdentifierIssuer(prefix string) *identifierIssuer {
+	return &identifierIssuer{
+		prefix: prefix,
+		issued: make(map[string]string),
+	}
+}
+
+// issue returns the identifier for the given blank node,
+// issuing a new identifier if necessary.
+func (x *identifierIssuer) issue(b string) string {
+	if id, ok := x.issued[b]; ok {
+		return id
+	}
+	id := fmt.Sprintf("%s%d", x.prefix, x.counter)
+	x.counter++
+	x.issued[b] = id
+	x.order = append(x.order, b)
+	return id
+}
+
+func (x *identifierIssuer) copy() *identifierIssuer {
+	c := &identifierIssuer{
+		prefix:  x.prefix,
+		counter: x.counter,
+		issued:  make(map[string]string, len(x.issued)),
+		order:   append([]string(nil), x.order...),
+	}
+	for k, v := range x.issued {
+		c.issued[k] = v
+	}
+	return c
+}
+
+// canonicalizer holds the canonicalization state of RDFC-1.0.
+type canonicalizer struct {
+	quads []quad
+	// blankQuads maps blank node labels to the quads that mention them.
+	blankQuads map[string][]*quad
+	canonical  *identifierIssuer
+	// firstDegree caches first degree hashes.
+	firstDegree map[string]string
+	work        int
+}
+
+func newCanonicalizer(quads []quad) *canonicalizer {
+	c := &canonicalizer{
+		quads:       quads,
+		blankQuads:  make(map[string][]*quad),
+		canonical:   newIdentifierIssuer("c14n"),
+		firstDegree: make(map[string]string),
+	}
+	for i := range quads {
+		q := &quads[i]
+		seen := make(map[string]bool, 3)
+		for _, t := range []interface{}{q.s, q.o, q.g} {
+			if b, ok := blankNodeLabel(t); ok && !seen[b] {
+				seen[b] = true
+				c.blankQuads[b] = append(c.blankQuads[b], q)
+			}
+		}
+	}
+	return c
+}
+
+// run issues canonical identifiers for all blank nodes.
+func (c *canonicalizer) run() error {
+	// Group blank nodes by their first degree hash.
+	byHash := make(map[string][]string)
+	for b := range c.blankQuads {
+		h := c.hashFirstDegree(b)
+		byHash[h] = append(byHash[h], b)
+	}
+	hashes := make([]string, 0, len(byHash))
+	for h := range byHash {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+	// Blank nodes with unique hashes are labelled first.
+	var shared []string
+	for _, h := range hashes {
+		if len(byHash[h]) > 1 {
+			shared = append(shared, h)
+			continue
+		}
+		c.canonical.issue(byHash[h][0])
+	}
+	// Then those that share hashes, distinguished by their neighbourhoods.
+	for _, h := range shared {
+		type result struct {
+			hash   string
+			issuer *identifierIssuer
+		}
+		var results []result
+		nodes := byHash[h]
+		sort.Strings(nodes)
+		for _, b := range nodes {
+			if _, ok := c.canonical.issued[b]; ok {
+				continue
+			}
+			issuer := newIdentifierIssuer("b")
+			issuer.issue(b)
+			hash, issuer, err := c.hashNDegree(b, issuer)
+			if err != nil {
+				return err
+			}
+			results = append(results, result{hash, issuer})
+		}
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].hash < results[j].hash
+		})
+		for _, r := range results {
+			for _, b := range r.issuer.order {
+				c.canonical.issue(b)
+			}
+		}
+	}
+	return nil
+}
+
+// hashFirstDegree returns the first degree hash of the given blank node.
+func (c *canonicalizer) hashFirstDegree(b string) string {
+	if h, ok := c.firstDegree[b]; ok {
+		return h
+	}
+	relabel := func(t interface{}) interface{} {
+		if x, ok := blankNodeLabel(t); ok {
+			if x == b {
+				return "_:a"
+			}
+			return "_:z"
+		}
+		return t
+	}
+	qs := c.blankQuads[b]
+	lines := make([]string, len(qs))
+	for i, q := range qs {
+		lines[i] = formatNQuad(relabel(q.s), q.p, relabel(q.o), relabel(q.g))
+	}
+	sort.Strings(lines)
+	h := hashString(strings.Join(lines, ""))
+	c.firstDegree[b] = h
+	return h
+}
+
+// hashRelated returns the hash of a blank node related to another
+// by the given quad, at the given position ("s", "o" or "g").
+func (c *canonicalizer) hashRelated(related string, q *quad, issuer *identifierIssuer, position string) string {
+	var id string
+	if x, ok := c.canonical.issued[related]; ok {
+		id = "_:" + x
+	} else if x, ok := issuer.issued[related]; ok {
+		id = "_:" + x
+	} else {
+		id = c.hashFirstDegree(related)
+	}
+	input := position
+	if position != "g" {
+		input += "<" + q.p + ">"
+	}
+	return hashString(input + id)
+}
+
+// hashNDegree returns the N-degree hash of the given blank node,
+// and the issuer holding the identifiers issued along the chosen path.
+func (c *canonicalizer) hashNDegree(b string, issuer *identifierIssuer) (string, *identifierIssuer, error) {
+	// Group related blank nodes by their hashes.
+	related := make(map[string][]string)
+	for _, q := range c.blankQuads[b] {
+		for _, x := range []struct {
+			term     interface{}
+			position string
+		}{{q.s, "s"}, {q.o, "o"}, {q.g, "g"}} {
+			if r, ok := blankNodeLabel(x.term); ok && r != b {
+				h := c.hashRelated(r, q, issuer, x.position)
+				related[h] = append(related[h], r)
+			}
+		}
+	}
+	hashes := make([]string, 0, len(related))
+	for h := range related {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+
+	var data strings.Builder
+	for _, h := range hashes {
+		data.WriteString(h)
+		chosenPath := ""
+		var chosenIssuer *identifierIssuer
+		var err error
+		permute(related[h], func(perm []string) bool {
+			c.work++
+			if c.work > maxCanonicalizationWork {
+				err = ErrCanonicalizationLimit
+				return false
+			}
+			issuerCopy := issuer.copy()
+			path := ""
+			var recursion []string
+			longer := func() bool {
+				return chosenPath != "" && len(path) >= len(chosenPath) && path > chosenPath
+			}
+			for _, r := range perm {
+				if id, ok := c.canonical.issued[r]; ok {
+					path += "_:" + id
+				} else {
+					if _, ok := issuerCopy.issued[r]; !ok {
+						recursion = append(recursion, r)
+					}
+					path += "_:" + issuerCopy.issue(r)
+				}
+				if longer() {
+					return true
+				}
+			}
+			for _, r := range recursion {
+				var hash string
+				var resultIssuer *identifierIssuer
+				hash, resultIssuer, err = c.hashNDegree(r, issuerCopy)
+				if err != nil {
+					return false
+				}
+				path += "_:" + issuerCopy.issue(r)
+				path += "<" + hash + ">"
+				issuerCopy = resultIssuer
+				if longer() {
+					return true
+				}
+			}
+			if chosenPath == "" || path < chosenPath {
+				chosenPath = path
+				chosenIssuer = issuerCopy
+			}
+			return true
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		data.WriteString(chosenPath)
+		issuer = chosenIssuer
+	}
+	return hashString(data.String()), issuer, nil
+}
+
+// permute calls fn with each permutation of the given items,
+// until fn returns false.
+func permute(items []string, fn func(perm []string) bool) {
+	perm := append([]string(nil), items...)
+	sort.Strings(perm)
+	var generate func(k int) bool
+	generate = func(k int) bool {
+		if k == len(perm) {
+			return fn(perm)
+		}
+		for i := k; i < len(perm); i++ {
+			perm[k], perm[i] = perm[i], perm[k]
+			if !generate(k + 1) {
+				return false
+			}
+			perm[k], perm[i] = perm[i], perm[k]
+		}
+		return true
+	}
+	generate(0)
+}
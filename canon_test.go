@@ -0,0 +1,176 @@
+package store4_test
+
+import (
+	"fmt"
+	"math/rand"
+
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Canonicalization", func() {
+
+	// relabel returns a copy of the given quads with blank nodes
+	// relabelled randomly, in a random order.
+	relabel := func(quads []*Quad, seed int64) *QuadStore {
+		r := rand.New(rand.NewSource(seed))
+		labels := make(map[string]string)
+		term := func(t string) string {
+			if len(t) < 2 || t[:2] != "_:" {
+				return t
+			}
+			if l, ok := labels[t]; ok {
+				return l
+			}
+			l := fmt.Sprintf("_:n%d", r.Intn(1000000))
+			labels[t] = l
+			return l
+		}
+		store := NewQuadStore()
+		for _, i := range r.Perm(len(quads)) {
+			q := quads[i]
+			o := q.O
+			if s, ok := o.(string); ok {
+				o = term(s)
+			}
+			store.Add(term(q.S), q.P, o, term(q.G))
+		}
+		return store
+	}
+
+	It("should label a single blank node", func() {
+		store := NewQuadStore([][3]string{
+			{"_:x", "http://example.org/p", "http://example.org/o"},
+		})
+		nq, err := store.CanonicalNQuads()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nq).To(Equal("_:c14n0 <http://example.org/p> <http://example.org/o> .\n"))
+		labels, err := store.CanonicalLabels()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(labels).To(Equal(map[string]string{"_:x": "_:c14n0"}))
+	})
+
+	It("should match the RDFC-1.0 specification's example", func() {
+		const ex = "http://example.com/#"
+		store := NewQuadStore([][3]string{
+			{ex + "p", ex + "q", "_:e0"},
+			{ex + "p", ex + "q", "_:e1"},
+			{"_:e0", ex + "p", "_:e2"},
+			{"_:e1", ex + "p", "_:e3"},
+			{"_:e2", ex + "r", "_:e3"},
+		})
+		nq, err := store.CanonicalNQuads()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nq).To(Equal(
+			"<http://example.com/#p> <http://example.com/#q> _:c14n2 .\n" +
+				"<http://example.com/#p> <http://example.com/#q> _:c14n3 .\n" +
+				"_:c14n0 <http://example.com/#r> _:c14n1 .\n" +
+				"_:c14n2 <http://example.com/#p> _:c14n1 .\n" +
+				"_:c14n3 <http://example.com/#p> _:c14n0 .\n"))
+	})
+
+	It("should serialize literals and graph names", func() {
+		store := NewQuadStore()
+		store.Add("http://example.org/s", "http://example.org/p", Literal{Value: "say \"hi\"\n"}, "")
+		store.Add("http://example.org/s", "http://example.org/p", Literal{Value: "chat", Language: "fr"}, "http://example.org/g")
+		store.Add("http://example.org/s", "http://example.org/p", 42, "_:g")
+		store.Add("http://example.org/s", "http://example.org/p", 4.2, "")
+		store.Add("http://example.org/s", "http://example.org/p", true, "")
+		nq, err := store.CanonicalNQuads()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nq).To(Equal(
+			`<http://example.org/s> <http://example.org/p> "4.2E0"^^<http://www.w3.org/2001/XMLSchema#double> .` + "\n" +
+				`<http://example.org/s> <http://example.org/p> "42"^^<http://www.w3.org/2001/XMLSchema#integer> _:c14n0 .` + "\n" +
+				`<http://example.org/s> <http://example.org/p> "chat"@fr <http://example.org/g> .` + "\n" +
+				`<http://example.org/s> <http://example.org/p> "say \"hi\"\n" .` + "\n" +
+				`<http://example.org/s> <http://example.org/p> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> .` + "\n"))
+	})
+
+	// Graphs whose blank nodes can not be told apart by their
+	// immediate neighbourhoods, which exercise the N-degree hashing.
+	hardGraphs := map[string][]*Quad{
+		"a cycle": {
+			{"_:a", "p", "_:b", ""},
+			{"_:b", "p", "_:c", ""},
+			{"_:c", "p", "_:a", ""},
+		},
+		"two disjoint cycles": {
+			{"_:a", "p", "_:b", ""},
+			{"_:b", "p", "_:a", ""},
+			{"_:c", "p", "_:d", ""},
+			{"_:d", "p", "_:c", ""},
+			{"_:c", "q", "x", ""},
+		},
+		"a symmetric diamond": {
+			{"_:top", "p", "_:l", ""},
+			{"_:top", "p", "_:r", ""},
+			{"_:l", "p", "_:bottom", ""},
+			{"_:r", "p", "_:bottom", ""},
+			{"_:l", "q", "_:r", "_:g"},
+		},
+	}
+
+	for name, quads := range hardGraphs {
+		quads := quads
+		It("should canonicalize "+name+" regardless of labelling and order", func() {
+			want, err := relabel(quads, 0).CanonicalNQuads()
+			Expect(err).NotTo(HaveOccurred())
+			for seed := int64(1); seed < 20; seed++ {
+				store := relabel(quads, seed)
+				Expect(store.CanonicalNQuads()).To(Equal(want))
+				ok, err := Isomorphic(store, relabel(quads, 0))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeTrue())
+			}
+		})
+	}
+
+	It("should distinguish graphs that are not isomorphic", func() {
+		a := NewQuadStore([][3]string{
+			{"_:a", "p", "_:b"},
+			{"_:b", "p", "_:c"},
+			{"_:c", "p", "_:a"},
+		})
+		b := NewQuadStore([][3]string{
+			{"_:a", "p", "_:b"},
+			{"_:b", "p", "_:a"},
+			{"_:c", "p", "_:c"},
+		})
+		c := NewQuadStore([][3]string{
+			{"_:a", "p", "_:b"},
+			{"_:b", "p", "_:a"},
+		})
+		Expect(Isomorphic(a, b)).To(BeFalse())
+		Expect(Isomorphic(a, c)).To(BeFalse())
+		ha, _ := a.CanonicalHash()
+		hb, _ := b.CanonicalHash()
+		Expect(ha).To(HaveLen(64))
+		Expect(ha).NotTo(Equal(hb))
+	})
+
+	It("should compare graph views, ignoring their names", func() {
+		store := NewQuadStore([][4]string{
+			{"_:x", "knows", "_:y", "g1"},
+			{"_:y", "name", "Bob", "g1"},
+			{"_:a", "knows", "_:b", "g2"},
+			{"_:b", "name", "Bob", "g2"},
+			{"_:b", "name", "Robert", "g3"},
+		})
+		g1, g2, g3 := store.GraphView("g1"), store.GraphView("g2"), store.GraphView("g3")
+		Expect(Isomorphic(g1, g2)).To(BeTrue())
+		Expect(Isomorphic(g1, g3)).To(BeFalse())
+		h1, _ := g1.CanonicalHash()
+		h2, _ := g2.CanonicalHash()
+		Expect(h1).To(Equal(h2))
+		nq, _ := g1.CanonicalNQuads()
+		Expect(nq).To(Equal("_:c14n0 <knows> _:c14n1 .\n_:c14n1 <name> <Bob> .\n"))
+		labels, _ := g2.CanonicalLabels()
+		Expect(labels).To(Equal(map[string]string{"_:a": "_:c14n0", "_:b": "_:c14n1"}))
+	})
+
+	It("should panic for unexpected argument types", func() {
+		Expect(func() { Isomorphic(NewQuadStore(), 42) }).To(Panic())
+	})
+})
@@ -0,0 +1,110 @@
+package store4
+
+// Clone returns a copy of the store, holding the same quads and graphs.
+//
+// The copy is made by duplicating the store's term pool and indexes
+// directly, so no terms are re-interned. Callbacks, subscriptions,
+// reachability indexes and the journal are not copied.
+func (s *QuadStore) Clone() *QuadStore {
+	c := &QuadStore{
+		size:   s.size,
+		graphs: make(graphMap, len(s.graphs)),
+		pool:   s.pool.clone(),
+	}
+	for name, g := range s.graphs {
+		c.graphs[name] = g.clone()
+	}
+	return c
+}
+
+// CopyTo adds the quads in the store that match the given pattern
+// to the given store. Returns the count of quads that were new to dst.
+//
+// Passing "*" (an asterisk) for any parameter acts as a
+// match-everything wildcard for that term.
+//
+// If provided, dst's OnBatch callback is called once, with all of the
+// quads added. As with Add, CopyTo will panic if any quad is rejected
+// by dst's BeforeAdd callback, in which case quads already copied remain.
+func (s *QuadStore) CopyTo(dst *QuadStore, subject, predicate string, object interface{}, graph string) uint64 {
+	if dst == s {
+		return 0
+	}
+	var quads []quad
+	s.ForEachWith(subject, predicate, object, graph, func(s, p string, o interface{}, g string) {
+		quads = append(quads, quad{s, p, o, g})
+	})
+	return dst.addQuads(quads)
+}
+
+// CopyTo adds the predicate-object tuples of the view to the given store,
+// as quads with the given subject and graph, such that the entity's
+// description can be copied under a different name or into a different graph.
+// Returns the count of quads that were new to dst.
+//
+// If the view's Graph is "*" (an asterisk), tuples from all graphs are copied.
+//
+// As with Add, CopyTo will panic if any quad is rejected
+// by dst's BeforeAdd callback, or if subject or graph are "*".
+func (v *SubjectView) CopyTo(dst *QuadStore, subject, graph string) uint64 {
+	var quads []quad
+	v.ForEach(func(p string, o interface{}) {
+		quads = append(quads, quad{subject, p, o, graph})
+	})
+	return dst.addQuads(quads)
+}
+
+// clone returns a copy of the pool.
+func (s *pool) clone() *pool {
+	c := &pool{
+		strToID:      make(map[string]uint64, len(s.strToID)),
+		idToStrInfo:  make(map[uint64]*strInfo, len(s.idToStrInfo)),
+		nextStrID:    s.nextStrID,
+		itemToID:     make(map[interface{}]uint64, len(s.itemToID)),
+		idToItemInfo: make(map[uint64]*itemInfo, len(s.idToItemInfo)),
+		nextItemID:   s.nextItemID,
+	}
+	for k, v := range s.strToID {
+		c.strToID[k] = v
+	}
+	for k, v := range s.idToStrInfo {
+		info := *v
+		c.idToStrInfo[k] = &info
+	}
+	for k, v := range s.itemToID {
+		c.itemToID[k] = v
+	}
+	for k, v := range s.idToItemInfo {
+		info := *v
+		c.idToItemInfo[k] = &info
+	}
+	return c
+}
+
+// clone returns a copy of the graph and its indexes.
+func (g *indexedGraph) clone() *indexedGraph {
+	return &indexedGraph{
+		size:     g.size,
+		explicit: g.explicit,
+		spoIndex: g.spoIndex.clone(),
+		posIndex: g.posIndex.clone(),
+		ospIndex: g.ospIndex.clone(),
+	}
+}
+
+// clone returns a copy of the index.
+func (idx indexRoot) clone() indexRoot {
+	c := make(indexRoot, len(idx))
+	for k0, index1 := range idx {
+		c1 := make(indexMid, len(index1))
+		for k1, index2 := range index1 {
+			c2 := make(indexLeaf, len(index2))
+			for k2 := range index2 {
+				c2[k2] = struct{}{}
+			}
+			c1[k1] = c2
+		}
+		c[k0] = c1
+	}
+	return c
+}
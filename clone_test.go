@@ -0,0 +1,95 @@
+package store4_test
+
+import (
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Clone", func() {
+
+	var store *QuadStore
+
+	BeforeEach(func() {
+		store = NewQuadStore([]*Quad{
+			{"s1", "p1", "o1", ""},
+			{"s1", "p2", 42, "g1"},
+			{"s2", "p1", "s1", "g1"},
+		})
+	})
+
+	It("should copy all quads and graphs", func() {
+		store.CreateGraph("empty")
+		c := store.Clone()
+		Expect(c.Size()).To(Equal(store.Size()))
+		Expect(iterResults(c)).To(ConsistOf(iterResults(store)))
+		Expect(c.Graphs()).To(Equal([]string{"", "empty", "g1"}))
+	})
+
+	It("should be independent of the original", func() {
+		c := store.Clone()
+		c.Remove("s1", "*", "*", "*")
+		c.Add("s3", "p3", 3, "g2")
+		Expect(store.Size()).To(Equal(uint64(3)))
+		Expect(store.FindObjects("s1", "p2", "g1")).To(Equal([]interface{}{42}))
+		Expect(c.Size()).To(Equal(uint64(2)))
+		// Terms must remain usable in both stores.
+		store.Remove("*", "*", "*", "*")
+		Expect(c.FindObjects("s3", "p3", "g2")).To(Equal([]interface{}{3}))
+		Expect(c.FindSubjects("p1", "s1", "g1")).To(Equal([]string{"s2"}))
+	})
+
+	It("should not copy callbacks", func() {
+		called := false
+		store.OnAdd = func(s, p string, o interface{}, g string) {
+			called = true
+		}
+		store.Clone().Add("s", "p", "o", "")
+		Expect(called).To(BeFalse())
+	})
+})
+
+var _ = Describe("CopyTo", func() {
+
+	var store *QuadStore
+
+	BeforeEach(func() {
+		store = NewQuadStore([]*Quad{
+			{"s1", "p1", "o1", ""},
+			{"s1", "p2", 42, "g1"},
+			{"s2", "p1", "o2", "g1"},
+		})
+	})
+
+	It("should copy matching quads into another store", func() {
+		dst := NewQuadStore([]*Quad{
+			{"s2", "p1", "o2", "g1"},
+		})
+		var batches int
+		dst.OnBatch = func(changes []Change) {
+			batches++
+		}
+		Expect(store.CopyTo(dst, "*", "p1", "*", "*")).To(Equal(uint64(1)))
+		Expect(iterResults(dst)).To(ConsistOf([]*Quad{
+			{"s1", "p1", "o1", ""},
+			{"s2", "p1", "o2", "g1"},
+		}))
+		Expect(batches).To(Equal(1))
+	})
+
+	It("should do nothing when copying to itself", func() {
+		Expect(store.CopyTo(store, "*", "*", "*", "*")).To(BeZero())
+		Expect(store.Size()).To(Equal(uint64(3)))
+	})
+
+	It("should copy a subject's description", func() {
+		dst := NewQuadStore()
+		v := store.SubjectView("s1", "*")
+		Expect(v.CopyTo(dst, "copy", "g2")).To(Equal(uint64(2)))
+		Expect(iterResults(dst)).To(ConsistOf([]*Quad{
+			{"copy", "p1", "o1", "g2"},
+			{"copy", "p2", 42, "g2"},
+		}))
+	})
+})
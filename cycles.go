@@ -0,0 +1,151 @@
+package store4
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CycleError is the error returned when a cycle is found
+// where an acyclic graph is expected.
+//
+// Returned by calls to TopologicalOrder, and by the
+// callback returned by AcyclicGuard.
+type CycleError struct {
+	// Predicate is the predicate whose edges form the cycle.
+	Predicate string
+	// Cycle holds the nodes of the cycle, in order. Each node has an
+	// edge to the next, and the last node has an edge to the first.
+	Cycle []string
+}
+
+// Error implements the error interface.
+func (e *CycleError) Error() string {
+	path := append(append([]string{}, e.Cycle...), e.Cycle[0])
+	return fmt.Sprintf("cycle in %s: %s", e.Predicate, strings.Join(path, " -> "))
+}
+
+// FindCycles returns a cycle for each strongly connected component
+// formed by edges with the given predicate in the given graph,
+// such that the result is empty only if the graph is acyclic.
+//
+// Each cycle is a shortest cycle through the first node of its component,
+// with nodes ordered by their N-Quads form, and is listed starting there.
+// A node with an edge to itself is a cycle of one node.
+//
+// Passing "*" (an asterisk) for the graph follows edges in all graphs.
+func (s *QuadStore) FindCycles(predicate, graph string) [][]string {
+	var out [][]string
+	opts := &TraversalOptions{Predicates: []string{predicate}}
+	for _, group := range s.GraphView(graph).StronglyConnectedComponents(predicate).Groups() {
+		// Components without cycles are single nodes without self-loops.
+		first, ok := group[0].(string)
+		if !ok || len(group) == 1 && s.Count(first, predicate, first, graph) == 0 {
+			continue
+		}
+		var best []Edge
+		objects := s.FindObjects(first, predicate, graph)
+		sortTerms(objects)
+		for _, next := range objects {
+			if path := s.ShortestPath(next, first, graph, opts); path != nil && (best == nil || len(path) < len(best)) {
+				best = append([]Edge{{first, predicate, next}}, path...)
+			}
+		}
+		out = append(out, cycleNodes(best))
+	}
+	return out
+}
+
+// TopologicalOrder returns the nodes connected by edges with the given
+// predicate in the given graph, ordered such that each subject comes
+// before its objects. Nodes are otherwise ordered by their N-Quads form.
+//
+// If the edges form a cycle, then a *CycleError is returned
+// holding a cycle found by FindCycles.
+//
+// Passing "*" (an asterisk) for the graph follows edges in all graphs.
+func (s *QuadStore) TopologicalOrder(predicate, graph string) ([]interface{}, error) {
+	a := newAnalyticsGraph(s.GraphView(graph), []string{predicate})
+	// Kahn's algorithm, a layer at a time.
+	remaining := make([]int, len(a.nodes))
+	var layer []int
+	for i := range a.nodes {
+		remaining[i] = len(a.in[i])
+		if remaining[i] == 0 {
+			layer = append(layer, i)
+		}
+	}
+	out := make([]interface{}, 0, len(a.nodes))
+	for len(layer) > 0 {
+		terms := make([]interface{}, len(layer))
+		for i, n := range layer {
+			terms[i] = a.term(a.nodes[n])
+		}
+		sortTerms(terms)
+		out = append(out, terms...)
+		var next []int
+		for _, n := range layer {
+			for _, m := range a.out[n] {
+				remaining[m]--
+				if remaining[m] == 0 {
+					next = append(next, m)
+				}
+			}
+		}
+		layer = next
+	}
+	if len(out) < len(a.nodes) {
+		return nil, &CycleError{Predicate: predicate, Cycle: s.FindCycles(predicate, graph)[0]}
+	}
+	return out, nil
+}
+
+// AcyclicGuard returns a callback, for use with the store's BeforeAdd hook,
+// that rejects any quad that would add a cycle to the edges with
+// the given predicate in the given graph, returning a *CycleError.
+//
+// Passing "*" (an asterisk) for the graph guards against cycles
+// formed by edges in any graph.
+//
+// The guard checks reachability using an attached ReachabilityIndex for
+// the predicate and graph, if any, and otherwise by traversing the graph.
+// Quads added before the guard is in place are not checked.
+func (s *QuadStore) AcyclicGuard(predicate, graph string) QuadValidateFn {
+	opts := &TraversalOptions{Predicates: []string{predicate}}
+	return func(subject, p string, object interface{}, g string) error {
+		if p != predicate || graph != "*" && g != graph {
+			return nil
+		}
+		// Only string objects can have edges leading back to the subject.
+		o, ok := object.(string)
+		if !ok || o != subject && !s.Reachable(o, subject, predicate, graph) {
+			return nil
+		}
+		path := s.ShortestPath(object, subject, graph, opts)
+		cycle := cycleNodes(append([]Edge{{subject, predicate, object}}, path...))
+		return &CycleError{Predicate: predicate, Cycle: cycle}
+	}
+}
+
+// FindCycles returns a cycle for each strongly connected component
+// formed by edges with the given predicate in the graph.
+// See QuadStore.FindCycles.
+func (g *GraphView) FindCycles(predicate string) [][]string {
+	return g.QuadStore.FindCycles(predicate, g.Graph)
+}
+
+// TopologicalOrder returns the nodes connected by edges with the given
+// predicate in the graph, ordered such that each subject comes before
+// its objects. See QuadStore.TopologicalOrder.
+func (g *GraphView) TopologicalOrder(predicate string) ([]interface{}, error) {
+	return g.QuadStore.TopologicalOrder(predicate, g.Graph)
+}
+
+// cycleNodes returns the subjects of the given edges,
+// which form a cycle.
+func cycleNodes(edges []Edge) []string {
+	out := make([]string, 0, len(edges))
+	for _, e := range edges {
+		out = append(out, e.Subject)
+	}
+	return out
+}
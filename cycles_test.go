@@ -0,0 +1,133 @@
+package store4_test
+
+import (
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cycles", func() {
+
+	var s *QuadStore
+
+	BeforeEach(func() {
+		s = NewQuadStore()
+		s.Add("dog", "broader", "mammal", "")
+		s.Add("cat", "broader", "mammal", "")
+		s.Add("mammal", "broader", "animal", "")
+		s.Add("animal", "broader", "thing", "")
+		s.Add("dog", "label", "Dog", "")
+	})
+
+	Describe("FindCycles", func() {
+
+		It("should find nothing in an acyclic graph", func() {
+			Expect(s.FindCycles("broader", "")).To(BeEmpty())
+		})
+
+		It("should find a cycle in each strongly connected component", func() {
+			s.Add("thing", "broader", "mammal", "")
+			s.Add("x", "broader", "y", "")
+			s.Add("y", "broader", "x", "")
+			s.Add("z", "broader", "z", "")
+			Expect(s.FindCycles("broader", "")).To(Equal([][]string{
+				{"animal", "thing", "mammal"},
+				{"x", "y"},
+				{"z"},
+			}))
+		})
+
+		It("should find the shortest cycle through the first node", func() {
+			s.Add("animal", "broader", "mammal", "")
+			s.Add("thing", "broader", "mammal", "")
+			Expect(s.FindCycles("broader", "")).To(Equal([][]string{
+				{"animal", "mammal"},
+			}))
+		})
+
+		It("should only follow the given predicate and graph", func() {
+			s.Add("thing", "narrower", "animal", "")
+			s.Add("thing", "broader", "animal", "g2")
+			Expect(s.FindCycles("broader", "")).To(BeEmpty())
+			Expect(s.GraphView("*").FindCycles("broader")).To(Equal([][]string{
+				{"animal", "thing"},
+			}))
+		})
+	})
+
+	Describe("TopologicalOrder", func() {
+
+		It("should order subjects before their objects", func() {
+			order, err := s.TopologicalOrder("broader", "")
+			Expect(err).To(BeNil())
+			Expect(order).To(Equal([]interface{}{"cat", "dog", "mammal", "animal", "thing"}))
+		})
+
+		It("should return a cycle error", func() {
+			s.Add("thing", "broader", "dog", "")
+			order, err := s.GraphView("").TopologicalOrder("broader")
+			Expect(order).To(BeNil())
+			Expect(err).To(Equal(&CycleError{
+				Predicate: "broader",
+				Cycle:     []string{"animal", "thing", "dog", "mammal"},
+			}))
+			Expect(err.Error()).To(Equal("cycle in broader: animal -> thing -> dog -> mammal -> animal"))
+		})
+
+		It("should include non-string objects", func() {
+			s.Add("thing", "broader", 42, "")
+			order, err := s.TopologicalOrder("broader", "")
+			Expect(err).To(BeNil())
+			Expect(order[len(order)-1]).To(Equal(42))
+		})
+
+		It("should return an empty order for an empty graph", func() {
+			order, err := s.TopologicalOrder("broader", "nothing")
+			Expect(err).To(BeNil())
+			Expect(order).To(BeEmpty())
+		})
+	})
+
+	Describe("AcyclicGuard", func() {
+
+		BeforeEach(func() {
+			s.BeforeAdd = s.AcyclicGuard("broader", "")
+		})
+
+		It("should reject quads that would add a cycle", func() {
+			ok, err := s.TryAdd("thing", "broader", "dog", "")
+			Expect(ok).To(BeFalse())
+			Expect(err).To(Equal(&CycleError{
+				Predicate: "broader",
+				Cycle:     []string{"thing", "dog", "mammal", "animal"},
+			}))
+			Expect(s.Count("thing", "broader", "dog", "")).To(BeEquivalentTo(0))
+		})
+
+		It("should reject self-loops", func() {
+			_, err := s.TryAdd("dog", "broader", "dog", "")
+			Expect(err).To(Equal(&CycleError{Predicate: "broader", Cycle: []string{"dog"}}))
+		})
+
+		It("should allow quads that keep the graph acyclic", func() {
+			Expect(s.Add("dog", "broader", "animal", "")).To(BeTrue())
+			Expect(s.Add("thing", "narrower", "dog", "")).To(BeTrue())
+			Expect(s.Add("thing", "broader", "dog", "g2")).To(BeTrue())
+			Expect(s.Add("thing", "broader", 42, "")).To(BeTrue())
+		})
+
+		It("should use an attached reachability index", func() {
+			r := NewReachabilityIndex(s, "broader", "")
+			defer r.Detach()
+			_, err := s.TryAdd("thing", "broader", "cat", "")
+			Expect(err).To(BeAssignableToTypeOf(&CycleError{}))
+		})
+
+		It("should guard edges in all graphs", func() {
+			s.BeforeAdd = s.AcyclicGuard("broader", "*")
+			_, err := s.TryAdd("thing", "broader", "dog", "g2")
+			Expect(err).To(BeAssignableToTypeOf(&CycleError{}))
+		})
+	})
+})
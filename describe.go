@@ -0,0 +1,167 @@
+package store4
+
+// DescribeCBD returns a new store holding the Concise Bounded Description
+// of the given subject in the given graph: all quads with the subject
+// as their subject, together with, recursively, the descriptions of
+// any blank nodes among their objects.
+//
+// Passing "*" (an asterisk) for the graph describes the subject
+// across all graphs, and quads keep their graphs in the new store.
+func (s *QuadStore) DescribeCBD(subject, graph string) *QuadStore {
+	out := NewQuadStore()
+	out.addQuads(s.describe(subject, graph, false))
+	return out
+}
+
+// DescribeSCBD returns a new store holding the Symmetric Concise Bounded
+// Description of the given node in the given graph: its Concise Bounded
+// Description, together with all quads with the node as their object and,
+// recursively, the quads with any blank nodes among their subjects as
+// their object.
+//
+// Passing "*" (an asterisk) for the graph describes the node
+// across all graphs, and quads keep their graphs in the new store.
+func (s *QuadStore) DescribeSCBD(node interface{}, graph string) *QuadStore {
+	out := NewQuadStore()
+	out.addQuads(s.describeSymmetric(node, graph))
+	return out
+}
+
+// Neighborhood returns a new store holding the quads in the given graph
+// followed by a traversal from the given node, such that the store holds
+// the node's k-hop neighborhood when opts.MaxDepth is k. See GraphView.BFS.
+//
+// Passing "*" (an asterisk) for the graph follows edges in all graphs,
+// and quads keep their graphs in the new store.
+func (s *QuadStore) Neighborhood(node interface{}, graph string, opts *TraversalOptions) *QuadStore {
+	out := NewQuadStore()
+	out.addQuads(s.neighborhood(node, graph, opts))
+	return out
+}
+
+// DescribeCBD returns a new GraphView holding the Concise Bounded
+// Description of the given subject in the graph. See QuadStore.DescribeCBD.
+func (g *GraphView) DescribeCBD(subject string) *GraphView {
+	return newGraphFrom(g.QuadStore.describe(subject, g.Graph, false))
+}
+
+// DescribeSCBD returns a new GraphView holding the Symmetric Concise Bounded
+// Description of the given node in the graph. See QuadStore.DescribeSCBD.
+func (g *GraphView) DescribeSCBD(node interface{}) *GraphView {
+	return newGraphFrom(g.QuadStore.describeSymmetric(node, g.Graph))
+}
+
+// Neighborhood returns a new GraphView holding the triples in the graph
+// followed by a traversal from the given node. See QuadStore.Neighborhood.
+func (g *GraphView) Neighborhood(node interface{}, opts *TraversalOptions) *GraphView {
+	return newGraphFrom(g.QuadStore.neighborhood(node, g.Graph, opts))
+}
+
+// DescribeCBD returns a new store holding the Concise Bounded Description
+// of the view's subject, extending the view's single level of tuples
+// with the descriptions of any blank nodes among its objects.
+// See QuadStore.DescribeCBD.
+func (v *SubjectView) DescribeCBD() *QuadStore {
+	return v.QuadStore.DescribeCBD(v.Subject, v.Graph)
+}
+
+// describe returns the quads describing the given node in the given graph.
+// If inverse is false, these are the quads with the node as their subject,
+// recursing into blank node objects. If inverse is true, these are the quads
+// with the node as their object, recursing into blank node subjects.
+func (s *QuadStore) describe(node interface{}, graph string, inverse bool) []quad {
+	var quads []quad
+	visited := map[interface{}]struct{}{node: {}}
+	queue := []interface{}{node}
+	follow := func(n interface{}) {
+		if _, ok := visited[n]; ok || !isBlankNode(n) {
+			return
+		}
+		visited[n] = struct{}{}
+		queue = append(queue, n)
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if inverse {
+			s.ForEachWith("*", "*", n, graph, func(s, p string, o interface{}, g string) {
+				quads = append(quads, quad{s, p, o, g})
+				follow(s)
+			})
+		} else if subject, ok := n.(string); ok {
+			s.ForEachWith(subject, "*", "*", graph, func(s, p string, o interface{}, g string) {
+				quads = append(quads, quad{s, p, o, g})
+				follow(o)
+			})
+		}
+	}
+	return quads
+}
+
+// describeSymmetric returns the quads describing the given node
+// in the given graph, both as subject and as object.
+func (s *QuadStore) describeSymmetric(node interface{}, graph string) []quad {
+	quads := s.describe(node, graph, true)
+	return append(quads, s.describe(node, graph, false)...)
+}
+
+// neighborhood returns the quads in the given graph followed by a
+// traversal from the given node, breadth-first. Each quad leading from
+// a node within the depth limit is included, unless the node limit
+// prevents the node it leads to from being visited.
+func (s *QuadStore) neighborhood(node interface{}, graph string, opts *TraversalOptions) []quad {
+	t := newTraversal(s, graph, opts)
+	id, ok := s.pool.anyToID(node)
+	if !ok || id == 0 {
+		return nil
+	}
+	var quads []quad
+	visited := map[uint64]struct{}{id: {}}
+	frontier := []uint64{id}
+	for depth := 1; len(frontier) > 0 && !t.tooDeep(depth); depth++ {
+		var next []uint64
+		for _, n := range frontier {
+			t.edges(n, func(e [3]uint64, m uint64) bool {
+				if _, ok := visited[m]; !ok {
+					if t.full(len(visited)) {
+						return false
+					}
+					visited[m] = struct{}{}
+					next = append(next, m)
+				}
+				quads = append(quads, t.quads(e, graph)...)
+				return false
+			})
+		}
+		frontier = next
+	}
+	return quads
+}
+
+// quads returns the quads for the triple with the given IDs,
+// one for each of the traversal's graphs that holds it.
+func (t *traversal) quads(e [3]uint64, graph string) []quad {
+	edge := t.edge(e)
+	var out []quad
+	t.store.graphs.forEachMatch(graph, func(name string, g *indexedGraph) {
+		if _, ok := g.spoIndex[e[0]][e[1]][e[2]]; ok {
+			out = append(out, quad{edge.Subject, edge.Predicate, edge.Object, name})
+		}
+	})
+	return out
+}
+
+// newGraphFrom returns a new GraphView holding the triples of the given quads.
+func newGraphFrom(quads []quad) *GraphView {
+	g := NewGraph()
+	g.QuadStore.addQuads(triplesIn(quads, g.Graph))
+	return g
+}
+
+// triplesIn returns the given quads, moved into the given graph.
+func triplesIn(quads []quad, graph string) []quad {
+	for i := range quads {
+		quads[i].g = graph
+	}
+	return quads
+}
@@ -0,0 +1,122 @@
+package store4_test
+
+import (
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Describe", func() {
+
+	var s *QuadStore
+
+	BeforeEach(func() {
+		s = NewQuadStore()
+		s.Add("alice", "name", "Alice", "")
+		s.Add("alice", "address", "_:addr", "")
+		s.Add("_:addr", "city", "Paris", "")
+		s.Add("_:addr", "geo", "_:geo", "")
+		s.Add("_:geo", "lat", 48.85, "")
+		s.Add("alice", "knows", "bob", "")
+		s.Add("bob", "name", "Bob", "")
+		s.Add("bob", "knows", "alice", "")
+		s.Add("_:review", "about", "alice", "")
+		s.Add("_:review", "rating", 5, "")
+		s.Add("_:src", "wrote", "_:review", "")
+		s.Add("alice", "age", 42, "other")
+	})
+
+	Describe("DescribeCBD", func() {
+
+		It("should include blank node sub-structures", func() {
+			d := s.DescribeCBD("alice", "")
+			Expect(iterResults(d)).To(ConsistOf([]*Quad{
+				{"alice", "name", "Alice", ""},
+				{"alice", "address", "_:addr", ""},
+				{"_:addr", "city", "Paris", ""},
+				{"_:addr", "geo", "_:geo", ""},
+				{"_:geo", "lat", 48.85, ""},
+				{"alice", "knows", "bob", ""},
+			}))
+		})
+
+		It("should describe across all graphs", func() {
+			d := s.DescribeCBD("alice", "*")
+			Expect(d.Size()).To(BeEquivalentTo(7))
+			Expect(d.Count("alice", "age", 42, "other")).To(BeEquivalentTo(1))
+		})
+
+		It("should terminate on blank node cycles", func() {
+			s.Add("_:geo", "back", "_:addr", "")
+			Expect(s.DescribeCBD("alice", "").Size()).To(BeEquivalentTo(7))
+		})
+
+		It("should return an empty store for an unknown subject", func() {
+			Expect(s.DescribeCBD("nobody", "").Empty()).To(BeTrue())
+		})
+
+		It("should be available on SubjectView", func() {
+			Expect(s.SubjectView("alice", "").DescribeCBD().Size()).To(BeEquivalentTo(6))
+		})
+
+		It("should be available on GraphView", func() {
+			g := s.GraphView("").DescribeCBD("alice")
+			Expect(g.Size()).To(BeEquivalentTo(6))
+			Expect(g.Count("_:geo", "lat", 48.85)).To(BeEquivalentTo(1))
+		})
+	})
+
+	Describe("DescribeSCBD", func() {
+
+		It("should include incoming links and their blank node sources", func() {
+			d := s.DescribeSCBD("alice", "")
+			Expect(d.Size()).To(BeEquivalentTo(9))
+			Expect(d.Count("bob", "knows", "alice", "")).To(BeEquivalentTo(1))
+			Expect(d.Count("_:review", "about", "alice", "")).To(BeEquivalentTo(1))
+			Expect(d.Count("_:src", "wrote", "_:review", "")).To(BeEquivalentTo(1))
+			// The blank node's other properties are not part of its backward description.
+			Expect(d.Count("_:review", "rating", 5, "")).To(BeEquivalentTo(0))
+			Expect(d.Count("bob", "name", "Bob", "")).To(BeEquivalentTo(0))
+		})
+
+		It("should describe non-string nodes by their incoming links", func() {
+			g := s.GraphView("").DescribeSCBD(5)
+			Expect(g.Size()).To(BeEquivalentTo(2))
+			Expect(g.Count("_:src", "wrote", "_:review")).To(BeEquivalentTo(1))
+		})
+	})
+
+	Describe("Neighborhood", func() {
+
+		It("should hold the k-hop neighborhood", func() {
+			d := s.Neighborhood("bob", "", &TraversalOptions{MaxDepth: 1})
+			Expect(iterResults(d)).To(ConsistOf([]*Quad{
+				{"bob", "name", "Bob", ""},
+				{"bob", "knows", "alice", ""},
+			}))
+			d = s.Neighborhood("bob", "", &TraversalOptions{MaxDepth: 2})
+			Expect(d.Size()).To(BeEquivalentTo(5))
+			Expect(d.Count("alice", "address", "_:addr", "")).To(BeEquivalentTo(1))
+		})
+
+		It("should follow incoming edges and restrict predicates", func() {
+			g := s.GraphView("").Neighborhood("alice", &TraversalOptions{
+				Direction:  Both,
+				Predicates: []string{"knows", "about"},
+				MaxDepth:   1,
+			})
+			Expect(g.Size()).To(BeEquivalentTo(3))
+			Expect(g.Count("_:review", "about", "alice")).To(BeEquivalentTo(1))
+		})
+
+		It("should keep quads in their graphs", func() {
+			d := s.Neighborhood("alice", "*", &TraversalOptions{MaxDepth: 1})
+			Expect(d.Count("alice", "age", 42, "other")).To(BeEquivalentTo(1))
+		})
+
+		It("should return an empty store for an unknown node", func() {
+			Expect(s.Neighborhood("nobody", "", nil).Empty()).To(BeTrue())
+		})
+	})
+})
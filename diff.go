@@ -0,0 +1,108 @@
+package store4
+
+import "sort"
+
+// GraphDiff holds the differences between two versions of a graph.
+type GraphDiff struct {
+	// Added holds the quads present only in the second version.
+	Added []Change
+	// Removed holds the quads present only in the first version.
+	Removed []Change
+}
+
+// DatasetDiff holds the differences between two stores,
+// as a GraphDiff for each graph that differs, keyed by graph name.
+//
+// Returned by calls to Diff.
+type DatasetDiff map[string]*GraphDiff
+
+// Diff compares two stores, returning the quads that would have to be
+// removed from a and added to a, to make it equal to b. Only graphs that
+// differ are included.
+//
+// Terms are compared exactly, so blank nodes are matched by label.
+// Changes within each GraphDiff are sorted, so that results are stable.
+func Diff(a, b *QuadStore) DatasetDiff {
+	d := make(DatasetDiff)
+	get := func(g string) *GraphDiff {
+		gd, ok := d[g]
+		if !ok {
+			gd = &GraphDiff{}
+			d[g] = gd
+		}
+		return gd
+	}
+	a.ForEach(func(s, p string, o interface{}, g string) {
+		if !b.contains(s, p, o, g) {
+			gd := get(g)
+			gd.Removed = append(gd.Removed, Change{Removed, s, p, o, g})
+		}
+	})
+	b.ForEach(func(s, p string, o interface{}, g string) {
+		if !a.contains(s, p, o, g) {
+			gd := get(g)
+			gd.Added = append(gd.Added, Change{Added, s, p, o, g})
+		}
+	})
+	for _, gd := range d {
+		sortChanges(gd.Added)
+		sortChanges(gd.Removed)
+	}
+	return d
+}
+
+// Graphs returns the names of the graphs that differ, sorted.
+func (d DatasetDiff) Graphs() []string {
+	out := make([]string, 0, len(d))
+	for g := range d {
+		out = append(out, g)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Patch returns the differences as a patch, which holds a single
+// transaction: all deletions, followed by all additions, graph by graph.
+func (d DatasetDiff) Patch() *Patch {
+	p := &Patch{}
+	p.Rows = append(p.Rows, PatchRow{Op: PatchTxBegin})
+	graphs := d.Graphs()
+	for _, g := range graphs {
+		for _, c := range d[g].Removed {
+			p.Rows = append(p.Rows, changeRow(PatchDelete, c))
+		}
+	}
+	for _, g := range graphs {
+		for _, c := range d[g].Added {
+			p.Rows = append(p.Rows, changeRow(PatchAdd, c))
+		}
+	}
+	p.Rows = append(p.Rows, PatchRow{Op: PatchTxCommit})
+	return p
+}
+
+// changeRow returns a patch row with the given op, for the quad of the given change.
+func changeRow(op PatchOp, c Change) PatchRow {
+	return PatchRow{
+		Op:        op,
+		Subject:   c.Subject,
+		Predicate: c.Predicate,
+		Object:    c.Object,
+		Graph:     c.Graph,
+	}
+}
+
+// sortChanges sorts changes by subject, predicate and object,
+// comparing objects by their N-Quads form.
+func sortChanges(changes []Change) {
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := &changes[i], &changes[j]
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		if a.Predicate != b.Predicate {
+			return a.Predicate < b.Predicate
+		}
+		return formatNQuadsTerm(a.Object) < formatNQuadsTerm(b.Object)
+	})
+}
@@ -0,0 +1,64 @@
+package store4_test
+
+import (
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diff", func() {
+
+	It("should return no differences for equal stores", func() {
+		a := NewQuadStore([][4]string{{"s", "p", "o", "g"}})
+		b := NewQuadStore([][4]string{{"s", "p", "o", "g"}})
+		Expect(Diff(a, b)).To(BeEmpty())
+	})
+
+	It("should return added and removed quads per graph", func() {
+		a := NewQuadStore([]*Quad{
+			{"s1", "p1", "o1", ""},
+			{"s2", "p2", 42, "g1"},
+			{"s3", "p3", "o3", "g2"},
+		})
+		b := NewQuadStore([]*Quad{
+			{"s1", "p1", "o1", ""},
+			{"s2", "p2", 43, "g1"},
+			{"s4", "p4", "o4", "g3"},
+		})
+		d := Diff(a, b)
+		Expect(d.Graphs()).To(Equal([]string{"g1", "g2", "g3"}))
+		Expect(d["g1"]).To(Equal(&GraphDiff{
+			Added:   []Change{{Added, "s2", "p2", 43, "g1"}},
+			Removed: []Change{{Removed, "s2", "p2", 42, "g1"}},
+		}))
+		Expect(d["g2"]).To(Equal(&GraphDiff{
+			Removed: []Change{{Removed, "s3", "p3", "o3", "g2"}},
+		}))
+		Expect(d["g3"]).To(Equal(&GraphDiff{
+			Added: []Change{{Added, "s4", "p4", "o4", "g3"}},
+		}))
+	})
+
+	It("should return a patch that turns one store into the other", func() {
+		a := NewQuadStore([]*Quad{
+			{"s1", "p1", "o1", ""},
+			{"s2", "p2", 42, "g1"},
+		})
+		b := NewQuadStore([]*Quad{
+			{"s1", "p1", "o1", ""},
+			{"s2", "p2", 43, "g1"},
+			{"s3", "p3", Literal{Value: "x"}, ""},
+		})
+		p := Diff(a, b).Patch()
+		Expect(p.Rows).To(Equal([]PatchRow{
+			{Op: PatchTxBegin},
+			{Op: PatchDelete, Subject: "s2", Predicate: "p2", Object: 42, Graph: "g1"},
+			{Op: PatchAdd, Subject: "s3", Predicate: "p3", Object: Literal{Value: "x"}},
+			{Op: PatchAdd, Subject: "s2", Predicate: "p2", Object: 43, Graph: "g1"},
+			{Op: PatchTxCommit},
+		}))
+		Expect(a.ApplyPatch(p)).To(Succeed())
+		Expect(Diff(a, b)).To(BeEmpty())
+	})
+})
@@ -42,7 +42,28 @@
 //
 // QuadStore also features callback hooks for both OnAdd and OnRemove,
 // which can be used to integrate external features such as logging or
-// inference.
+// inference. The BeforeAdd and BeforeRemove hooks run before the store
+// is modified, and can reject a change by returning an error — which
+// is surfaced by TryAdd and TryRemove. For multiple independent listeners,
+// Subscribe registers handlers that receive changes matching a pattern,
+// and SubscribeChan delivers them over a channel.
+//
+// AddAll and RemoveAll modify the store in bulk, reporting all of
+// their changes with a single call to the OnBatch hook.
+//
+// EnableJournal records every change with a sequence number, enabling
+// Undo and Redo (see also BeginGroup and EndGroup), and ChangesSince
+// and Replay for keeping other stores in sync.
+//
+// Stats reports memory and cardinality statistics for the store,
+// and PublishExpvar makes them available through package expvar.
+//
+// Named graphs are created implicitly by Add, and removed when empty,
+// unless registered with CreateGraph. CopyGraph, MoveGraph, RenameGraph,
+// ClearGraph and DropGraph manage whole graphs at a time.
+//
+// Clone copies a whole store cheaply, by duplicating its indexes directly,
+// and CopyTo copies the quads matching a pattern into another store.
 //
 // GraphView API
 //
@@ -60,6 +81,36 @@
 // Obtain a GraphView by calling QuadStore.GraphView, QuadStore.GraphViews
 // — or NewGraph.
 //
+// Union, Intersection, Difference and SymmetricDifference combine two
+// GraphViews into a lazy TripleSetView, or two stores into a QuadSetView,
+// whose CopyTo method materializes the result into a target.
+//
+// BFS and DFS walk the graph from a start node, following outgoing or
+// incoming edges, optionally restricted to some predicates, and
+// ShortestPath and ShortestWeightedPath return paths as lists of Edges.
+// WeightByPredicate weighs edges by the numeric values of a predicate.
+//
+// ParsePropertyPath parses SPARQL-style property paths, such as "knows+"
+// or "^parent/child", which are evaluated by FindObjectsByPath and
+// FindSubjectsByPath.
+//
+// NewReachabilityIndex maintains the transitive closure of a predicate
+// as the store changes, so that Reachable can answer by lookup,
+// and can also materialize the closure as quads.
+//
+// WeaklyConnectedComponents, StronglyConnectedComponents, Degrees and
+// PageRank compute graph analytics over the store's ID-level indexes,
+// returning maps whose WriteTo methods write results back as quads.
+//
+// DescribeCBD and DescribeSCBD extract the (Symmetric) Concise Bounded
+// Description of a node, including its blank node sub-structures, and
+// Neighborhood extracts the quads within k hops of a node, each into
+// a new QuadStore or GraphView.
+//
+// FindCycles and TopologicalOrder check hierarchy predicates for cycles,
+// and AcyclicGuard returns a BeforeAdd callback that rejects quads
+// which would introduce one.
+//
 // SubjectView API
 //
 // The SubjectView API is based around predicate-object (property/value) tuples.
@@ -78,6 +129,76 @@
 //
 // SubjectViews are returned by calls to Query, SubjectView and SubjectViews.
 //
+// Follow, FollowPath, Parent and Parents navigate from a SubjectView
+// to the SubjectViews of related subjects.
+//
+// Marshal writes a Go struct to the store as the description of a subject,
+// driven by rdf struct tags giving each field's predicate, and Unmarshal
+// reads a SubjectView back into a struct.
+//
+// ObjectView API
+//
+// The ObjectView API is based around subject-predicate links to an object,
+// such as the backlinks to a resource, or the subjects having a literal value.
+//
+//  // Get a view over the links to Alice in 'unnamed' graph.
+//  v := s.ObjectView("Alice", "")
+//
+//  // Find all subjects linking to Alice with the knows predicate.
+//  subjects := v.FindSubjects("knows")
+//
+// ObjectViews are returned by calls to ObjectView, ObjectViews
+// and SubjectView.Incoming.
+//
+// PredicateView API
+//
+// The PredicateView API treats a predicate as a table column
+// of subject-object pairs, read directly from the POS index.
+//
+//  // Get a view over all ages in 'unnamed' graph.
+//  v := s.PredicateView("age", "")
+//
+//  // Get the mean of the numeric ages.
+//  avg, ok := v.Average()
+//
+// PredicateViews are returned by calls to PredicateView.
+//
+// Canonicalization
+//
+// Blank nodes are strings beginning with "_:", and string literals
+// can be held as Literals. CanonicalNQuads, CanonicalLabels and
+// CanonicalHash implement RDF Dataset Canonicalization (RDFC-1.0) on both
+// QuadStore and GraphView, and Isomorphic compares datasets regardless
+// of how their blank nodes are labelled.
+//
+// Diff compares two stores graph by graph, and its result can be turned
+// into a Patch. Patches are read and written in the RDF Patch text format
+// with ReadPatch and Patch.WriteTo, and ApplyPatch applies a patch
+// to a store atomically.
+//
+// Reasoning
+//
+// NewRDFSReasoner attaches an RDFS reasoner to a store, which maintains
+// the store's RDFS entailments in a separate graph as data changes.
+//
+// NewRuleEngine evaluates user-defined Datalog-style rules, which can be
+// parsed from text with ParseRules. Rules are evaluated to a fixpoint by Run,
+// or kept up to date incrementally after a call to Attach.
+//
+// NewOWLReasoner uses a RuleEngine to maintain a subset of the OWL 2 RL
+// entailments (owl:sameAs, owl:inverseOf, and transitive, symmetric,
+// functional and inverse-functional properties). Its Check method reports
+// contradictions as an *InconsistencyError.
+//
+// Validation
+//
+// NewSHACLValidator reads SHACL shapes from a graph, and validates
+// data graphs (or a whole store) against them, producing a
+// ValidationReport that can also be converted to quads.
+//
+// ParseShExC parses a ShEx schema, against which SubjectViews
+// can be validated, individually or using a shape map.
+//
 // Implementation
 //
 // Inside QuadStore each graph is indexed by SPO, POS and OSP,
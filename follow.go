@@ -0,0 +1,77 @@
+package store4
+
+import "sort"
+
+// Follow returns a list of SubjectViews for the distinct string objects
+// of the given predicate, in the view's graph, sorted by subject.
+// Objects that are not strings cannot be subjects, so are skipped.
+//
+// If the view's Graph is "*" (an asterisk), links are followed in all graphs,
+// and the returned views also span all graphs, such that a link held in one
+// graph can lead to a subject described in another.
+//
+// Passing "*" (an asterisk) for the predicate follows links with any predicate.
+func (v *SubjectView) Follow(predicate string) []*SubjectView {
+	var subjects []string
+	v.ForObjects(predicate, func(o interface{}) {
+		if s, ok := o.(string); ok {
+			subjects = append(subjects, s)
+		}
+	})
+	return v.views(subjects)
+}
+
+// FollowPath returns a list of SubjectViews for the distinct string nodes
+// reached from the view's subject by the given SPARQL-style property path,
+// such as "knows/worksFor", sorted by subject. See ParsePropertyPath.
+//
+// As with Follow, a view whose Graph is "*" (an asterisk)
+// follows links in all graphs, and returns views spanning all graphs.
+//
+// Returns an error if the path cannot be parsed.
+func (v *SubjectView) FollowPath(path string) ([]*SubjectView, error) {
+	p, err := ParsePropertyPath(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var subjects []string
+	for _, o := range v.QuadStore.FindObjectsByPath(v.Subject, p, v.Graph) {
+		if s, ok := o.(string); ok {
+			subjects = append(subjects, s)
+		}
+	}
+	return v.views(subjects), nil
+}
+
+// Parents returns a list of SubjectViews for the distinct subjects
+// linking to the view's subject with the given predicate,
+// in the view's graph, sorted by subject.
+//
+// As with Follow, a view whose Graph is "*" (an asterisk)
+// follows links in all graphs, and returns views spanning all graphs.
+func (v *SubjectView) Parents(predicate string) []*SubjectView {
+	return v.views(v.QuadStore.FindSubjects(predicate, v.Subject, v.Graph))
+}
+
+// Parent returns a SubjectView for a subject linking to the view's subject
+// with the given predicate, such as the parent in a hierarchy,
+// or nil if there is none. If there are several, the first
+// by sort order is returned.
+func (v *SubjectView) Parent(predicate string) *SubjectView {
+	parents := v.Parents(predicate)
+	if len(parents) == 0 {
+		return nil
+	}
+	return parents[0]
+}
+
+// views returns SubjectViews for the given subjects,
+// in the view's graph, sorted by subject.
+func (v *SubjectView) views(subjects []string) []*SubjectView {
+	sort.Strings(subjects)
+	out := make([]*SubjectView, len(subjects))
+	for i, subject := range subjects {
+		out[i] = v.QuadStore.SubjectView(subject, v.Graph)
+	}
+	return out
+}
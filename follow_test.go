@@ -0,0 +1,100 @@
+package store4_test
+
+import (
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Follow", func() {
+
+	var store *QuadStore
+
+	subjects := func(views []*SubjectView) []string {
+		out := make([]string, len(views))
+		for i, v := range views {
+			out[i] = v.Subject
+		}
+		return out
+	}
+
+	BeforeEach(func() {
+		store = NewQuadStore()
+		store.Add("alice", "knows", "bob", "")
+		store.Add("alice", "knows", "carol", "")
+		store.Add("alice", "age", 42, "")
+		store.Add("bob", "worksFor", "acme", "")
+		store.Add("carol", "worksFor", "initech", "")
+		store.Add("alice", "knows", "dave", "people")
+		store.Add("bob", "knows", "dave", "people")
+		store.Add("dave", "worksFor", "acme", "")
+		store.Add("acme", "parentOrg", "megacorp", "")
+		store.Add("initech", "parentOrg", "megacorp", "")
+	})
+
+	Describe("Follow", func() {
+
+		It("should return views of string objects in the same graph", func() {
+			views := store.SubjectView("alice", "").Follow("knows")
+			Expect(subjects(views)).To(Equal([]string{"bob", "carol"}))
+			Expect(views[0].Graph).To(Equal(""))
+			Expect(views[0].FindObjects("worksFor")).To(Equal([]interface{}{"acme"}))
+		})
+
+		It("should skip non-string objects", func() {
+			views := store.SubjectView("alice", "").Follow("*")
+			Expect(subjects(views)).To(Equal([]string{"bob", "carol"}))
+		})
+
+		It("should follow links across graphs", func() {
+			views := store.SubjectView("alice", "*").Follow("knows")
+			Expect(subjects(views)).To(Equal([]string{"bob", "carol", "dave"}))
+			Expect(views[2].Graph).To(Equal("*"))
+			Expect(views[2].FindObjects("worksFor")).To(Equal([]interface{}{"acme"}))
+		})
+
+		It("should return nothing for a missing predicate", func() {
+			Expect(store.SubjectView("alice", "").Follow("missing")).To(BeEmpty())
+		})
+	})
+
+	Describe("FollowPath", func() {
+
+		It("should follow a sequence of predicates", func() {
+			views, err := store.SubjectView("alice", "").FollowPath("knows/worksFor")
+			Expect(err).To(BeNil())
+			Expect(subjects(views)).To(Equal([]string{"acme", "initech"}))
+		})
+
+		It("should follow paths across graphs", func() {
+			views, err := store.SubjectView("alice", "*").FollowPath("knows+/worksFor/parentOrg")
+			Expect(err).To(BeNil())
+			Expect(subjects(views)).To(Equal([]string{"megacorp"}))
+		})
+
+		It("should return an error for an invalid path", func() {
+			views, err := store.SubjectView("alice", "").FollowPath("knows/")
+			Expect(views).To(BeNil())
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("Parents", func() {
+
+		It("should return views of linking subjects", func() {
+			views := store.SubjectView("acme", "").Parents("worksFor")
+			Expect(subjects(views)).To(Equal([]string{"bob", "dave"}))
+			Expect(store.SubjectView("dave", "*").Parents("knows")).To(HaveLen(2))
+		})
+
+		It("should return the first parent", func() {
+			Expect(store.SubjectView("acme", "").Parent("worksFor").Subject).To(Equal("bob"))
+			Expect(store.SubjectView("megacorp", "").Parent("parentOrg").Subject).To(Equal("acme"))
+		})
+
+		It("should return nil when there is no parent", func() {
+			Expect(store.SubjectView("alice", "").Parent("knows")).To(BeNil())
+		})
+	})
+})
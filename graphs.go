@@ -0,0 +1,347 @@
+package store4
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrGraphExists is returned by RenameGraph when the target graph
+// already holds quads.
+var ErrGraphExists = errors.New("graph already exists")
+
+// CreateGraph explicitly registers the named graph, so that it is kept
+// in the store (and listed by Graphs) even while it holds no quads.
+// Returns true if the graph was not already registered.
+//
+// Graphs are otherwise created implicitly by adding quads to them,
+// and removed when their last quad is removed.
+//
+// If the given graph is "*" (an asterisk), then this method will panic.
+func (s *QuadStore) CreateGraph(graph string) bool {
+	if graph == "*" {
+		panic(ErrWildcardTerm)
+	}
+	g, ok := s.graphs[graph]
+	if !ok {
+		g = newIndexedGraph(0)
+		s.graphs[graph] = g
+	}
+	if g.explicit {
+		return false
+	}
+	g.explicit = true
+	return true
+}
+
+// HasGraph returns true if the store holds the named graph, either
+// because it holds quads in that graph, or because the graph
+// was registered by CreateGraph.
+func (s *QuadStore) HasGraph(graph string) bool {
+	_, ok := s.graphs[graph]
+	return ok
+}
+
+// Graphs returns the names of all graphs in the store,
+// including any empty graphs registered by CreateGraph, sorted.
+func (s *QuadStore) Graphs() []string {
+	out := make([]string, 0, len(s.graphs))
+	for graph := range s.graphs {
+		out = append(out, graph)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ClearGraph removes all quads from the named graph, returning the count
+// of quads removed. A graph registered by CreateGraph remains registered.
+//
+// Passing "*" (an asterisk) clears every graph.
+//
+// As with TryRemove, if any of the quads are rejected by the BeforeRemove
+// callback, then the callback's error is returned, and no quads are removed.
+func (s *QuadStore) ClearGraph(graph string) (uint64, error) {
+	defer s.beginBatch(0)()
+	s.BeginGroup()
+	defer s.EndGroup()
+	return s.TryRemove("*", "*", "*", graph)
+}
+
+// DropGraph removes all quads from the named graph, and unregisters
+// the graph if it was registered by CreateGraph. Returns the count
+// of quads removed.
+//
+// Passing "*" (an asterisk) drops every graph.
+//
+// As with TryRemove, if any of the quads are rejected by the BeforeRemove
+// callback, then the callback's error is returned, and nothing is changed.
+func (s *QuadStore) DropGraph(graph string) (uint64, error) {
+	n, err := s.ClearGraph(graph)
+	if err != nil {
+		return 0, err
+	}
+	if graph == "*" {
+		s.graphs = make(graphMap)
+	} else {
+		delete(s.graphs, graph)
+	}
+	return n, nil
+}
+
+// CopyGraph replaces the contents of the graph named dst with
+// the contents of the graph named src, leaving src unchanged.
+// Returns the count of quads added to dst.
+//
+// As with SPARQL's COPY, any quads in dst that are not in src are removed.
+// Copying a graph to itself has no effect.
+//
+// ErrWildcardTerm is returned if either graph is "*" (an asterisk).
+// If any change is rejected by the BeforeAdd or BeforeRemove callbacks,
+// then the callback's error is returned, and nothing is changed.
+func (s *QuadStore) CopyGraph(src, dst string) (uint64, error) {
+	return s.transferGraph(src, dst, false)
+}
+
+// MoveGraph replaces the contents of the graph named dst with
+// the contents of the graph named src, and then drops src.
+//
+// If dst holds no quads, MoveGraph is equivalent to RenameGraph.
+// Moving a graph to itself has no effect.
+//
+// ErrWildcardTerm is returned if either graph is "*" (an asterisk).
+// If any change is rejected by the BeforeAdd or BeforeRemove callbacks,
+// then the callback's error is returned, and nothing is changed.
+func (s *QuadStore) MoveGraph(src, dst string) error {
+	if src == "*" || dst == "*" {
+		return ErrWildcardTerm
+	}
+	if g, ok := s.graphs[dst]; !ok || g.size == 0 {
+		return s.RenameGraph(src, dst)
+	}
+	_, err := s.transferGraph(src, dst, true)
+	return err
+}
+
+// RenameGraph renames the graph named from to the given name,
+// keeping its contents and registration.
+//
+// The graph's indexes are moved rather than copied, so renaming takes
+// constant time. However, if the journal is enabled, or there are
+// callbacks (including OnBatch) or subscriptions to notify, then each
+// quad is reported as removed from the old graph and added to the new
+// one, within a single batch, and renaming takes time proportional to
+// the size of the graph. The same is true when there are BeforeAdd or
+// BeforeRemove callbacks, which are called for each quad.
+//
+// ErrGraphExists is returned if the target graph already holds quads,
+// and ErrWildcardTerm is returned if either graph is "*" (an asterisk).
+// If any quad is rejected by the BeforeAdd or BeforeRemove callbacks,
+// then the callback's error is returned, and nothing is changed.
+func (s *QuadStore) RenameGraph(from, to string) error {
+	if from == "*" || to == "*" {
+		return ErrWildcardTerm
+	}
+	if from == to {
+		return nil
+	}
+	g, ok := s.graphs[from]
+	if !ok {
+		return nil
+	}
+	target, ok := s.graphs[to]
+	if ok && target.size > 0 {
+		return ErrGraphExists
+	}
+	defer s.beginBatch(0)()
+	var triples [][3]uint64
+	if s.BeforeAdd != nil || s.BeforeRemove != nil || s.observed() {
+		triples = g.triples()
+		if err := s.validateRemove(triples, from); err != nil {
+			return err
+		}
+		if err := s.validateAdd(triples, to); err != nil {
+			return err
+		}
+	}
+	delete(s.graphs, from)
+	if target != nil {
+		g.explicit = g.explicit || target.explicit
+	}
+	s.graphs[to] = g
+	if s.observed() {
+		s.BeginGroup()
+		defer s.EndGroup()
+		for _, t := range triples {
+			s.removed(s.pool.idToString(t[0]), s.pool.idToString(t[1]), s.pool.idToAny(t[2]), from)
+		}
+		for _, t := range triples {
+			s.added(s.pool.idToString(t[0]), s.pool.idToString(t[1]), s.pool.idToAny(t[2]), to)
+		}
+	}
+	return nil
+}
+
+// transferGraph implements CopyGraph, and MoveGraph if drop is true.
+// Returns the count of quads added to dst.
+func (s *QuadStore) transferGraph(src, dst string, drop bool) (uint64, error) {
+	if src == "*" || dst == "*" {
+		return 0, ErrWildcardTerm
+	}
+	if src == dst {
+		return 0, nil
+	}
+	sg := s.graphs[src]
+	dg := s.graphs[dst]
+	var srcTriples, toAdd, toRemove [][3]uint64
+	if sg != nil {
+		srcTriples = sg.triples()
+		for _, t := range srcTriples {
+			if !dg.has(t) {
+				toAdd = append(toAdd, t)
+			}
+		}
+	}
+	if dg != nil {
+		for _, t := range dg.triples() {
+			if !sg.has(t) {
+				toRemove = append(toRemove, t)
+			}
+		}
+	}
+	// Validate every change before making any of them.
+	if err := s.validateRemove(toRemove, dst); err != nil {
+		return 0, err
+	}
+	if err := s.validateAdd(toAdd, dst); err != nil {
+		return 0, err
+	}
+	if drop {
+		if err := s.validateRemove(srcTriples, src); err != nil {
+			return 0, err
+		}
+	}
+
+	defer s.beginBatch(0)()
+	s.BeginGroup()
+	defer s.EndGroup()
+	if dg == nil {
+		dg = newIndexedGraph(len(toAdd))
+		s.graphs[dst] = dg
+	}
+	for _, t := range toRemove {
+		s.deleteTriple(dg, dst, t)
+	}
+	for _, t := range toAdd {
+		s.insertTriple(dg, dst, t)
+	}
+	if sg != nil && sg.explicit {
+		dg.explicit = true
+	}
+	if drop {
+		for _, t := range srcTriples {
+			s.deleteTriple(sg, src, t)
+		}
+		delete(s.graphs, src)
+	}
+	if dg.size == 0 && !dg.explicit {
+		delete(s.graphs, dst)
+	}
+	return uint64(len(toAdd)), nil
+}
+
+// validateRemove calls the BeforeRemove callback for each of the given
+// triples in the named graph, returning the first error.
+func (s *QuadStore) validateRemove(triples [][3]uint64, graph string) error {
+	if s.BeforeRemove == nil {
+		return nil
+	}
+	for _, t := range triples {
+		if err := s.BeforeRemove(s.pool.idToString(t[0]), s.pool.idToString(t[1]), s.pool.idToAny(t[2]), graph); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateAdd calls the BeforeAdd callback for each of the given
+// triples in the named graph, returning the first error.
+func (s *QuadStore) validateAdd(triples [][3]uint64, graph string) error {
+	if s.BeforeAdd == nil {
+		return nil
+	}
+	for _, t := range triples {
+		if err := s.BeforeAdd(s.pool.idToString(t[0]), s.pool.idToString(t[1]), s.pool.idToAny(t[2]), graph); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertTriple adds the triple with the given IDs to the given graph,
+// which must not already hold it, taking references on its terms.
+func (s *QuadStore) insertTriple(g *indexedGraph, graph string, t [3]uint64) {
+	addToIndex(g.spoIndex, t[0], t[1], t[2])
+	addToIndex(g.posIndex, t[1], t[2], t[0])
+	addToIndex(g.ospIndex, t[2], t[0], t[1])
+	s.pool.addRef(t[0])
+	s.pool.addRef(t[1])
+	s.pool.addRef(t[2])
+	s.size++
+	g.size++
+	if s.observed() {
+		s.added(s.pool.idToString(t[0]), s.pool.idToString(t[1]), s.pool.idToAny(t[2]), graph)
+	}
+}
+
+// deleteTriple removes the triple with the given IDs from the given graph,
+// which must hold it, releasing references on its terms.
+// The graph itself is kept, even if it becomes empty.
+func (s *QuadStore) deleteTriple(g *indexedGraph, graph string, t [3]uint64) {
+	deleteFromIndex(g.spoIndex, t[0], t[1], t[2])
+	deleteFromIndex(g.posIndex, t[1], t[2], t[0])
+	deleteFromIndex(g.ospIndex, t[2], t[0], t[1])
+	s.size--
+	g.size--
+	if s.observed() {
+		s.removed(s.pool.idToString(t[0]), s.pool.idToString(t[1]), s.pool.idToAny(t[2]), graph)
+	}
+	s.pool.releaseRefString(t[0])
+	s.pool.releaseRefString(t[1])
+	s.pool.releaseRefAny(t[2])
+}
+
+// deleteFromIndex removes a triple from the given index,
+// removing any index buckets that become empty.
+func deleteFromIndex(index0 indexRoot, key0, key1, key2 uint64) {
+	index1 := index0[key0]
+	index2 := index1[key1]
+	delete(index2, key2)
+	if len(index2) == 0 {
+		delete(index1, key1)
+		if len(index1) == 0 {
+			delete(index0, key0)
+		}
+	}
+}
+
+// triples returns all triples in the graph, as IDs.
+func (g *indexedGraph) triples() [][3]uint64 {
+	out := make([][3]uint64, 0, g.size)
+	for sid, pm := range g.spoIndex {
+		for pid, om := range pm {
+			for oid := range om {
+				out = append(out, [3]uint64{sid, pid, oid})
+			}
+		}
+	}
+	return out
+}
+
+// has returns true if the graph holds the triple with the given IDs.
+// A nil graph holds nothing.
+func (g *indexedGraph) has(t [3]uint64) bool {
+	if g == nil {
+		return false
+	}
+	_, ok := g.spoIndex[t[0]][t[1]][t[2]]
+	return ok
+}
@@ -0,0 +1,221 @@
+package store4_test
+
+import (
+	"errors"
+
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Graph management", func() {
+
+	var store *QuadStore
+
+	BeforeEach(func() {
+		store = NewQuadStore([]*Quad{
+			{"s1", "p1", "o1", "a"},
+			{"s2", "p2", 2, "a"},
+			{"s2", "p2", 2, "b"},
+			{"s3", "p3", "o3", "b"},
+			{"s4", "p4", "o4", ""},
+		})
+	})
+
+	Describe("CreateGraph", func() {
+
+		It("should keep registered graphs while empty", func() {
+			Expect(store.CreateGraph("c")).To(BeTrue())
+			Expect(store.CreateGraph("c")).To(BeFalse())
+			Expect(store.HasGraph("c")).To(BeTrue())
+			Expect(store.Graphs()).To(Equal([]string{"", "a", "b", "c"}))
+			store.Add("s", "p", "o", "c")
+			store.Remove("s", "p", "o", "c")
+			Expect(store.HasGraph("c")).To(BeTrue())
+		})
+
+		It("should not keep implicit graphs once empty", func() {
+			store.Remove("*", "*", "*", "a")
+			Expect(store.HasGraph("a")).To(BeFalse())
+		})
+
+		It("should panic with a wildcard", func() {
+			Expect(func() { store.CreateGraph("*") }).To(Panic())
+		})
+	})
+
+	Describe("ClearGraph", func() {
+
+		It("should remove all quads in the graph", func() {
+			store.CreateGraph("a")
+			n, err := store.ClearGraph("a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(uint64(2)))
+			Expect(store.Size()).To(Equal(uint64(3)))
+			Expect(store.HasGraph("a")).To(BeTrue())
+		})
+
+		It("should report a veto and change nothing", func() {
+			veto := errors.New("vetoed")
+			store.BeforeRemove = func(s, p string, o interface{}, g string) error {
+				if s == "s2" {
+					return veto
+				}
+				return nil
+			}
+			_, err := store.ClearGraph("a")
+			Expect(err).To(MatchError(veto))
+			Expect(store.Size()).To(Equal(uint64(5)))
+		})
+	})
+
+	Describe("DropGraph", func() {
+
+		It("should remove and unregister the graph", func() {
+			store.CreateGraph("a")
+			n, err := store.DropGraph("a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(uint64(2)))
+			Expect(store.HasGraph("a")).To(BeFalse())
+		})
+
+		It("should drop every graph with a wildcard", func() {
+			store.CreateGraph("c")
+			n, err := store.DropGraph("*")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(uint64(5)))
+			Expect(store.Graphs()).To(BeEmpty())
+		})
+	})
+
+	Describe("CopyGraph", func() {
+
+		It("should replace the target's contents", func() {
+			n, err := store.CopyGraph("a", "b")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(uint64(1)))
+			Expect(iterResults(store)).To(ConsistOf([]*Quad{
+				{"s1", "p1", "o1", "a"},
+				{"s2", "p2", 2, "a"},
+				{"s1", "p1", "o1", "b"},
+				{"s2", "p2", 2, "b"},
+				{"s4", "p4", "o4", ""},
+			}))
+		})
+
+		It("should copy into a new graph", func() {
+			n, err := store.CopyGraph("", "c")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(uint64(1)))
+			Expect(store.FindObjects("s4", "p4", "c")).To(Equal([]interface{}{"o4"}))
+			// Terms remain referenced after the source is gone.
+			store.Remove("*", "*", "*", "")
+			Expect(store.FindObjects("s4", "p4", "c")).To(Equal([]interface{}{"o4"}))
+		})
+
+		It("should clear the target when the source does not exist", func() {
+			_, err := store.CopyGraph("missing", "a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store.HasGraph("a")).To(BeFalse())
+		})
+
+		It("should report changes", func() {
+			var changes []Change
+			store.OnBatch = func(c []Change) {
+				changes = c
+			}
+			_, err := store.CopyGraph("a", "b")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(ConsistOf(
+				Change{Removed, "s3", "p3", "o3", "b"},
+				Change{Added, "s1", "p1", "o1", "b"},
+			))
+		})
+
+		It("should report a veto and change nothing", func() {
+			veto := errors.New("vetoed")
+			store.BeforeAdd = func(s, p string, o interface{}, g string) error {
+				return veto
+			}
+			_, err := store.CopyGraph("a", "b")
+			Expect(err).To(MatchError(veto))
+			Expect(store.Count("*", "*", "*", "b")).To(Equal(uint64(2)))
+		})
+
+		It("should reject wildcards", func() {
+			_, err := store.CopyGraph("*", "b")
+			Expect(err).To(MatchError(ErrWildcardTerm))
+		})
+	})
+
+	Describe("MoveGraph", func() {
+
+		It("should replace the target's contents and drop the source", func() {
+			Expect(store.MoveGraph("a", "b")).To(Succeed())
+			Expect(iterResults(store)).To(ConsistOf([]*Quad{
+				{"s1", "p1", "o1", "b"},
+				{"s2", "p2", 2, "b"},
+				{"s4", "p4", "o4", ""},
+			}))
+			Expect(store.HasGraph("a")).To(BeFalse())
+		})
+
+		It("should rename when the target is empty", func() {
+			Expect(store.MoveGraph("a", "c")).To(Succeed())
+			Expect(store.Graphs()).To(Equal([]string{"", "b", "c"}))
+		})
+	})
+
+	Describe("RenameGraph", func() {
+
+		It("should rename the graph", func() {
+			Expect(store.RenameGraph("a", "c")).To(Succeed())
+			Expect(store.Graphs()).To(Equal([]string{"", "b", "c"}))
+			Expect(store.Count("*", "*", "*", "c")).To(Equal(uint64(2)))
+			Expect(store.Size()).To(Equal(uint64(5)))
+		})
+
+		It("should keep the graph's registration", func() {
+			store.CreateGraph("a")
+			Expect(store.RenameGraph("a", "c")).To(Succeed())
+			store.Remove("*", "*", "*", "c")
+			Expect(store.HasGraph("c")).To(BeTrue())
+		})
+
+		It("should not overwrite a graph", func() {
+			Expect(store.RenameGraph("a", "b")).To(MatchError(ErrGraphExists))
+		})
+
+		It("should notify subscribers and be undoable", func() {
+			store.EnableJournal()
+			var changes []Change
+			store.Subscribe("*", "*", "*", "*", func(c Change) {
+				changes = append(changes, c)
+			})
+			Expect(store.RenameGraph("a", "c")).To(Succeed())
+			Expect(changes).To(HaveLen(4))
+			Expect(store.Undo()).To(BeTrue())
+			Expect(store.Graphs()).To(Equal([]string{"", "a", "b"}))
+		})
+
+		It("should report the rename as a single batch", func() {
+			var batches [][]Change
+			store.OnBatch = func(changes []Change) {
+				batches = append(batches, changes)
+			}
+			Expect(store.RenameGraph("a", "c")).To(Succeed())
+			Expect(batches).To(HaveLen(1))
+			Expect(batches[0]).To(HaveLen(4))
+		})
+
+		It("should report a veto and change nothing", func() {
+			veto := errors.New("vetoed")
+			store.BeforeRemove = func(s, p string, o interface{}, g string) error {
+				return veto
+			}
+			Expect(store.RenameGraph("a", "c")).To(MatchError(veto))
+			Expect(store.Graphs()).To(Equal([]string{"", "a", "b"}))
+		})
+	})
+})
@@ -106,6 +106,17 @@ func (g *GraphView) Add(subject, predicate string, object interface{}) bool {
 	return g.QuadStore.Add(subject, predicate, object, g.Graph)
 }
 
+// TryAdd adds a quad to the underlying QuadStore,
+// with the given subject, predicate and object values
+// and this GraphView's Graph value.
+// Returns true if the quad was a new quad,
+// or false if the quad already existed.
+//
+// Unlike Add, TryAdd does not panic, see QuadStore.TryAdd.
+func (g *GraphView) TryAdd(subject, predicate string, object interface{}) (bool, error) {
+	return g.QuadStore.TryAdd(subject, predicate, object, g.Graph)
+}
+
 // Count returns a count of triples in the graph that match the given pattern.
 //
 // Passing "*" (an asterisk) for any parameter acts as a
@@ -234,6 +245,16 @@ func (g *GraphView) Remove(subject, predicate string, object interface{}) uint64
 	return g.QuadStore.Remove(subject, predicate, object, g.Graph)
 }
 
+// TryRemove removes quads from the underlying QuadStore,
+// with the given subject, predicate and object values
+// and this GraphView's Graph value.
+// Returns the number of quads that were removed.
+//
+// Unlike Remove, TryRemove does not panic, see QuadStore.TryRemove.
+func (g *GraphView) TryRemove(subject, predicate string, object interface{}) (uint64, error) {
+	return g.QuadStore.TryRemove(subject, predicate, object, g.Graph)
+}
+
 // Size returns the total count of triples in the graph.
 func (g *GraphView) Size() uint64 {
 	return g.QuadStore.Count("*", "*", "*", g.Graph)
@@ -0,0 +1,211 @@
+package store4
+
+// JournalEntry is a single change recorded by a QuadStore's journal,
+// together with its sequence number.
+//
+// Returned by calls to QuadStore's ChangesSince.
+type JournalEntry struct {
+	// Seq is the entry's sequence number, which increases
+	// monotonically (starting from 1) for each recorded change.
+	Seq uint64
+	Change
+}
+
+// journal records the effective changes made to a store,
+// and holds the undo and redo stacks.
+type journal struct {
+	// seq holds the sequence number of the last recorded change.
+	seq uint64
+	// entries holds all recorded changes, in order.
+	entries []JournalEntry
+	// undo and redo hold groups of changes, most recent last.
+	undo [][]Change
+	redo [][]Change
+	// group holds the changes of the currently open group.
+	group []Change
+	// depth is the nesting depth of open groups.
+	depth int
+	// applying is true while Undo or Redo are modifying the store.
+	applying bool
+}
+
+// EnableJournal starts recording every effective change made to the store,
+// each with a monotonically increasing sequence number. Recorded changes
+// can be undone and redone, and retrieved with ChangesSince.
+//
+// Each call that changes the store, such as Add, Remove or AddAll,
+// is undone as a single step, together with any changes made by
+// callbacks or subscriptions in reaction to it (for example, by an
+// attached reasoner).
+//
+// Calling EnableJournal on a store that already has
+// its journal enabled has no effect.
+//
+// Note that the journal retains every recorded change, so its memory
+// use grows with the number of changes made to the store.
+func (s *QuadStore) EnableJournal() {
+	if s.journal != nil {
+		return
+	}
+	s.journal = &journal{}
+}
+
+// DisableJournal stops recording changes, and discards the journal.
+func (s *QuadStore) DisableJournal() {
+	s.journal = nil
+}
+
+// record adds a change made to the store to the journal.
+func (j *journal) record(c Change) {
+	j.seq++
+	j.entries = append(j.entries, JournalEntry{Seq: j.seq, Change: c})
+	if j.applying {
+		// Undo and Redo manage the stacks themselves.
+		return
+	}
+	// A new edit invalidates anything that could be redone.
+	j.redo = nil
+	if j.depth > 0 {
+		j.group = append(j.group, c)
+		return
+	}
+	j.undo = append(j.undo, []Change{c})
+}
+
+// BeginGroup starts a group of changes, which will be undone and redone
+// as a single step. Groups may be nested, in which case the outermost
+// group forms the step.
+//
+// Each call to BeginGroup must be balanced by a call to EndGroup.
+// BeginGroup has no effect if the journal is not enabled.
+func (s *QuadStore) BeginGroup() {
+	if s.journal == nil {
+		return
+	}
+	s.journal.depth++
+}
+
+// EndGroup ends a group of changes started by BeginGroup.
+func (s *QuadStore) EndGroup() {
+	j := s.journal
+	if j == nil || j.depth == 0 {
+		return
+	}
+	j.depth--
+	if j.depth == 0 && len(j.group) > 0 {
+		j.undo = append(j.undo, j.group)
+		j.group = nil
+	}
+}
+
+// Undo reverts the most recent step (a single change, or a group of changes)
+// recorded by the journal. Returns true if a step was undone, or false if
+// there was nothing to undo, or the journal is not enabled.
+//
+// The changes made by Undo are themselves recorded in the journal,
+// so they are visible to ChangesSince. As with Add and Remove,
+// Undo will panic if any of its changes are rejected by the
+// BeforeAdd or BeforeRemove callbacks.
+func (s *QuadStore) Undo() bool {
+	j := s.journal
+	if j == nil || j.depth > 0 || len(j.undo) == 0 {
+		return false
+	}
+	step := j.undo[len(j.undo)-1]
+	j.undo = j.undo[:len(j.undo)-1]
+	j.applying = true
+	defer func() { j.applying = false }()
+	// Revert changes in reverse order.
+	for i := len(step) - 1; i >= 0; i-- {
+		s.apply(step[i], true)
+	}
+	j.redo = append(j.redo, step)
+	return true
+}
+
+// Redo reapplies the most recent step reverted by Undo.
+// Returns true if a step was redone, or false if there was nothing
+// to redo, or the journal is not enabled.
+//
+// Any new change made to the store, other than by Undo or Redo,
+// discards all steps that could have been redone.
+func (s *QuadStore) Redo() bool {
+	j := s.journal
+	if j == nil || j.depth > 0 || len(j.redo) == 0 {
+		return false
+	}
+	step := j.redo[len(j.redo)-1]
+	j.redo = j.redo[:len(j.redo)-1]
+	j.applying = true
+	defer func() { j.applying = false }()
+	for _, c := range step {
+		s.apply(c, false)
+	}
+	j.undo = append(j.undo, step)
+	return true
+}
+
+// apply makes the given change to the store, or reverts it if invert is true.
+func (s *QuadStore) apply(c Change, invert bool) {
+	if (c.Op == Added) != invert {
+		s.Add(c.Subject, c.Predicate, c.Object, c.Graph)
+	} else {
+		s.Remove(c.Subject, c.Predicate, c.Object, c.Graph)
+	}
+}
+
+// ChangesSince returns all changes recorded by the journal with
+// a sequence number greater than the given one, in order.
+// Passing 0 returns all recorded changes.
+//
+// Returns nil if the journal is not enabled.
+func (s *QuadStore) ChangesSince(seq uint64) []JournalEntry {
+	j := s.journal
+	if j == nil || len(j.entries) == 0 {
+		return nil
+	}
+	// Sequence numbers are contiguous, so we can index directly.
+	var i uint64
+	if first := j.entries[0].Seq; seq >= first {
+		i = seq - first + 1
+	}
+	if i >= uint64(len(j.entries)) {
+		return nil
+	}
+	out := make([]JournalEntry, len(j.entries)-int(i))
+	copy(out, j.entries[i:])
+	return out
+}
+
+// LastSeq returns the sequence number of the most recent change
+// recorded by the journal, or 0 if there are none or the
+// journal is not enabled.
+func (s *QuadStore) LastSeq() uint64 {
+	if s.journal == nil {
+		return 0
+	}
+	return s.journal.seq
+}
+
+// Replay applies the given journal entries to the store, in order.
+// It is typically used to bring another store up to date, with entries
+// obtained by calling ChangesSince.
+//
+// Replay stops at the first change that is rejected (by wildcard terms,
+// or by the BeforeAdd or BeforeRemove callbacks), and returns the error.
+// Changes applied before that point are kept.
+func (s *QuadStore) Replay(entries []JournalEntry) error {
+	for _, e := range entries {
+		var err error
+		switch e.Op {
+		case Added:
+			_, err = s.TryAdd(e.Subject, e.Predicate, e.Object, e.Graph)
+		case Removed:
+			_, err = s.TryRemove(e.Subject, e.Predicate, e.Object, e.Graph)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
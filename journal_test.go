@@ -0,0 +1,228 @@
+package store4_test
+
+import (
+	"errors"
+
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Journal", func() {
+
+	newStore := func() *QuadStore {
+		store := NewQuadStore([][4]string{
+			{"s1", "p1", "o1", ""},
+			{"s1", "p2", "o2", "g1"},
+		})
+		store.EnableJournal()
+		return store
+	}
+
+	Describe("A store without a journal", func() {
+		store := NewQuadStore([4]string{"s1", "p1", "o1", ""})
+
+		It("should have nothing to undo or redo", func() {
+			Expect(store.Undo()).To(BeFalse())
+			Expect(store.Redo()).To(BeFalse())
+		})
+		It("should have no changes", func() {
+			Expect(store.ChangesSince(0)).To(BeNil())
+			Expect(store.LastSeq()).To(BeZero())
+		})
+		It("should ignore groups", func() {
+			store.BeginGroup()
+			store.EndGroup()
+		})
+	})
+
+	Describe("ChangesSince", func() {
+
+		It("should return effective changes with increasing sequence numbers", func() {
+			store := newStore()
+			store.Add("s2", "p1", "o1", "")
+			store.Add("s2", "p1", "o1", "")
+			store.Remove("s1", "*", "*", "")
+			store.Remove("s9", "*", "*", "")
+			Expect(store.ChangesSince(0)).To(Equal([]JournalEntry{
+				{1, Change{Added, "s2", "p1", "o1", ""}},
+				{2, Change{Removed, "s1", "p1", "o1", ""}},
+			}))
+			Expect(store.LastSeq()).To(Equal(uint64(2)))
+		})
+
+		It("should only return changes after the given sequence number", func() {
+			store := newStore()
+			store.Add("s2", "p1", "o1", "")
+			store.Add("s3", "p1", "o1", "")
+			store.Add("s4", "p1", "o1", "")
+			Expect(store.ChangesSince(2)).To(Equal([]JournalEntry{
+				{3, Change{Added, "s4", "p1", "o1", ""}},
+			}))
+			Expect(store.ChangesSince(3)).To(BeEmpty())
+			Expect(store.ChangesSince(99)).To(BeEmpty())
+		})
+	})
+
+	Describe("Undo and Redo", func() {
+
+		It("should undo and redo single changes", func() {
+			store := newStore()
+			store.Add("s2", "p1", "o1", "")
+			store.Remove("s1", "p1", "o1", "")
+			Expect(store.Undo()).To(BeTrue())
+			Expect(store.Count("s1", "p1", "o1", "")).To(Equal(uint64(1)))
+			Expect(store.Undo()).To(BeTrue())
+			Expect(store.Count("s2", "p1", "o1", "")).To(BeZero())
+			Expect(store.Undo()).To(BeFalse())
+			Expect(store.Size()).To(Equal(uint64(2)))
+
+			Expect(store.Redo()).To(BeTrue())
+			Expect(store.Count("s2", "p1", "o1", "")).To(Equal(uint64(1)))
+			Expect(store.Redo()).To(BeTrue())
+			Expect(store.Count("s1", "p1", "o1", "")).To(BeZero())
+			Expect(store.Redo()).To(BeFalse())
+		})
+
+		It("should undo a group as a single step", func() {
+			store := newStore()
+			store.BeginGroup()
+			store.Add("s2", "p1", "o1", "")
+			store.BeginGroup()
+			store.Add("s3", "p1", "o1", "")
+			store.EndGroup()
+			store.Remove("s1", "*", "*", "*")
+			store.EndGroup()
+			Expect(store.Size()).To(Equal(uint64(2)))
+			Expect(store.Undo()).To(BeTrue())
+			Expect(iterResults(store)).To(ConsistOf([]*Quad{
+				{"s1", "p1", "o1", ""},
+				{"s1", "p2", "o2", "g1"},
+			}))
+			Expect(store.Undo()).To(BeFalse())
+			Expect(store.Redo()).To(BeTrue())
+			Expect(iterResults(store)).To(ConsistOf([]*Quad{
+				{"s2", "p1", "o1", ""},
+				{"s3", "p1", "o1", ""},
+			}))
+		})
+
+		It("should undo each call as a single step", func() {
+			store := newStore()
+			store.AddAll([][3]string{
+				{"s2", "p1", "o1"},
+				{"s3", "p1", "o1"},
+			})
+			store.Remove("s1", "*", "*", "*")
+			Expect(store.Size()).To(Equal(uint64(2)))
+			Expect(store.Undo()).To(BeTrue())
+			Expect(store.Size()).To(Equal(uint64(4)))
+			Expect(store.Undo()).To(BeTrue())
+			Expect(store.Size()).To(Equal(uint64(2)))
+			Expect(store.Count("s1", "*", "*", "*")).To(Equal(uint64(2)))
+		})
+
+		It("should undo changes made in reaction to a change with it", func() {
+			store := newStore()
+			store.Subscribe("*", "knows", "*", "*", func(c Change) {
+				if c.Op == Added {
+					store.Add(c.Object.(string), "knownBy", c.Subject, c.Graph)
+				} else {
+					store.Remove(c.Object.(string), "knownBy", c.Subject, c.Graph)
+				}
+			})
+			store.Add("alice", "knows", "bob", "")
+			store.Add("bob", "knows", "carol", "")
+			Expect(store.Size()).To(Equal(uint64(6)))
+			Expect(store.Undo()).To(BeTrue())
+			Expect(store.Count("*", "*", "carol", "")).To(BeZero())
+			Expect(store.Count("carol", "*", "*", "")).To(BeZero())
+			Expect(store.Count("bob", "knownBy", "alice", "")).To(Equal(uint64(1)))
+			Expect(store.Redo()).To(BeTrue())
+			Expect(store.Count("carol", "knownBy", "bob", "")).To(Equal(uint64(1)))
+			store.Remove("alice", "knows", "bob", "")
+			Expect(store.Count("bob", "knownBy", "alice", "")).To(BeZero())
+			Expect(store.Undo()).To(BeTrue())
+			Expect(store.Count("alice", "knows", "bob", "")).To(Equal(uint64(1)))
+			Expect(store.Count("bob", "knownBy", "alice", "")).To(Equal(uint64(1)))
+		})
+
+		It("should not undo while a group is open", func() {
+			store := newStore()
+			store.BeginGroup()
+			store.Add("s2", "p1", "o1", "")
+			Expect(store.Undo()).To(BeFalse())
+			store.EndGroup()
+			Expect(store.Undo()).To(BeTrue())
+		})
+
+		It("should discard redo steps after a new change", func() {
+			store := newStore()
+			store.Add("s2", "p1", "o1", "")
+			store.Undo()
+			store.Add("s3", "p1", "o1", "")
+			Expect(store.Redo()).To(BeFalse())
+		})
+
+		It("should record undo and redo changes in the journal", func() {
+			store := newStore()
+			store.Add("s2", "p1", "o1", "")
+			store.Undo()
+			store.Redo()
+			Expect(store.ChangesSince(0)).To(Equal([]JournalEntry{
+				{1, Change{Added, "s2", "p1", "o1", ""}},
+				{2, Change{Removed, "s2", "p1", "o1", ""}},
+				{3, Change{Added, "s2", "p1", "o1", ""}},
+			}))
+		})
+	})
+
+	Describe("DisableJournal", func() {
+		It("should stop recording and discard the journal", func() {
+			store := newStore()
+			store.EnableJournal()
+			store.Add("s2", "p1", "o1", "")
+			store.DisableJournal()
+			store.DisableJournal()
+			store.Add("s3", "p1", "o1", "")
+			Expect(store.ChangesSince(0)).To(BeNil())
+			Expect(store.Undo()).To(BeFalse())
+		})
+	})
+
+	Describe("Replay", func() {
+
+		It("should bring another store up to date", func() {
+			src := newStore()
+			dst := NewQuadStore([][4]string{
+				{"s1", "p1", "o1", ""},
+				{"s1", "p2", "o2", "g1"},
+			})
+			src.Add("s2", "p1", 42, "")
+			src.Remove("s1", "p2", "o2", "g1")
+			Expect(dst.Replay(src.ChangesSince(0))).To(Succeed())
+			Expect(iterResults(dst)).To(ConsistOf(iterResults(src)))
+
+			seq := src.LastSeq()
+			src.Add("s3", "p3", "o3", "g3")
+			Expect(dst.Replay(src.ChangesSince(seq))).To(Succeed())
+			Expect(iterResults(dst)).To(ConsistOf(iterResults(src)))
+		})
+
+		It("should stop at the first rejected change", func() {
+			src := newStore()
+			src.Add("s2", "p1", "o1", "")
+			src.Add("s3", "p1", "o1", "")
+			dst := NewQuadStore()
+			dst.BeforeAdd = func(s, p string, o interface{}, g string) error {
+				if s == "s3" {
+					return errors.New("rejected")
+				}
+				return nil
+			}
+			Expect(dst.Replay(src.ChangesSince(0))).To(MatchError("rejected"))
+			Expect(dst.Size()).To(Equal(uint64(1)))
+		})
+	})
+})
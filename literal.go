@@ -0,0 +1,324 @@
+package store4
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Literal is an RDF literal, for use as an object value.
+//
+// Plain Go strings used as terms are treated as IRIs (or as blank nodes,
+// if they begin with "_:"), so string literals should be held as Literals.
+// Values of other Go types are treated as typed literals: int is
+// xsd:integer, and the sized integer types are xsd:long, xsd:int,
+// xsd:short and xsd:byte, or their unsigned counterparts (with uint as
+// xsd:nonNegativeInteger); float32 is xsd:float, float64 is xsd:double,
+// bool is xsd:boolean, and time.Time is xsd:dateTime.
+//
+// Literal is comparable, so Literals can be used with the store's
+// query methods in the same way as any other object value.
+type Literal struct {
+	// Value is the literal's lexical form.
+	Value string
+	// Datatype is the IRI of the literal's datatype.
+	// An empty Datatype means xsd:string, or rdf:langString
+	// if the literal has a Language.
+	Datatype string
+	// Language is the literal's language tag, if any.
+	Language string
+}
+
+// String returns the literal in N-Quads syntax.
+func (l Literal) String() string {
+	s := quoteNQuadsString(l.Value)
+	switch {
+	case l.Language != "":
+		return s + "@" + l.Language
+	case l.Datatype != "" && l.Datatype != XSDNamespace+"string":
+		return s + "^^<" + l.Datatype + ">"
+	}
+	return s
+}
+
+// rdfLangString is the datatype of literals with a language tag.
+const rdfLangString = "http://www.w3.org/1999/02/22-rdf-syntax-ns#langString"
+
+// datatype returns the IRI of the literal's datatype, after defaulting.
+func (l Literal) datatype() string {
+	switch {
+	case l.Language != "":
+		return rdfLangString
+	case l.Datatype == "":
+		return XSDNamespace + "string"
+	}
+	return l.Datatype
+}
+
+// formatNQuadsTerm returns the given subject, predicate, object or graph
+// term in N-Quads syntax.
+func formatNQuadsTerm(t interface{}) string {
+	switch v := t.(type) {
+	case string:
+		if strings.HasPrefix(v, "_:") {
+			return v
+		}
+		return "<" + escapeNQuadsIRI(v) + ">"
+	case Literal:
+		return v.String()
+	case bool:
+		return typedLiteral(strconv.FormatBool(v), "boolean")
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return typedLiteral(fmt.Sprint(v), integerDatatype(v))
+	case float32:
+		return typedLiteral(formatXSDFloat(float64(v), 32), "float")
+	case float64:
+		return typedLiteral(formatXSDFloat(v, 64), "double")
+	case time.Time:
+		return typedLiteral(v.Format(time.RFC3339Nano), "dateTime")
+	}
+	return quoteNQuadsString(fmt.Sprint(t))
+}
+
+// integerDatatype returns the name of the XSD datatype
+// for a value of one of Go's integer types.
+func integerDatatype(v interface{}) string {
+	switch v.(type) {
+	case int:
+		return "integer"
+	case int8:
+		return "byte"
+	case int16:
+		return "short"
+	case int32:
+		return "int"
+	case int64:
+		return "long"
+	case uint:
+		return "nonNegativeInteger"
+	case uint8:
+		return "unsignedByte"
+	case uint16:
+		return "unsignedShort"
+	case uint32:
+		return "unsignedInt"
+	case uint64:
+		return "unsignedLong"
+	}
+	return ""
+}
+
+// typedLiteral returns a literal of the given XSD datatype in N-Quads syntax.
+func typedLiteral(value, datatype string) string {
+	return quoteNQuadsString(value) + "^^<" + XSDNamespace + datatype + ">"
+}
+
+// formatXSDFloat returns the canonical lexical form of an xsd:double
+// or xsd:float value, such as "4.2E0".
+func formatXSDFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "INF"
+	case math.IsInf(f, -1):
+		return "-INF"
+	}
+	s := strconv.FormatFloat(f, 'E', -1, bitSize)
+	i := strings.IndexByte(s, 'E')
+	mantissa, exp := s[:i], s[i+1:]
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	n, _ := strconv.Atoi(exp)
+	return mantissa + "E" + strconv.Itoa(n)
+}
+
+// quoteNQuadsString returns the given string as a quoted N-Quads string,
+// escaped as for canonical N-Quads.
+func quoteNQuadsString(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for _, c := range s {
+		switch c {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, c)
+			} else {
+				b.WriteRune(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// escapeNQuadsIRI escapes characters that may not appear within an IRI in N-Quads.
+func escapeNQuadsIRI(s string) string {
+	if !strings.ContainsAny(s, "<>\"{}|^`\\") && strings.IndexFunc(s, func(c rune) bool { return c <= 0x20 }) < 0 {
+		return s
+	}
+	var b strings.Builder
+	for _, c := range s {
+		if c <= 0x20 || strings.ContainsRune("<>\"{}|^`\\", c) {
+			fmt.Fprintf(&b, `\u%04X`, c)
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// lexicalForm returns the lexical form of the given object value,
+// for matching against patterns.
+func lexicalForm(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case Literal:
+		return x.Value
+	}
+	return fmt.Sprint(v)
+}
+
+// literalValue returns the Go value for a literal with the given lexical
+// form, datatype and language tag. Literals of the XSD datatypes that map
+// to Go types (as described for Literal) are converted to those types,
+// and all other literals, or those with invalid lexical forms, are returned
+// as a Literal.
+func literalValue(value, datatype, language string) interface{} {
+	if language != "" {
+		return Literal{Value: value, Language: language}
+	}
+	switch datatype {
+	case "", XSDNamespace + "string":
+		return Literal{Value: value}
+	case XSDNamespace + "integer":
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	case XSDNamespace + "long", XSDNamespace + "int", XSDNamespace + "short", XSDNamespace + "byte",
+		XSDNamespace + "nonNegativeInteger", XSDNamespace + "unsignedLong", XSDNamespace + "unsignedInt",
+		XSDNamespace + "unsignedShort", XSDNamespace + "unsignedByte":
+		if i, ok := parseXSDInteger(value, datatype[len(XSDNamespace):]); ok {
+			return i
+		}
+	case XSDNamespace + "double":
+		if f, err := parseXSDFloat(value, 64); err == nil {
+			return f
+		}
+	case XSDNamespace + "float":
+		if f, err := parseXSDFloat(value, 32); err == nil {
+			return float32(f)
+		}
+	case XSDNamespace + "boolean":
+		switch value {
+		case "true", "1":
+			return true
+		case "false", "0":
+			return false
+		}
+	case XSDNamespace + "dateTime":
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return fixZone(t, value)
+		}
+	}
+	return Literal{Value: value, Datatype: datatype}
+}
+
+// parseXSDInteger parses the lexical form of a value of the XSD integer
+// datatype with the given name, returning a value of the matching Go type.
+func parseXSDInteger(value, name string) (interface{}, bool) {
+	bits := map[string]int{
+		"long": 64, "int": 32, "short": 16, "byte": 8,
+		"nonNegativeInteger": 0, "unsignedLong": 64, "unsignedInt": 32,
+		"unsignedShort": 16, "unsignedByte": 8,
+	}[name]
+	if !strings.HasPrefix(name, "unsigned") && name != "nonNegativeInteger" {
+		i, err := strconv.ParseInt(value, 10, bits)
+		if err != nil {
+			return nil, false
+		}
+		switch bits {
+		case 64:
+			return i, true
+		case 32:
+			return int32(i), true
+		case 16:
+			return int16(i), true
+		}
+		return int8(i), true
+	}
+	u, err := strconv.ParseUint(strings.TrimPrefix(value, "+"), 10, bits)
+	if err != nil {
+		return nil, false
+	}
+	switch bits {
+	case 0:
+		return uint(u), true
+	case 64:
+		return u, true
+	case 32:
+		return uint32(u), true
+	case 16:
+		return uint16(u), true
+	}
+	return uint8(u), true
+}
+
+// fixZone returns the given time, parsed from the given xsd:dateTime
+// lexical form, in a zone that does not depend on the local time zone:
+// UTC for times ending in "Z", otherwise an unnamed fixed zone with the
+// time's offset. Fixed zones are shared, so that equal times read from
+// the same lexical form are equal Go values, and so the same object.
+func fixZone(t time.Time, value string) time.Time {
+	if strings.HasSuffix(value, "Z") {
+		return t.UTC()
+	}
+	_, offset := t.Zone()
+	fixedZonesMu.Lock()
+	defer fixedZonesMu.Unlock()
+	loc, ok := fixedZones[offset]
+	if !ok {
+		loc = time.FixedZone("", offset)
+		fixedZones[offset] = loc
+	}
+	return t.In(loc)
+}
+
+// fixedZones holds the zones returned by fixZone, keyed by offset.
+var (
+	fixedZonesMu sync.Mutex
+	fixedZones   = make(map[int]*time.Location)
+)
+
+// parseXSDFloat parses the lexical form of an xsd:double or xsd:float value.
+func parseXSDFloat(s string, bitSize int) (float64, error) {
+	switch s {
+	case "INF", "+INF":
+		return math.Inf(1), nil
+	case "-INF":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(s, bitSize)
+}
@@ -0,0 +1,50 @@
+package store4_test
+
+import (
+	"math"
+	"time"
+
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Literal", func() {
+
+	It("should format as N-Quads", func() {
+		Expect(Literal{Value: "plain"}.String()).To(Equal(`"plain"`))
+		Expect(Literal{Value: "x", Datatype: XSDNamespace + "string"}.String()).To(Equal(`"x"`))
+		Expect(Literal{Value: "hello", Language: "en-GB"}.String()).To(Equal(`"hello"@en-GB`))
+		Expect(Literal{Value: "1", Datatype: XSDNamespace + "int"}.String()).
+			To(Equal(`"1"^^<http://www.w3.org/2001/XMLSchema#int>`))
+		Expect(Literal{Value: "a\\b\t\r\b\f\x01\x7f"}.String()).To(Equal(`"a\\b\t\r\b\f\u0001\u007F"`))
+	})
+
+	It("should be usable as an object value", func() {
+		store := NewQuadStore()
+		store.Add("s", "p", Literal{Value: "chat", Language: "fr"}, "")
+		store.Add("s", "p", Literal{Value: "chat", Language: "en"}, "")
+		Expect(store.Count("s", "p", Literal{Value: "chat", Language: "fr"}, "")).To(Equal(uint64(1)))
+		Expect(store.Count("s", "p", "chat", "")).To(BeZero())
+	})
+
+	It("should give other values their XSD datatypes", func() {
+		g := NewGraph()
+		when := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		for _, o := range []interface{}{float32(1), 1e21, 0.001, math.Inf(1), when, uint8(7), struct{ X int }{1}} {
+			g.Add("_:s", "p", o)
+		}
+		g.Add("_:s", "http://example.org/a b", "http://example.org/<x>")
+		nq, err := g.CanonicalNQuads()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nq).To(ContainSubstring(`"1.0E0"^^<http://www.w3.org/2001/XMLSchema#float>`))
+		Expect(nq).To(ContainSubstring(`"1.0E21"^^<http://www.w3.org/2001/XMLSchema#double>`))
+		Expect(nq).To(ContainSubstring(`"1.0E-3"^^<http://www.w3.org/2001/XMLSchema#double>`))
+		Expect(nq).To(ContainSubstring(`"INF"^^<http://www.w3.org/2001/XMLSchema#double>`))
+		Expect(nq).To(ContainSubstring(`"2020-01-02T03:04:05Z"^^<http://www.w3.org/2001/XMLSchema#dateTime>`))
+		Expect(nq).To(ContainSubstring(`"7"^^<http://www.w3.org/2001/XMLSchema#unsignedByte>`))
+		Expect(nq).To(ContainSubstring(`"{1}"`))
+		Expect(nq).To(ContainSubstring(`<http://example.org/a\u0020b> <http://example.org/\u003Cx\u003E>`))
+	})
+})
@@ -0,0 +1,542 @@
+package store4
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Marshal writes the fields of the given struct (or pointer to struct)
+// to the store, as the description of the given subject in the given graph.
+//
+// Only fields with an rdf struct tag are written, the tag giving the
+// field's predicate, optionally followed by a comma-separated list
+// of options:
+//
+//  Name     string    `rdf:"http://xmlns.com/foaf/0.1/name"`
+//  Homepage string    `rdf:"http://xmlns.com/foaf/0.1/homepage,iri"`
+//  Nick     *string   `rdf:"http://xmlns.com/foaf/0.1/nick"`
+//  Knows    []*Person `rdf:"http://xmlns.com/foaf/0.1/knows"`
+//  Age      int       `rdf:"http://xmlns.com/foaf/0.1/age,omitempty"`
+//  ID       string    `rdf:"@id"`
+//
+// Strings are written as Literals, or with the iri option, as IRIs.
+// Numbers, bools and time.Time values are written as Go values, and
+// Literals are written as-is. Nil pointers are omitted, as are zero
+// values with the omitempty option. Each element of a slice or array
+// is written as a separate value of the predicate.
+//
+// Nested structs are written as subjects of their own, linked from the
+// parent: a struct whose @id field is set is written as that subject,
+// and otherwise as a new blank node. Pointers to the same struct are
+// written once, so pointer cycles are allowed.
+//
+// Any existing values of each struct's tagged predicates are replaced,
+// along with the descriptions of any blank nodes among them. Marshal is
+// atomic: if any change is rejected by the BeforeAdd or BeforeRemove
+// callbacks, the changes already made are reverted and the error is
+// returned. An error is also returned if v is not a struct, or holds
+// a value of an unsupported type, such as a map.
+func (s *QuadStore) Marshal(v interface{}, subject, graph string) error {
+	if subject == "*" || graph == "*" {
+		return ErrWildcardTerm
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot marshal Go value of type %T: not a struct", v)
+	}
+	m := &marshaler{store: s, graph: graph, seen: make(map[uintptr]string)}
+	if p := reflect.ValueOf(v); p.Kind() == reflect.Ptr {
+		m.seen[p.Pointer()] = subject
+	}
+	if err := m.marshalStruct(rv, subject); err != nil {
+		return err
+	}
+	return m.write()
+}
+
+// Marshal writes the fields of the given struct to the store,
+// as the description of the given subject in the graph.
+// See QuadStore.Marshal.
+func (g *GraphView) Marshal(v interface{}, subject string) error {
+	return g.QuadStore.Marshal(v, subject, g.Graph)
+}
+
+// Unmarshal reads the description of the view's subject into the given
+// pointer to struct, setting each field with an rdf struct tag from the
+// values of its predicate. See QuadStore.Marshal for the struct tags.
+//
+// Slices receive all of the predicate's values, other fields receive
+// the first value by sort order, and fields without any values are left
+// unchanged. Values are converted to the field's type: Literals and
+// IRIs are parsed into numbers, bools and time.Time values, numbers are
+// converted between numeric types where no precision is lost, and any
+// value can be read into a string (as its lexical form), or into an
+// interface{} field as-is. A field tagged @id receives the subject.
+//
+// Nested structs are read from the subjects linked from the parent.
+// Each subject is read once into pointers to structs, so pointer cycles
+// are recreated, but a subject linked from itself through struct values
+// (rather than pointers) only has its @id field set.
+//
+// An error is returned if v is not a non-nil pointer to struct,
+// or if a value cannot be converted to its field's type.
+func Unmarshal(view *SubjectView, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot unmarshal into Go value of type %T: not a pointer to struct", v)
+	}
+	u := &unmarshaler{
+		store:      view.QuadStore,
+		graph:      view.Graph,
+		pointers:   map[string]reflect.Value{view.Subject: rv},
+		inProgress: make(map[string]bool),
+	}
+	return u.unmarshalStruct(rv.Elem(), view.Subject)
+}
+
+// rdfField describes a struct field with an rdf struct tag.
+type rdfField struct {
+	name      string
+	index     []int
+	predicate string
+	iri       bool
+	omitEmpty bool
+}
+
+// rdfFields returns the tagged fields of the given struct type,
+// including those of embedded structs without tags.
+// The field tagged @id, if any, is returned separately.
+func rdfFields(t reflect.Type) (fields []rdfField, id []int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("rdf")
+		if !ok {
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				embedded, eid := rdfFields(f.Type)
+				for _, e := range embedded {
+					e.index = append([]int{i}, e.index...)
+					fields = append(fields, e)
+				}
+				if eid != nil && id == nil {
+					id = append([]int{i}, eid...)
+				}
+			}
+			continue
+		}
+		if tag == "-" || f.PkgPath != "" {
+			// Skipped, or unexported.
+			continue
+		}
+		if tag == "@id" {
+			id = []int{i}
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		field := rdfField{name: f.Name, index: []int{i}, predicate: parts[0]}
+		for _, opt := range parts[1:] {
+			switch opt {
+			case "iri":
+				field.iri = true
+			case "omitempty":
+				field.omitEmpty = true
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields, id
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	literalType = reflect.TypeOf(Literal{})
+)
+
+// isNestedStruct returns true if values of the given type
+// are marshaled as subjects of their own.
+func isNestedStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && t != literalType
+}
+
+// marshaler collects the quads describing a struct.
+type marshaler struct {
+	store *QuadStore
+	graph string
+	quads []quad
+	// replaced holds the predicates of each subject whose
+	// existing values are replaced.
+	replaced []quad
+	// seen maps pointers to structs to the subjects written for them.
+	seen map[uintptr]string
+}
+
+// marshalStruct collects the quads describing the given struct value.
+func (m *marshaler) marshalStruct(rv reflect.Value, subject string) error {
+	fields, _ := rdfFields(rv.Type())
+	for _, f := range fields {
+		fv := rv.FieldByIndex(f.index)
+		m.replaced = append(m.replaced, quad{subject, f.predicate, nil, m.graph})
+		if f.omitEmpty && fv.IsZero() {
+			continue
+		}
+		if err := m.marshalValue(fv, subject, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshalValue collects the quads linking the given subject to the
+// given field value, which may hold any number of objects.
+func (m *marshaler) marshalValue(fv reflect.Value, subject string, f rdfField) error {
+	switch fv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if fv.IsNil() {
+			return nil
+		}
+		if fv.Kind() == reflect.Ptr && isNestedStruct(fv.Elem().Type()) {
+			o, ok := m.seen[fv.Pointer()]
+			if !ok {
+				o = m.nestedSubject(fv.Elem())
+				m.seen[fv.Pointer()] = o
+				if err := m.marshalStruct(fv.Elem(), o); err != nil {
+					return err
+				}
+			}
+			m.quads = append(m.quads, quad{subject, f.predicate, o, m.graph})
+			return nil
+		}
+		return m.marshalValue(fv.Elem(), subject, f)
+	case reflect.Slice, reflect.Array:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		for i := 0; i < fv.Len(); i++ {
+			if err := m.marshalValue(fv.Index(i), subject, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+		if !isNestedStruct(fv.Type()) {
+			m.quads = append(m.quads, quad{subject, f.predicate, fv.Interface(), m.graph})
+			return nil
+		}
+		o := m.nestedSubject(fv)
+		if err := m.marshalStruct(fv, o); err != nil {
+			return err
+		}
+		m.quads = append(m.quads, quad{subject, f.predicate, o, m.graph})
+		return nil
+	case reflect.String:
+		if f.iri {
+			m.quads = append(m.quads, quad{subject, f.predicate, fv.String(), m.graph})
+		} else {
+			m.quads = append(m.quads, quad{subject, f.predicate, Literal{Value: fv.String()}, m.graph})
+		}
+		return nil
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		// Named types are written as their underlying basic type.
+		o := fv.Convert(basicTypes[fv.Kind()]).Interface()
+		m.quads = append(m.quads, quad{subject, f.predicate, o, m.graph})
+		return nil
+	}
+	return fmt.Errorf("cannot marshal Go value of type %s (field %s)", fv.Type(), f.name)
+}
+
+// basicTypes maps kinds to their basic types.
+var basicTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:    reflect.TypeOf(false),
+	reflect.Int:     reflect.TypeOf(int(0)),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+}
+
+// nestedSubject returns the subject for the given nested struct value:
+// the value of its @id field, if set, or else a new blank node.
+func (m *marshaler) nestedSubject(rv reflect.Value) string {
+	if _, id := rdfFields(rv.Type()); id != nil {
+		if idv := rv.FieldByIndex(id); idv.Kind() == reflect.String && idv.String() != "" {
+			return idv.String()
+		}
+	}
+	return m.store.newBlankNode()
+}
+
+// write replaces the existing values of the marshaled predicates
+// with the collected quads, reverting all changes on error.
+func (m *marshaler) write() error {
+	s := m.store
+	defer s.beginBatch(len(m.quads))()
+	s.BeginGroup()
+	defer s.EndGroup()
+
+	keep := make(map[quad]struct{}, len(m.quads))
+	for _, q := range m.quads {
+		keep[q] = struct{}{}
+	}
+	var old []quad
+	for _, r := range m.replaced {
+		s.ForEachWith(r.s, r.p, "*", r.g, func(s1, p1 string, o1 interface{}, g1 string) {
+			old = append(old, quad{s1, p1, o1, g1})
+			if isBlankNode(o1) {
+				old = append(old, s.describe(o1, g1, false)...)
+			}
+		})
+	}
+	var applied []Change
+	for _, q := range old {
+		if _, ok := keep[q]; ok {
+			continue
+		}
+		n, err := s.TryRemove(q.s, q.p, q.o, q.g)
+		if err != nil {
+			s.revert(applied)
+			return err
+		}
+		if n > 0 {
+			applied = append(applied, Change{Removed, q.s, q.p, q.o, q.g})
+		}
+	}
+	for _, q := range m.quads {
+		ok, err := s.TryAdd(q.s, q.p, q.o, q.g)
+		if err != nil {
+			s.revert(applied)
+			return err
+		}
+		if ok {
+			applied = append(applied, Change{Added, q.s, q.p, q.o, q.g})
+		}
+	}
+	return nil
+}
+
+// newBlankNode returns a blank node label not yet used in the store.
+func (s *QuadStore) newBlankNode() string {
+	for {
+		label := "_:b" + strconv.FormatUint(s.nextBlankNode, 10)
+		s.nextBlankNode++
+		if _, ok := s.pool.stringToID(label); !ok {
+			return label
+		}
+	}
+}
+
+// unmarshaler reads struct values from the descriptions of subjects.
+type unmarshaler struct {
+	store *QuadStore
+	graph string
+	// pointers maps subjects to the pointers to structs read from them.
+	pointers map[string]reflect.Value
+	// inProgress holds the subjects being read into struct values.
+	inProgress map[string]bool
+}
+
+// unmarshalStruct reads the description of the given subject
+// into the given struct value.
+func (u *unmarshaler) unmarshalStruct(rv reflect.Value, subject string) error {
+	fields, id := rdfFields(rv.Type())
+	if id != nil {
+		if idv := rv.FieldByIndex(id); idv.Kind() == reflect.String {
+			idv.SetString(subject)
+		}
+	}
+	if u.inProgress[subject] {
+		return nil
+	}
+	u.inProgress[subject] = true
+	defer delete(u.inProgress, subject)
+	for _, f := range fields {
+		objects := u.store.FindObjects(subject, f.predicate, u.graph)
+		if len(objects) == 0 {
+			continue
+		}
+		sortObjects(objects)
+		fv := rv.FieldByIndex(f.index)
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+			out := reflect.MakeSlice(fv.Type(), 0, len(objects))
+			for _, o := range objects {
+				ov, err := u.convert(o, fv.Type().Elem(), f)
+				if err != nil {
+					return err
+				}
+				out = reflect.Append(out, ov)
+			}
+			fv.Set(out)
+			continue
+		}
+		ov, err := u.convert(objects[0], fv.Type(), f)
+		if err != nil {
+			return err
+		}
+		fv.Set(ov)
+	}
+	return nil
+}
+
+// convert returns the given object as a value of the given type.
+func (u *unmarshaler) convert(o interface{}, t reflect.Type, f rdfField) (reflect.Value, error) {
+	fail := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("cannot unmarshal %v into Go value of type %s (field %s)", formatNQuadsTerm(o), t, f.name)
+	}
+	if t.Kind() == reflect.Ptr {
+		if subject, ok := o.(string); ok && isNestedStruct(t.Elem()) {
+			if p, ok := u.pointers[subject]; ok && p.Type() == t {
+				return p, nil
+			}
+			p := reflect.New(t.Elem())
+			u.pointers[subject] = p
+			return p, u.unmarshalStruct(p.Elem(), subject)
+		}
+		ev, err := u.convert(o, t.Elem(), f)
+		if err != nil {
+			return ev, err
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(ev)
+		return p, nil
+	}
+	ov := reflect.ValueOf(o)
+	if ov.Type() == t || t.Kind() == reflect.Interface && ov.Type().Implements(t) {
+		return ov, nil
+	}
+	if isNestedStruct(t) {
+		subject, ok := o.(string)
+		if !ok {
+			return fail()
+		}
+		v := reflect.New(t).Elem()
+		return v, u.unmarshalStruct(v, subject)
+	}
+	// Literals are converted to the Go values of their datatypes,
+	// and IRIs and plain literals are parsed as their lexical forms.
+	if l, ok := o.(Literal); ok {
+		o = literalValue(l.Value, l.Datatype, l.Language)
+		ov = reflect.ValueOf(o)
+	}
+	text, isText := o.(string)
+	if l, ok := o.(Literal); ok {
+		text, isText = l.Value, true
+	}
+	v := reflect.New(t).Elem()
+	switch {
+	case t == timeType:
+		if isText {
+			tm, err := time.Parse(time.RFC3339Nano, text)
+			if err != nil {
+				return fail()
+			}
+			o = tm
+		}
+		tm, ok := o.(time.Time)
+		if !ok {
+			return fail()
+		}
+		v.Set(reflect.ValueOf(tm))
+	case t.Kind() == reflect.String:
+		v.SetString(lexicalForm(o))
+	case t.Kind() == reflect.Bool:
+		b, ok := o.(bool)
+		if isText {
+			var err error
+			if b, err = strconv.ParseBool(text); err != nil {
+				return fail()
+			}
+		} else if !ok {
+			return fail()
+		}
+		v.SetBool(b)
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		var i int64
+		switch {
+		case isText:
+			n, err := strconv.ParseInt(text, 10, 64)
+			if err != nil {
+				return fail()
+			}
+			i = n
+		case ov.Kind() >= reflect.Int && ov.Kind() <= reflect.Int64:
+			i = ov.Int()
+		case ov.Kind() >= reflect.Uint && ov.Kind() <= reflect.Uint64:
+			if ov.Uint() > 1<<63-1 {
+				return fail()
+			}
+			i = int64(ov.Uint())
+		case ov.Kind() == reflect.Float32 || ov.Kind() == reflect.Float64:
+			fl := ov.Float()
+			if fl != float64(int64(fl)) {
+				return fail()
+			}
+			i = int64(fl)
+		default:
+			return fail()
+		}
+		if v.OverflowInt(i) {
+			return fail()
+		}
+		v.SetInt(i)
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		var i uint64
+		switch {
+		case isText:
+			n, err := strconv.ParseUint(text, 10, 64)
+			if err != nil {
+				return fail()
+			}
+			i = n
+		case ov.Kind() >= reflect.Int && ov.Kind() <= reflect.Int64:
+			if ov.Int() < 0 {
+				return fail()
+			}
+			i = uint64(ov.Int())
+		case ov.Kind() >= reflect.Uint && ov.Kind() <= reflect.Uint64:
+			i = ov.Uint()
+		case ov.Kind() == reflect.Float32 || ov.Kind() == reflect.Float64:
+			fl := ov.Float()
+			if fl < 0 || fl != float64(uint64(fl)) {
+				return fail()
+			}
+			i = uint64(fl)
+		default:
+			return fail()
+		}
+		if v.OverflowUint(i) {
+			return fail()
+		}
+		v.SetUint(i)
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		var fl float64
+		if isText {
+			n, err := parseXSDFloat(text, t.Bits())
+			if err != nil {
+				return fail()
+			}
+			fl = n
+		} else if n, ok := toFloat64(o); ok {
+			fl = n
+		} else {
+			return fail()
+		}
+		v.SetFloat(fl)
+	default:
+		return fail()
+	}
+	return v, nil
+}
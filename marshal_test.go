@@ -0,0 +1,225 @@
+package store4_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type Address struct {
+	City    string `rdf:"city"`
+	Country string `rdf:"country,omitempty"`
+}
+
+type Person struct {
+	ID       string    `rdf:"@id"`
+	Name     string    `rdf:"name"`
+	Homepage string    `rdf:"homepage,iri,omitempty"`
+	Age      int       `rdf:"age"`
+	Height   float64   `rdf:"height,omitempty"`
+	Born     time.Time `rdf:"born,omitempty"`
+	Nick     *string   `rdf:"nick"`
+	Emails   []string  `rdf:"email"`
+	Address  *Address  `rdf:"address"`
+	Knows    []*Person `rdf:"knows"`
+	Notes    string
+	Ignored  string `rdf:"-"`
+}
+
+var _ = Describe("Marshal", func() {
+
+	var store *QuadStore
+	born := time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		store = NewQuadStore()
+	})
+
+	Describe("Marshal", func() {
+
+		It("should write tagged fields as quads", func() {
+			nick := "Al"
+			err := store.Marshal(&Person{
+				Name:     "Alice",
+				Homepage: "http://example.org/alice",
+				Age:      42,
+				Born:     born,
+				Nick:     &nick,
+				Emails:   []string{"a@example.org", "alice@example.org"},
+				Notes:    "not written",
+				Ignored:  "not written",
+			}, "alice", "")
+			Expect(err).To(BeNil())
+			Expect(iterResults(store)).To(ConsistOf([]*Quad{
+				{"alice", "name", Literal{Value: "Alice"}, ""},
+				{"alice", "homepage", "http://example.org/alice", ""},
+				{"alice", "age", 42, ""},
+				{"alice", "born", born, ""},
+				{"alice", "nick", Literal{Value: "Al"}, ""},
+				{"alice", "email", Literal{Value: "a@example.org"}, ""},
+				{"alice", "email", Literal{Value: "alice@example.org"}, ""},
+			}))
+		})
+
+		It("should write nested structs as blank nodes", func() {
+			err := store.Marshal(Person{Name: "Alice", Address: &Address{City: "Paris"}}, "alice", "g")
+			Expect(err).To(BeNil())
+			addr := store.FindObjects("alice", "address", "g")
+			Expect(addr).To(HaveLen(1))
+			Expect(addr[0]).To(HavePrefix("_:"))
+			Expect(store.Count(addr[0].(string), "city", Literal{Value: "Paris"}, "g")).To(BeEquivalentTo(1))
+		})
+
+		It("should write nested structs with IDs as linked subjects", func() {
+			bob := &Person{ID: "bob", Name: "Bob"}
+			alice := &Person{Name: "Alice", Knows: []*Person{bob}}
+			bob.Knows = []*Person{alice}
+			Expect(store.Marshal(alice, "alice", "")).To(BeNil())
+			Expect(store.Count("alice", "knows", "bob", "")).To(BeEquivalentTo(1))
+			Expect(store.Count("bob", "knows", "alice", "")).To(BeEquivalentTo(1))
+			Expect(store.Count("bob", "name", Literal{Value: "Bob"}, "")).To(BeEquivalentTo(1))
+		})
+
+		It("should replace existing values", func() {
+			Expect(store.Marshal(Person{Name: "Alice", Age: 41, Address: &Address{City: "Paris"}}, "alice", "")).To(BeNil())
+			store.Add("alice", "other", "kept", "")
+			Expect(store.Marshal(Person{Name: "Alice", Age: 42, Address: &Address{City: "Rome"}}, "alice", "")).To(BeNil())
+			Expect(store.FindObjects("alice", "age", "")).To(Equal([]interface{}{42}))
+			Expect(store.Count("*", "city", Literal{Value: "Paris"}, "")).To(BeEquivalentTo(0))
+			Expect(store.Count("*", "city", Literal{Value: "Rome"}, "")).To(BeEquivalentTo(1))
+			Expect(store.Count("alice", "other", "kept", "")).To(BeEquivalentTo(1))
+		})
+
+		It("should not change anything when nothing changed", func() {
+			Expect(store.Marshal(Person{Name: "Alice", Age: 42}, "alice", "")).To(BeNil())
+			var changes int
+			store.OnAdd = func(s, p string, o interface{}, g string) { changes++ }
+			store.OnRemove = func(s, p string, o interface{}, g string) { changes++ }
+			Expect(store.Marshal(Person{Name: "Alice", Age: 42}, "alice", "")).To(BeNil())
+			Expect(changes).To(Equal(0))
+		})
+
+		It("should revert all changes when one is rejected", func() {
+			Expect(store.Marshal(Person{Name: "Alice", Age: 41}, "alice", "")).To(BeNil())
+			rejected := errors.New("rejected")
+			store.BeforeAdd = func(s, p string, o interface{}, g string) error {
+				if p == "age" {
+					return rejected
+				}
+				return nil
+			}
+			err := store.Marshal(Person{Name: "Alicia", Age: 42}, "alice", "")
+			Expect(err).To(Equal(rejected))
+			Expect(store.FindObjects("alice", "name", "")).To(Equal([]interface{}{Literal{Value: "Alice"}}))
+			Expect(store.FindObjects("alice", "age", "")).To(Equal([]interface{}{41}))
+		})
+
+		It("should return an error for unsupported values", func() {
+			err := store.Marshal(struct {
+				M map[string]int `rdf:"m"`
+			}{}, "x", "")
+			Expect(err).To(MatchError("cannot marshal Go value of type map[string]int (field M)"))
+			Expect(store.Marshal(42, "x", "")).To(MatchError("cannot marshal Go value of type int: not a struct"))
+			Expect(store.Marshal(Person{}, "*", "")).To(Equal(ErrWildcardTerm))
+			Expect(store.Size()).To(BeEquivalentTo(0))
+		})
+	})
+
+	Describe("Unmarshal", func() {
+
+		It("should read a marshaled struct", func() {
+			nick := "Al"
+			in := &Person{
+				Name:    "Alice",
+				Age:     42,
+				Height:  1.7,
+				Born:    born,
+				Nick:    &nick,
+				Emails:  []string{"b@example.org", "a@example.org"},
+				Address: &Address{City: "Paris", Country: "FR"},
+			}
+			Expect(store.Marshal(in, "alice", "")).To(BeNil())
+			var out Person
+			Expect(Unmarshal(store.SubjectView("alice", ""), &out)).To(BeNil())
+			Expect(out.ID).To(Equal("alice"))
+			Expect(out.Name).To(Equal("Alice"))
+			Expect(out.Age).To(Equal(42))
+			Expect(out.Height).To(Equal(1.7))
+			Expect(out.Born.Equal(born)).To(BeTrue())
+			Expect(*out.Nick).To(Equal("Al"))
+			Expect(out.Emails).To(Equal([]string{"a@example.org", "b@example.org"}))
+			Expect(*out.Address).To(Equal(Address{City: "Paris", Country: "FR"}))
+			Expect(out.Knows).To(BeNil())
+		})
+
+		It("should recreate pointer cycles", func() {
+			bob := &Person{ID: "bob", Name: "Bob"}
+			alice := &Person{Name: "Alice", Knows: []*Person{bob}}
+			bob.Knows = []*Person{alice}
+			Expect(store.Marshal(alice, "alice", "")).To(BeNil())
+			var out Person
+			Expect(Unmarshal(store.SubjectView("alice", ""), &out)).To(BeNil())
+			Expect(out.Knows).To(HaveLen(1))
+			Expect(out.Knows[0].Name).To(Equal("Bob"))
+			Expect(out.Knows[0].Knows[0]).To(BeIdenticalTo(&out))
+		})
+
+		It("should convert values to field types", func() {
+			store.Add("x", "age", Literal{Value: "42", Datatype: XSDNamespace + "integer"}, "")
+			store.Add("x", "height", "1.5", "")
+			store.Add("x", "born", Literal{Value: "1990-05-17T00:00:00Z"}, "")
+			store.Add("x", "name", 7, "")
+			var out struct {
+				Age    uint8       `rdf:"age"`
+				Height float32     `rdf:"height"`
+				Born   *time.Time  `rdf:"born"`
+				Name   string      `rdf:"name"`
+				Any    interface{} `rdf:"age"`
+			}
+			Expect(Unmarshal(store.SubjectView("x", ""), &out)).To(BeNil())
+			Expect(out.Age).To(BeEquivalentTo(42))
+			Expect(out.Height).To(BeEquivalentTo(1.5))
+			Expect(out.Born.Equal(born)).To(BeTrue())
+			Expect(out.Name).To(Equal("7"))
+			Expect(out.Any).To(Equal(Literal{Value: "42", Datatype: XSDNamespace + "integer"}))
+		})
+
+		It("should convert between numeric types", func() {
+			store.Add("x", "n", 3.0, "")
+			store.Add("y", "n", 3.5, "")
+			store.Add("z", "n", 300, "")
+			var out struct {
+				N int8 `rdf:"n"`
+			}
+			Expect(Unmarshal(store.SubjectView("x", ""), &out)).To(BeNil())
+			Expect(out.N).To(BeEquivalentTo(3))
+			Expect(Unmarshal(store.SubjectView("y", ""), &out)).NotTo(BeNil())
+			Expect(Unmarshal(store.SubjectView("z", ""), &out)).NotTo(BeNil())
+		})
+
+		It("should leave fields without values unchanged", func() {
+			out := Person{Name: "Unchanged", Age: 7}
+			store.Add("x", "age", 8, "")
+			Expect(Unmarshal(store.SubjectView("x", ""), &out)).To(BeNil())
+			Expect(out.Name).To(Equal("Unchanged"))
+			Expect(out.Age).To(Equal(8))
+		})
+
+		It("should return an error for values that cannot be converted", func() {
+			store.Add("x", "age", "old", "")
+			var out Person
+			err := Unmarshal(store.SubjectView("x", ""), &out)
+			Expect(err).To(MatchError(`cannot unmarshal <old> into Go value of type int (field Age)`))
+		})
+
+		It("should return an error when not given a pointer to struct", func() {
+			var out Person
+			Expect(Unmarshal(store.SubjectView("x", ""), out)).To(MatchError("cannot unmarshal into Go value of type store4_test.Person: not a pointer to struct"))
+			Expect(Unmarshal(store.SubjectView("x", ""), (*Person)(nil))).NotTo(BeNil())
+		})
+	})
+})
@@ -0,0 +1,280 @@
+package store4
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// LinkCallbackFn is the function signature used to implement
+// callback functions that receive a subject-predicate link.
+//
+// Used with calls to ObjectView's ForEach and ForEachWith.
+type LinkCallbackFn func(s, p string)
+
+// LinkTestFn is the function signature used to implement
+// callback functions performing subject-predicate link tests.
+// A response of true means that the test has been passed.
+//
+// Used with calls to ObjectView's Every, EveryWith, Some and SomeWith.
+type LinkTestFn func(s, p string) bool
+
+// ObjectView provides an object-centric API for working with
+// subject-predicate links (backlinks) to an object, which may be
+// a string or a non-string term.
+//
+// ObjectView is a convenience façade that simply
+// proxies calls to its associated QuadStore, whose
+// OSP index serves lookups by object.
+//
+// Returned by calls to ObjectView and ObjectViews on
+// both QuadStore and GraphView, and to SubjectView's Incoming.
+type ObjectView struct {
+	Object    interface{}
+	Graph     string
+	QuadStore *QuadStore
+}
+
+// ObjectView returns an ObjectView for the given object and graph.
+func (s *QuadStore) ObjectView(object interface{}, graph string) *ObjectView {
+	v := &ObjectView{
+		Object:    object,
+		Graph:     graph,
+		QuadStore: s,
+	}
+	return v
+}
+
+// ObjectViews returns a list of ObjectViews for objects that
+// match the given pattern.
+//
+// Passing "*" (an asterisk) for any parameter acts as a
+// match-everything wildcard for that term.
+func (s *QuadStore) ObjectViews(subject, predicate, graph string) []*ObjectView {
+	var out []*ObjectView
+	s.ForObjects(subject, predicate, graph, func(object interface{}) {
+		v := &ObjectView{
+			Object:    object,
+			Graph:     graph,
+			QuadStore: s,
+		}
+		out = append(out, v)
+	})
+	return out
+}
+
+// ObjectView returns an ObjectView for the given object.
+func (g *GraphView) ObjectView(object interface{}) *ObjectView {
+	return g.QuadStore.ObjectView(object, g.Graph)
+}
+
+// ObjectViews returns a list of ObjectViews for objects that
+// match the given pattern.
+//
+// Passing "*" (an asterisk) for any parameter acts as a
+// match-everything wildcard for that term.
+func (g *GraphView) ObjectViews(subject, predicate string) []*ObjectView {
+	return g.QuadStore.ObjectViews(subject, predicate, g.Graph)
+}
+
+// Incoming returns an ObjectView of the links to the SubjectView's subject.
+func (v *SubjectView) Incoming() *ObjectView {
+	return v.QuadStore.ObjectView(v.Subject, v.Graph)
+}
+
+// Map returns a map containing the predicate terms linking to
+// the ObjectView's object, mapped to their corresponding subject terms.
+func (v *ObjectView) Map() map[string][]string {
+	m := make(map[string][]string)
+	v.ForPredicates("*", func(predicate string) {
+		m[predicate] = v.FindSubjects(predicate)
+	})
+	return m
+}
+
+// Add a quad to the underlying QuadStore,
+// with the given subject and predicate values
+// and this ObjectView's Object and Graph values.
+// Returns true if the quad was a new quad,
+// or false if the quad already existed.
+//
+// If any of the given terms are "*" (an asterisk),
+// then this method will panic. (The asterisk is reserved
+// for wildcard operations throughout the API).
+func (v *ObjectView) Add(subject, predicate string) bool {
+	return v.QuadStore.Add(subject, predicate, v.Object, v.Graph)
+}
+
+// TryAdd adds a quad to the underlying QuadStore,
+// with the given subject and predicate values
+// and this ObjectView's Object and Graph values.
+// Returns true if the quad was a new quad,
+// or false if the quad already existed.
+//
+// Unlike Add, TryAdd does not panic, see QuadStore.TryAdd.
+func (v *ObjectView) TryAdd(subject, predicate string) (bool, error) {
+	return v.QuadStore.TryAdd(subject, predicate, v.Object, v.Graph)
+}
+
+// Count returns a count of links in the ObjectView that match the given pattern.
+//
+// Passing "*" (an asterisk) for any parameter acts as a
+// match-everything wildcard for that term.
+func (v *ObjectView) Count(subject, predicate string) uint64 {
+	return v.QuadStore.Count(subject, predicate, v.Object, v.Graph)
+}
+
+// Empty returns true if the ObjectView has no contents.
+func (v *ObjectView) Empty() bool {
+	haltFn := func(s, p string, o interface{}, g string) bool {
+		return true
+	}
+	return !v.QuadStore.SomeWith("*", "*", v.Object, v.Graph, haltFn)
+}
+
+// Every tests whether all links in the ObjectView pass the test
+// implemented by the given function.
+//
+// As with SubjectView's Every, if the ObjectView is empty,
+// then Every returns false.
+func (v *ObjectView) Every(fn LinkTestFn) bool {
+	return v.QuadStore.EveryWith("*", "*", v.Object, v.Graph, adaptLinkTestFn(fn))
+}
+
+// EveryWith tests whether all links in the ObjectView that match the
+// given terms pass the test implemented by the given function.
+//
+// As with SubjectView's EveryWith, if no links match the given terms,
+// then EveryWith returns false.
+func (v *ObjectView) EveryWith(subject, predicate string, fn LinkTestFn) bool {
+	return v.QuadStore.EveryWith(subject, predicate, v.Object, v.Graph, adaptLinkTestFn(fn))
+}
+
+// FindSubjects returns a list of distinct subject terms for all
+// links in the ObjectView that match the given pattern.
+//
+// Passing "*" (an asterisk) for any parameter acts as a
+// match-everything wildcard for that term.
+func (v *ObjectView) FindSubjects(predicate string) []string {
+	return v.QuadStore.FindSubjects(predicate, v.Object, v.Graph)
+}
+
+// FindPredicates returns a list of distinct predicate terms for all
+// links in the ObjectView that match the given pattern.
+//
+// Passing "*" (an asterisk) for any parameter acts as a
+// match-everything wildcard for that term.
+func (v *ObjectView) FindPredicates(subject string) []string {
+	return v.QuadStore.FindPredicates(subject, v.Object, v.Graph)
+}
+
+// ForEach executes the given callback once for each link in the ObjectView.
+func (v *ObjectView) ForEach(fn LinkCallbackFn) {
+	v.QuadStore.ForEachWith("*", "*", v.Object, v.Graph, adaptLinkCallbackFn(fn))
+}
+
+// ForEachWith executes the given callback once for each link in the ObjectView
+// that matches the given pattern.
+//
+// Passing "*" (an asterisk) for any parameter acts as a
+// match-everything wildcard for that term.
+func (v *ObjectView) ForEachWith(subject, predicate string, fn LinkCallbackFn) {
+	v.QuadStore.ForEachWith(subject, predicate, v.Object, v.Graph, adaptLinkCallbackFn(fn))
+}
+
+// ForSubjects executes the given callback once for each distinct subject term
+// for all links in the ObjectView that match the given pattern.
+//
+// Passing "*" (an asterisk) for any parameter acts as a
+// match-everything wildcard for that term.
+func (v *ObjectView) ForSubjects(predicate string, fn StringCallbackFn) {
+	v.QuadStore.ForSubjects(predicate, v.Object, v.Graph, fn)
+}
+
+// ForPredicates executes the given callback once for each distinct predicate term
+// for all links in the ObjectView that match the given pattern.
+//
+// Passing "*" (an asterisk) for any parameter acts as a
+// match-everything wildcard for that term.
+func (v *ObjectView) ForPredicates(subject string, fn StringCallbackFn) {
+	v.QuadStore.ForPredicates(subject, v.Object, v.Graph, fn)
+}
+
+// Remove quads from the underlying QuadStore,
+// with the given subject and predicate values
+// and this ObjectView's Object and Graph values.
+// Returns the number of quads removed.
+//
+// Passing "*" (an asterisk) for any parameter acts as a
+// match-everything wildcard for that term.
+func (v *ObjectView) Remove(subject, predicate string) uint64 {
+	return v.QuadStore.Remove(subject, predicate, v.Object, v.Graph)
+}
+
+// TryRemove removes quads from the underlying QuadStore,
+// with the given subject and predicate values
+// and this ObjectView's Object and Graph values.
+// Returns the number of quads removed.
+//
+// Unlike Remove, TryRemove does not panic, see QuadStore.TryRemove.
+func (v *ObjectView) TryRemove(subject, predicate string) (uint64, error) {
+	return v.QuadStore.TryRemove(subject, predicate, v.Object, v.Graph)
+}
+
+// Size returns the total count of links in the ObjectView.
+func (v *ObjectView) Size() uint64 {
+	return v.QuadStore.Count("*", "*", v.Object, v.Graph)
+}
+
+// Some tests whether some link in the ObjectView passes the test
+// implemented by the given function, halting iteration
+// as soon as the callback returns true.
+func (v *ObjectView) Some(fn LinkTestFn) bool {
+	return v.QuadStore.SomeWith("*", "*", v.Object, v.Graph, adaptLinkTestFn(fn))
+}
+
+// SomeWith tests whether some link matching the given pattern
+// passes the test implemented by the given function, halting
+// iteration as soon as the callback returns true.
+func (v *ObjectView) SomeWith(subject, predicate string, fn LinkTestFn) bool {
+	return v.QuadStore.SomeWith(subject, predicate, v.Object, v.Graph, adaptLinkTestFn(fn))
+}
+
+// String returns the contents of the ObjectView in a human-readable format.
+func (v *ObjectView) String() string {
+	var buf bytes.Buffer
+	graph := v.Graph
+	if len(graph) > 0 {
+		buf.WriteString(graph)
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(fmt.Sprint(v.Object))
+	buf.WriteByte('\n')
+	predicates := v.FindPredicates("*")
+	sort.Strings(predicates)
+	for _, predicate := range predicates {
+		subjects := v.FindSubjects(predicate)
+		sort.Strings(subjects)
+		for _, subject := range subjects {
+			buf.WriteByte('[')
+			buf.WriteString(subject)
+			buf.WriteByte(' ')
+			buf.WriteString(predicate)
+			buf.WriteByte(']')
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.String()
+}
+
+func adaptLinkCallbackFn(fn LinkCallbackFn) QuadCallbackFn {
+	return func(s, p string, o interface{}, g string) {
+		fn(s, p)
+	}
+}
+
+func adaptLinkTestFn(fn LinkTestFn) QuadTestFn {
+	return func(s, p string, o interface{}, g string) bool {
+		return fn(s, p)
+	}
+}
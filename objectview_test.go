@@ -0,0 +1,150 @@
+package store4_test
+
+import (
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type Link struct {
+	S string
+	P string
+}
+
+var _ = Describe("ObjectView", func() {
+
+	var store *QuadStore
+
+	BeforeEach(func() {
+		store = NewQuadStore([][4]string{
+			{"s1", "p1", "o1", ""},
+			{"s2", "p1", "o1", ""},
+			{"s2", "p2", "o1", ""},
+			{"o1", "p1", "o2", ""},
+			{"s3", "p2", "o1", "c4"},
+		})
+		store.Add("s1", "age", 42, "")
+		store.Add("s2", "age", 42, "c4")
+	})
+
+	Describe("Creating a single ObjectView from a QuadStore", func() {
+
+		Context("with a string object and wildcard graph", func() {
+
+			var view *ObjectView
+
+			BeforeEach(func() {
+				view = store.ObjectView("o1", "*")
+			})
+
+			It("should have size 4", func() {
+				Expect(view.Size()).To(Equal(uint64(4)))
+			})
+
+			It("should contain the correct predicates", func() {
+				Expect(view.FindPredicates("*")).To(ConsistOf([]string{"p1", "p2"}))
+			})
+
+			It("should contain the correct subjects for predicate p2", func() {
+				Expect(view.FindSubjects("p2")).To(ConsistOf([]string{"s2", "s3"}))
+			})
+
+			It("should map predicates to subjects", func() {
+				m := view.Map()
+				Expect(m).To(HaveLen(2))
+				Expect(m["p1"]).To(ConsistOf("s1", "s2"))
+				Expect(m["p2"]).To(ConsistOf("s2", "s3"))
+			})
+
+			It("should not contain outgoing links", func() {
+				Expect(view.Count("o1", "*")).To(BeEquivalentTo(0))
+			})
+		})
+
+		Context("with a non-string object", func() {
+
+			It("should contain the correct links", func() {
+				view := store.ObjectView(42, "")
+				var results []Link
+				view.ForEach(func(s, p string) {
+					results = append(results, Link{s, p})
+				})
+				Expect(results).To(Equal([]Link{{"s1", "age"}}))
+				Expect(store.ObjectView(42, "*").FindSubjects("age")).To(ConsistOf("s1", "s2"))
+			})
+		})
+
+		Context("with a missing object", func() {
+
+			It("should be empty", func() {
+				view := store.ObjectView("nothing", "")
+				Expect(view.Empty()).To(BeTrue())
+				Expect(view.Size()).To(BeEquivalentTo(0))
+				Expect(view.Map()).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Creating ObjectViews", func() {
+
+		It("should return a view for each matching object", func() {
+			views := store.ObjectViews("s2", "*", "*")
+			var objects []interface{}
+			for _, v := range views {
+				objects = append(objects, v.Object)
+			}
+			Expect(objects).To(ConsistOf("o1", 42))
+		})
+
+		It("should be available from a GraphView", func() {
+			g := store.GraphView("c4")
+			Expect(g.ObjectView("o1").FindSubjects("*")).To(Equal([]string{"s3"}))
+			Expect(g.ObjectViews("s2", "age")).To(HaveLen(1))
+		})
+
+		It("should be available from a SubjectView", func() {
+			view := store.SubjectView("o1", "").Incoming()
+			Expect(view.Object).To(Equal("o1"))
+			Expect(view.Size()).To(BeEquivalentTo(3))
+		})
+	})
+
+	Describe("Modifying via an ObjectView", func() {
+
+		It("should add links to the object", func() {
+			view := store.ObjectView(42, "")
+			Expect(view.Add("s3", "age")).To(BeTrue())
+			Expect(store.Count("s3", "age", 42, "")).To(BeEquivalentTo(1))
+		})
+
+		It("should remove matching links", func() {
+			view := store.ObjectView("o1", "")
+			Expect(view.Remove("*", "p1")).To(BeEquivalentTo(2))
+			Expect(view.Size()).To(BeEquivalentTo(1))
+			Expect(store.Count("o1", "p1", "o2", "")).To(BeEquivalentTo(1))
+		})
+
+		It("should remove links to non-string objects", func() {
+			Expect(store.ObjectView(42, "*").Remove("*", "*")).To(BeEquivalentTo(2))
+			Expect(store.Count("*", "age", "*", "*")).To(BeEquivalentTo(0))
+		})
+	})
+
+	Describe("Testing links", func() {
+
+		It("should support Every and Some", func() {
+			view := store.ObjectView("o1", "")
+			Expect(view.Every(func(s, p string) bool { return s != "s3" })).To(BeTrue())
+			Expect(view.Some(func(s, p string) bool { return p == "p2" })).To(BeTrue())
+			Expect(view.SomeWith("s1", "p2", func(s, p string) bool { return true })).To(BeFalse())
+		})
+	})
+
+	Describe("String", func() {
+
+		It("should list links in order", func() {
+			Expect(store.ObjectView("o1", "").String()).To(Equal("o1\n[s1 p1]\n[s2 p1]\n[s2 p2]\n"))
+		})
+	})
+})
@@ -0,0 +1,183 @@
+package store4
+
+import (
+	"fmt"
+	"strings"
+)
+
+// owlRules holds the OWL 2 RL rules implemented by OWLReasoner,
+// named as in the OWL 2 RL specification.
+const owlRules = `
+@prefix rdf: <http://www.w3.org/1999/02/22-rdf-syntax-ns#> .
+@prefix owl: <http://www.w3.org/2002/07/owl#> .
+[eq-sym: (?x owl:sameAs ?y) -> (?y owl:sameAs ?x)]
+[eq-trans: (?x owl:sameAs ?y) (?y owl:sameAs ?z) -> (?x owl:sameAs ?z)]
+[eq-rep-s: (?s owl:sameAs ?s2) (?s ?p ?o) -> (?s2 ?p ?o)]
+[eq-rep-p: (?p owl:sameAs ?p2) (?s ?p ?o) -> (?s ?p2 ?o)]
+[eq-rep-o: (?o owl:sameAs ?o2) (?s ?p ?o) -> (?s ?p ?o2)]
+[prp-fp: (?p rdf:type owl:FunctionalProperty) (?x ?p ?y1) (?x ?p ?y2) -> (?y1 owl:sameAs ?y2)]
+[prp-ifp: (?p rdf:type owl:InverseFunctionalProperty) (?x1 ?p ?y) (?x2 ?p ?y) -> (?x1 owl:sameAs ?x2)]
+[prp-symp: (?p rdf:type owl:SymmetricProperty) (?x ?p ?y) -> (?y ?p ?x)]
+[prp-trp: (?p rdf:type owl:TransitiveProperty) (?x ?p ?y) (?y ?p ?z) -> (?x ?p ?z)]
+[prp-inv1: (?p1 owl:inverseOf ?p2) (?x ?p1 ?y) -> (?y ?p2 ?x)]
+[prp-inv2: (?p1 owl:inverseOf ?p2) (?x ?p2 ?y) -> (?y ?p1 ?x)]
+`
+
+// OWLRules returns the OWL 2 RL rules implemented by OWLReasoner,
+// with every pattern matching the given graph. Passing "*" (an asterisk)
+// for graph returns rules that reason over each graph independently.
+//
+// The rules cover owl:sameAs (eq-sym, eq-trans, eq-rep-s, eq-rep-p
+// and eq-rep-o), owl:FunctionalProperty (prp-fp),
+// owl:InverseFunctionalProperty (prp-ifp), owl:SymmetricProperty (prp-symp),
+// owl:TransitiveProperty (prp-trp) and owl:inverseOf (prp-inv1 and prp-inv2).
+func OWLRules(graph string) []*Rule {
+	rules, err := ParseRules(owlRules)
+	if err != nil {
+		panic(err)
+	}
+	if graph == "*" {
+		graph = "?g"
+	}
+	for _, r := range rules {
+		for i := range r.Body {
+			r.Body[i].Graph = graph
+		}
+		for i := range r.Head {
+			r.Head[i].Graph = graph
+		}
+	}
+	return rules
+}
+
+// OWLReasoner materializes a subset of the OWL 2 RL entailments
+// for a graph in a QuadStore, and keeps them up to date as the
+// store changes. See OWLRules for the rules implemented.
+//
+// Unlike RDFSReasoner, inferred quads are added to the graph being
+// reasoned over. The reasoner's RuleEngine keeps track of which quads
+// it inferred, and retracts them when they are no longer supported.
+//
+// Returned by calls to NewOWLReasoner.
+type OWLReasoner struct {
+	// Graph is the graph being reasoned over,
+	// or "*" (an asterisk) to reason over each graph independently.
+	Graph string
+	// RuleEngine is the engine evaluating the OWL rules.
+	RuleEngine *RuleEngine
+}
+
+// NewOWLReasoner attaches a new OWLReasoner to the given store,
+// reasoning over the given graph. Any entailments of existing data
+// are materialized immediately.
+func NewOWLReasoner(s *QuadStore, graph string) *OWLReasoner {
+	r := &OWLReasoner{
+		Graph:      graph,
+		RuleEngine: NewRuleEngine(s, OWLRules(graph)...),
+	}
+	r.RuleEngine.Attach()
+	return r
+}
+
+// Detach stops the reasoner from tracking changes to the store.
+// Any inferred quads are left in place.
+func (r *OWLReasoner) Detach() {
+	r.RuleEngine.Detach()
+}
+
+// Inconsistency describes a single contradiction found by OWLReasoner's Check.
+type Inconsistency struct {
+	// Rule is the name of the OWL 2 RL rule that found the contradiction:
+	// "eq-diff1" for a resource that is both owl:sameAs and owl:differentFrom
+	// another, or "prp-fp" for a functional property with more than one
+	// distinct literal value.
+	Rule string
+	// Subject is the resource at fault.
+	Subject string
+	// Predicate is owl:differentFrom for eq-diff1,
+	// or the functional property for prp-fp.
+	Predicate string
+	// Objects holds the conflicting values.
+	Objects []interface{}
+	// Graph is the graph holding the contradiction.
+	Graph string
+}
+
+// String returns a human-readable description of the inconsistency.
+func (i Inconsistency) String() string {
+	switch i.Rule {
+	case "eq-diff1":
+		return fmt.Sprintf("%s: %s is both owl:sameAs and owl:differentFrom %v in graph %q",
+			i.Rule, i.Subject, i.Objects[0], i.Graph)
+	}
+	return fmt.Sprintf("%s: %s has conflicting values %v for functional property %s in graph %q",
+		i.Rule, i.Subject, i.Objects, i.Predicate, i.Graph)
+}
+
+// InconsistencyError is the error returned by OWLReasoner's Check
+// when the store holds contradictions.
+type InconsistencyError struct {
+	Inconsistencies []Inconsistency
+}
+
+// Error implements the error interface.
+func (e *InconsistencyError) Error() string {
+	msgs := make([]string, len(e.Inconsistencies))
+	for i, x := range e.Inconsistencies {
+		msgs[i] = x.String()
+	}
+	return "inconsistent data: " + strings.Join(msgs, "; ")
+}
+
+// Check looks for contradictions in the reasoner's graph, returning
+// an *InconsistencyError listing any found, or nil if there are none.
+//
+// Two kinds of contradiction are detected: a resource that is
+// owl:differentFrom a resource it is also owl:sameAs (or itself),
+// and a functional property that has more than one distinct
+// non-string value for the same subject. (Distinct string values
+// are IRIs, so they are inferred to be owl:sameAs each other.)
+func (r *OWLReasoner) Check() error {
+	s := r.RuleEngine.QuadStore
+	var out []Inconsistency
+	// eq-diff1: (x sameAs y) (x differentFrom y) -> false
+	s.ForEachWith("*", OWLDifferentFrom, "*", r.Graph, func(x, p string, y interface{}, g string) {
+		ys, ok := y.(string)
+		if !ok {
+			return
+		}
+		if x == ys || s.contains(x, OWLSameAs, ys, g) || s.contains(ys, OWLSameAs, x, g) {
+			out = append(out, Inconsistency{
+				Rule:      "eq-diff1",
+				Subject:   x,
+				Predicate: OWLDifferentFrom,
+				Objects:   []interface{}{y},
+				Graph:     g,
+			})
+		}
+	})
+	// prp-fp with literal values, which can not be owl:sameAs each other.
+	s.ForEachWith("*", RDFType, OWLFunctionalProperty, r.Graph, func(p, _ string, _ interface{}, g string) {
+		for _, x := range s.FindSubjects(p, "*", g) {
+			var literals []interface{}
+			for _, o := range s.FindObjects(x, p, g) {
+				if _, ok := o.(string); !ok {
+					literals = append(literals, o)
+				}
+			}
+			if len(literals) > 1 {
+				out = append(out, Inconsistency{
+					Rule:      "prp-fp",
+					Subject:   x,
+					Predicate: p,
+					Objects:   literals,
+					Graph:     g,
+				})
+			}
+		}
+	})
+	if len(out) > 0 {
+		return &InconsistencyError{Inconsistencies: out}
+	}
+	return nil
+}
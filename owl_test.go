@@ -0,0 +1,148 @@
+package store4_test
+
+import (
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OWLReasoner", func() {
+
+	It("should infer inverse properties", func() {
+		store := NewQuadStore([][3]string{
+			{"hasParent", OWLInverseOf, "hasChild"},
+			{"bob", "hasParent", "alice"},
+			{"alice", "hasChild", "carol"},
+		})
+		NewOWLReasoner(store, "")
+		Expect(store.Count("alice", "hasChild", "bob", "")).To(Equal(uint64(1)))
+		Expect(store.Count("carol", "hasParent", "alice", "")).To(Equal(uint64(1)))
+	})
+
+	It("should infer symmetric and transitive properties", func() {
+		store := NewQuadStore([][3]string{
+			{"knows", RDFType, OWLSymmetricProperty},
+			{"ancestorOf", RDFType, OWLTransitiveProperty},
+			{"alice", "knows", "bob"},
+			{"a", "ancestorOf", "b"},
+			{"b", "ancestorOf", "c"},
+			{"c", "ancestorOf", "d"},
+		})
+		NewOWLReasoner(store, "")
+		Expect(store.Count("bob", "knows", "alice", "")).To(Equal(uint64(1)))
+		Expect(store.FindObjects("a", "ancestorOf", "")).To(ConsistOf("b", "c", "d"))
+		Expect(store.FindObjects("b", "ancestorOf", "")).To(ConsistOf("c", "d"))
+	})
+
+	It("should apply owl:sameAs equality", func() {
+		store := NewQuadStore([][3]string{
+			{"alice", OWLSameAs, "ali"},
+			{"ali", OWLSameAs, "al"},
+			{"alice", "age", "thirty"},
+			{"bob", "knows", "al"},
+			{"knows", OWLSameAs, "isAcquaintedWith"},
+		})
+		NewOWLReasoner(store, "")
+		Expect(store.FindObjects("al", OWLSameAs, "")).To(ContainElements("alice", "ali"))
+		Expect(store.FindObjects("al", "age", "")).To(ConsistOf("thirty"))
+		Expect(store.FindObjects("bob", "knows", "")).To(ConsistOf("alice", "ali", "al"))
+		Expect(store.FindObjects("bob", "isAcquaintedWith", "")).To(ConsistOf("alice", "ali", "al"))
+	})
+
+	It("should infer owl:sameAs from functional properties", func() {
+		store := NewQuadStore([][3]string{
+			{"hasMother", RDFType, OWLFunctionalProperty},
+			{"hasEmail", RDFType, OWLInverseFunctionalProperty},
+			{"bob", "hasMother", "alice"},
+			{"bob", "hasMother", "mum"},
+			{"x", "hasEmail", "mailto:x@example.org"},
+			{"y", "hasEmail", "mailto:x@example.org"},
+		})
+		NewOWLReasoner(store, "")
+		Expect(store.Count("alice", OWLSameAs, "mum", "")).To(Equal(uint64(1)))
+		Expect(store.Count("x", OWLSameAs, "y", "")).To(Equal(uint64(1)))
+	})
+
+	It("should join on int-valued functional properties", func() {
+		store := NewQuadStore([][3]string{
+			{"employeeNumber", RDFType, OWLInverseFunctionalProperty},
+			{"employeeNumber", RDFType, OWLFunctionalProperty},
+		})
+		store.Add("alice", "employeeNumber", 42, "")
+		store.Add("ali", "employeeNumber", 42, "")
+		store.Add("bob", "employeeNumber", 7, "")
+		NewOWLReasoner(store, "")
+		Expect(store.Count("alice", OWLSameAs, "ali", "")).To(Equal(uint64(1)))
+		Expect(store.FindObjects("bob", OWLSameAs, "")).NotTo(ContainElement("alice"))
+		store.Add("carol", "employeeNumber", 7, "")
+		Expect(store.Count("bob", OWLSameAs, "carol", "")).To(Equal(uint64(1)))
+		Expect(store.FindObjects("carol", "employeeNumber", "")).To(Equal([]interface{}{7}))
+	})
+
+	It("should reason over each graph independently", func() {
+		store := NewQuadStore([][4]string{
+			{"knows", RDFType, OWLSymmetricProperty, "g1"},
+			{"alice", "knows", "bob", "g1"},
+			{"alice", "knows", "carol", "g2"},
+		})
+		NewOWLReasoner(store, "*")
+		Expect(store.Count("bob", "knows", "alice", "g1")).To(Equal(uint64(1)))
+		Expect(store.Count("carol", "knows", "alice", "*")).To(BeZero())
+	})
+
+	It("should keep inferences up to date", func() {
+		store := NewQuadStore([][3]string{
+			{"hasParent", OWLInverseOf, "hasChild"},
+		})
+		r := NewOWLReasoner(store, "")
+		store.Add("bob", "hasParent", "alice", "")
+		Expect(store.Count("alice", "hasChild", "bob", "")).To(Equal(uint64(1)))
+		store.Remove("bob", "hasParent", "alice", "")
+		Expect(store.Count("alice", "hasChild", "bob", "")).To(BeZero())
+		r.Detach()
+		store.Add("bob", "hasParent", "alice", "")
+		Expect(store.Count("alice", "hasChild", "bob", "")).To(BeZero())
+	})
+
+	Describe("Check", func() {
+
+		It("should return nil for consistent data", func() {
+			store := NewQuadStore([][3]string{
+				{"alice", OWLDifferentFrom, "bob"},
+			})
+			Expect(NewOWLReasoner(store, "").Check()).To(Succeed())
+		})
+
+		It("should report owl:differentFrom contradictions", func() {
+			store := NewQuadStore([][3]string{
+				{"alice", OWLSameAs, "ali"},
+				{"ali", OWLSameAs, "al"},
+				{"al", OWLDifferentFrom, "alice"},
+				{"bob", OWLDifferentFrom, "bob"},
+			})
+			err := NewOWLReasoner(store, "").Check()
+			Expect(err).To(HaveOccurred())
+			// Equality replication spreads the contradiction to every alias.
+			Expect(err.(*InconsistencyError).Inconsistencies).To(ContainElements(
+				Inconsistency{"eq-diff1", "al", OWLDifferentFrom, []interface{}{"alice"}, ""},
+				Inconsistency{"eq-diff1", "bob", OWLDifferentFrom, []interface{}{"bob"}, ""},
+			))
+		})
+
+		It("should report conflicting functional property values", func() {
+			store := NewQuadStore([][4]string{
+				{"age", RDFType, OWLFunctionalProperty, "g1"},
+			})
+			store.Add("alice", "age", 30, "g1")
+			store.Add("alice", "age", 31, "g1")
+			store.Add("bob", "age", 40, "g1")
+			err := NewOWLReasoner(store, "*").Check()
+			Expect(err).To(MatchError(ContainSubstring("prp-fp: alice has conflicting values")))
+			incs := err.(*InconsistencyError).Inconsistencies
+			Expect(incs).To(HaveLen(1))
+			Expect(incs[0].Objects).To(ConsistOf(30, 31))
+			Expect(incs[0].Graph).To(Equal("g1"))
+		})
+	})
+})
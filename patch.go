@@ -0,0 +1,431 @@
+package store4
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// PatchOp is the operation of a row in an RDF Patch.
+type PatchOp int
+
+const (
+	// PatchAdd adds a quad (row code "A").
+	PatchAdd PatchOp = iota
+	// PatchDelete deletes a quad (row code "D").
+	PatchDelete
+	// PatchTxBegin begins a transaction (row code "TX").
+	PatchTxBegin
+	// PatchTxCommit commits a transaction (row code "TC").
+	PatchTxCommit
+	// PatchTxAbort aborts a transaction (row code "TA").
+	PatchTxAbort
+	// PatchPrefixAdd declares a prefix (row code "PA").
+	PatchPrefixAdd
+	// PatchPrefixDelete removes a prefix (row code "PD").
+	PatchPrefixDelete
+	// PatchHeader is a header entry (row code "H").
+	PatchHeader
+)
+
+// patchOpCodes holds the row code of each PatchOp.
+var patchOpCodes = []string{"A", "D", "TX", "TC", "TA", "PA", "PD", "H"}
+
+// String returns the row code of the operation, such as "A" or "TX".
+func (op PatchOp) String() string {
+	if op < 0 || int(op) >= len(patchOpCodes) {
+		return "PatchOp(" + strconv.Itoa(int(op)) + ")"
+	}
+	return patchOpCodes[op]
+}
+
+// PatchRow is a single row of an RDF Patch.
+type PatchRow struct {
+	Op PatchOp
+	// Subject, Predicate, Object and Graph hold the quad
+	// of PatchAdd and PatchDelete rows. An empty Graph
+	// is the default graph.
+	Subject   string
+	Predicate string
+	Object    interface{}
+	Graph     string
+	// Name holds the header name of PatchHeader rows,
+	// or the prefix of PatchPrefixAdd and PatchPrefixDelete rows.
+	Name string
+	// Value holds the header value of PatchHeader rows,
+	// or the namespace IRI of PatchPrefixAdd rows.
+	Value interface{}
+}
+
+// Patch is a list of changes in the RDF Patch format,
+// which can be read, written and applied to a QuadStore.
+//
+// See https://afs.github.io/rdf-delta/rdf-patch.html
+type Patch struct {
+	Rows []PatchRow
+}
+
+// ReadPatch reads a patch in RDF Patch text format from the given reader.
+//
+// Prefixed names are expanded using the prefixes declared by earlier
+// PA rows. Literals with XSD datatypes that map to Go types are converted
+// to those types (see Literal), and all other literals are read as Literals.
+func ReadPatch(r io.Reader) (*Patch, error) {
+	p := &Patch{}
+	prefixes := make(map[string]string)
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		sc := &patchScanner{src: []rune(line), line: n, prefixes: prefixes}
+		row, ok, rerr := sc.row()
+		if rerr != nil {
+			return nil, rerr
+		}
+		if ok {
+			switch row.Op {
+			case PatchPrefixAdd:
+				prefixes[row.Name] = row.Value.(string)
+			case PatchPrefixDelete:
+				delete(prefixes, row.Name)
+			}
+			p.Rows = append(p.Rows, row)
+		}
+		if err == io.EOF {
+			return p, nil
+		}
+	}
+}
+
+// WriteTo writes the patch to the given writer in RDF Patch text format,
+// returning the number of bytes written. Prefixed names are not used
+// when writing, and PA rows are written only as declarations.
+func (p *Patch) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var total int64
+	for _, row := range p.Rows {
+		n, err := bw.WriteString(formatPatchRow(row))
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, bw.Flush()
+}
+
+// String returns the patch in RDF Patch text format.
+func (p *Patch) String() string {
+	var b strings.Builder
+	p.WriteTo(&b)
+	return b.String()
+}
+
+// formatPatchRow returns the given row in RDF Patch text format,
+// including its terminating newline.
+func formatPatchRow(row PatchRow) string {
+	switch row.Op {
+	case PatchAdd, PatchDelete:
+		return row.Op.String() + " " + formatNQuad(row.Subject, row.Predicate, row.Object, row.Graph)
+	case PatchHeader:
+		return "H " + row.Name + " " + formatNQuadsTerm(row.Value) + " .\n"
+	case PatchPrefixAdd:
+		return "PA " + row.Name + ": " + formatNQuadsTerm(row.Value) + " .\n"
+	case PatchPrefixDelete:
+		return "PD " + row.Name + ": .\n"
+	}
+	return row.Op.String() + " .\n"
+}
+
+// ErrPatchTransaction is returned by ApplyPatch when a patch's
+// transaction rows are unbalanced.
+var ErrPatchTransaction = errors.New("unbalanced transaction in patch")
+
+// ApplyPatch applies the given patch to the store, atomically:
+// either every change is made, or, if any row fails, the store
+// is returned to its original state and the error is returned.
+//
+// Adding a quad that already exists, or deleting a quad that does
+// not exist, has no effect. A TA row reverts the changes made since
+// the preceding TX row. Header and prefix rows are ignored.
+//
+// ErrWildcardTerm is returned if any quad contains a "*" (asterisk) term,
+// and ErrPatchTransaction is returned for a TC or TA row without a
+// preceding TX, a nested TX, or a TX that is not closed by the end
+// of the patch. A change rejected by the BeforeAdd or BeforeRemove
+// callbacks also fails the patch, with the callback's error.
+//
+// If provided, the OnBatch callback is called once, with all changes
+// made by the patch (including any that were reverted). If the journal
+// is enabled, the patch is recorded as a single step.
+func (s *QuadStore) ApplyPatch(p *Patch) error {
+	defer s.beginBatch(0)()
+	s.BeginGroup()
+	defer s.EndGroup()
+
+	var applied []Change
+	// tx is the index in applied of the open transaction's first change,
+	// or -1 if there is no open transaction.
+	tx := -1
+	for _, row := range p.Rows {
+		var err error
+		switch row.Op {
+		case PatchAdd, PatchDelete:
+			if row.Subject == "*" || row.Predicate == "*" || row.Object == "*" || row.Graph == "*" {
+				err = ErrWildcardTerm
+				break
+			}
+			if row.Op == PatchAdd {
+				var ok bool
+				ok, err = s.TryAdd(row.Subject, row.Predicate, row.Object, row.Graph)
+				if ok {
+					applied = append(applied, Change{Added, row.Subject, row.Predicate, row.Object, row.Graph})
+				}
+			} else {
+				var n uint64
+				n, err = s.TryRemove(row.Subject, row.Predicate, row.Object, row.Graph)
+				if n > 0 {
+					applied = append(applied, Change{Removed, row.Subject, row.Predicate, row.Object, row.Graph})
+				}
+			}
+		case PatchTxBegin:
+			if tx >= 0 {
+				err = ErrPatchTransaction
+				break
+			}
+			tx = len(applied)
+		case PatchTxCommit:
+			if tx < 0 {
+				err = ErrPatchTransaction
+				break
+			}
+			tx = -1
+		case PatchTxAbort:
+			if tx < 0 {
+				err = ErrPatchTransaction
+				break
+			}
+			s.revert(applied[tx:])
+			applied = applied[:tx]
+			tx = -1
+		}
+		if err != nil {
+			s.revert(applied)
+			return err
+		}
+	}
+	if tx >= 0 {
+		s.revert(applied)
+		return ErrPatchTransaction
+	}
+	return nil
+}
+
+// revert undoes the given changes, in reverse order, bypassing
+// the BeforeAdd and BeforeRemove callbacks.
+func (s *QuadStore) revert(changes []Change) {
+	beforeAdd, beforeRemove := s.BeforeAdd, s.BeforeRemove
+	s.BeforeAdd, s.BeforeRemove = nil, nil
+	defer func() { s.BeforeAdd, s.BeforeRemove = beforeAdd, beforeRemove }()
+	for i := len(changes) - 1; i >= 0; i-- {
+		s.apply(changes[i], true)
+	}
+}
+
+// patchScanner parses a single line of RDF Patch text.
+type patchScanner struct {
+	src      []rune
+	pos      int
+	line     int
+	prefixes map[string]string
+}
+
+func (sc *patchScanner) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("patch syntax error at line %d: %s", sc.line, fmt.Sprintf(format, args...))
+}
+
+// row parses the line as a row. Returns false if the line is
+// blank or holds only a comment.
+func (sc *patchScanner) row() (PatchRow, bool, error) {
+	var row PatchRow
+	sc.skipSpace()
+	if sc.done() {
+		return row, false, nil
+	}
+	code := sc.word()
+	op := -1
+	for i, c := range patchOpCodes {
+		if c == code {
+			op = i
+		}
+	}
+	if op < 0 {
+		return row, false, sc.errorf("unknown row code %q", code)
+	}
+	row.Op = PatchOp(op)
+	switch row.Op {
+	case PatchAdd, PatchDelete:
+		var terms []interface{}
+		for len(terms) < 4 && !sc.done() {
+			t, err := sc.term()
+			if err != nil {
+				return row, false, err
+			}
+			terms = append(terms, t)
+		}
+		if len(terms) < 3 {
+			return row, false, sc.errorf("expected 3 or 4 terms, got %d", len(terms))
+		}
+		for i, t := range terms {
+			if i == 2 {
+				continue
+			}
+			if _, ok := t.(string); !ok {
+				return row, false, sc.errorf("unexpected literal %s", formatNQuadsTerm(t))
+			}
+		}
+		row.Subject = terms[0].(string)
+		row.Predicate = terms[1].(string)
+		row.Object = terms[2]
+		if len(terms) == 4 {
+			row.Graph = terms[3].(string)
+		}
+	case PatchHeader:
+		row.Name = sc.word()
+		if row.Name == "" {
+			return row, false, sc.errorf("expected header name")
+		}
+		v, err := sc.term()
+		if err != nil {
+			return row, false, err
+		}
+		row.Value = v
+	case PatchPrefixAdd, PatchPrefixDelete:
+		row.Name = strings.TrimSuffix(sc.word(), ":")
+		if row.Op == PatchPrefixAdd {
+			sc.skipSpace()
+			if sc.done() || sc.src[sc.pos] != '<' {
+				return row, false, sc.errorf("expected namespace IRI")
+			}
+			v, err := sc.term()
+			if err != nil {
+				return row, false, err
+			}
+			row.Value = v
+		}
+	}
+	if !sc.done() {
+		return row, false, sc.errorf("unexpected %q", string(sc.src[sc.pos:]))
+	}
+	return row, true, nil
+}
+
+// skipSpace skips whitespace.
+func (sc *patchScanner) skipSpace() {
+	for sc.pos < len(sc.src) && unicode.IsSpace(sc.src[sc.pos]) {
+		sc.pos++
+	}
+}
+
+// done skips whitespace, and any terminating "." and comment,
+// and returns true if the end of the line has been reached.
+func (sc *patchScanner) done() bool {
+	sc.skipSpace()
+	if sc.pos < len(sc.src) && sc.src[sc.pos] == '.' {
+		rest := sc.pos + 1
+		for rest < len(sc.src) && unicode.IsSpace(sc.src[rest]) {
+			rest++
+		}
+		if rest == len(sc.src) || sc.src[rest] == '#' {
+			sc.pos = rest
+		}
+	}
+	if sc.pos < len(sc.src) && sc.src[sc.pos] == '#' {
+		sc.pos = len(sc.src)
+	}
+	return sc.pos >= len(sc.src)
+}
+
+// word returns the next run of non-space characters, excluding
+// any trailing "." that ends the row.
+func (sc *patchScanner) word() string {
+	sc.skipSpace()
+	start := sc.pos
+	for sc.pos < len(sc.src) && !unicode.IsSpace(sc.src[sc.pos]) {
+		sc.pos++
+	}
+	if sc.pos > start+1 && sc.src[sc.pos-1] == '.' {
+		sc.pos--
+	}
+	return string(sc.src[start:sc.pos])
+}
+
+// term parses an IRI, blank node, literal or prefixed name.
+func (sc *patchScanner) term() (interface{}, error) {
+	sc.skipSpace()
+	if sc.pos >= len(sc.src) {
+		return nil, sc.errorf("unexpected end of row")
+	}
+	switch c := sc.src[sc.pos]; c {
+	case '<':
+		return sc.quoted(scanIRIRef)
+	case '"', '\'':
+		value, err := sc.quoted(scanString)
+		if err != nil {
+			return nil, err
+		}
+		if sc.pos < len(sc.src) && sc.src[sc.pos] == '@' {
+			sc.pos++
+			return Literal{Value: value, Language: sc.word()}, nil
+		}
+		if sc.pos+1 < len(sc.src) && sc.src[sc.pos] == '^' && sc.src[sc.pos+1] == '^' {
+			sc.pos += 2
+			dt, err := sc.term()
+			if err != nil {
+				return nil, err
+			}
+			iri, ok := dt.(string)
+			if !ok || isBlankNode(iri) {
+				return nil, sc.errorf("expected datatype IRI")
+			}
+			return literalValue(value, iri, ""), nil
+		}
+		return Literal{Value: value}, nil
+	}
+	w := sc.word()
+	switch {
+	case strings.HasPrefix(w, "_:"):
+		return w, nil
+	case w == "true" || w == "false":
+		return w == "true", nil
+	}
+	if n, ok := parseNumber(w); ok {
+		if isDecimal(w) {
+			return Literal{Value: w, Datatype: XSDNamespace + "decimal"}, nil
+		}
+		return n, nil
+	}
+	if strings.IndexByte(w, ':') >= 0 {
+		iri, ok := expandPrefixedName(w, sc.prefixes)
+		if !ok {
+			return nil, sc.errorf("undeclared prefix %q", prefixOf(w))
+		}
+		return iri, nil
+	}
+	return nil, sc.errorf("unexpected %q", w)
+}
+
+// quoted parses an IRI or string using the given scan function.
+func (sc *patchScanner) quoted(scan func(src []rune, pos int) (string, int, error)) (string, error) {
+	text, pos, err := scan(sc.src, sc.pos)
+	sc.pos = pos
+	if err != nil {
+		return "", sc.errorf("%v", err)
+	}
+	return text, nil
+}
@@ -0,0 +1,275 @@
+package store4_test
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Patch", func() {
+
+	const ex = "http://example.org/"
+
+	Describe("ReadPatch", func() {
+
+		It("should read rows", func() {
+			text := `H id <uuid:0123> .
+TX .
+PA ex: <http://example.org/> .
+A <http://example.org/s> ex:p "x"@en <http://example.org/g> .
+A _:b1 ex:p "42"^^<http://www.w3.org/2001/XMLSchema#integer> .
+# A comment.
+D _:b1 ex:q "a \"quoted\"\né string"
+A ex:s ex:p true .
+A ex:s ex:p "y"^^ex:type .
+PD ex: .
+TC .
+`
+			p, err := ReadPatch(strings.NewReader(text))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.Rows).To(Equal([]PatchRow{
+				{Op: PatchHeader, Name: "id", Value: "uuid:0123"},
+				{Op: PatchTxBegin},
+				{Op: PatchPrefixAdd, Name: "ex", Value: ex},
+				{Op: PatchAdd, Subject: ex + "s", Predicate: ex + "p", Object: Literal{Value: "x", Language: "en"}, Graph: ex + "g"},
+				{Op: PatchAdd, Subject: "_:b1", Predicate: ex + "p", Object: 42},
+				{Op: PatchDelete, Subject: "_:b1", Predicate: ex + "q", Object: Literal{Value: "a \"quoted\"\né string"}},
+				{Op: PatchAdd, Subject: ex + "s", Predicate: ex + "p", Object: true},
+				{Op: PatchAdd, Subject: ex + "s", Predicate: ex + "p", Object: Literal{Value: "y", Datatype: ex + "type"}},
+				{Op: PatchPrefixDelete, Name: "ex"},
+				{Op: PatchTxCommit},
+			}))
+		})
+
+		It("should read numbers and escapes as in other syntaxes", func() {
+			p, err := ReadPatch(strings.NewReader(`A <\u0073> <p> 1.50 .
+A <s> <p> 1e3 .
+A <s> <p> -2 .
+A <s> <p> 'it\'s' .
+`))
+			Expect(err).NotTo(HaveOccurred())
+			objects := []interface{}{}
+			for _, row := range p.Rows {
+				objects = append(objects, row.Object)
+			}
+			Expect(p.Rows[0].Subject).To(Equal("s"))
+			Expect(objects).To(Equal([]interface{}{
+				Literal{Value: "1.50", Datatype: XSDNamespace + "decimal"}, 1e3, -2, Literal{Value: "it's"},
+			}))
+		})
+
+		It("should report syntax errors", func() {
+			_, err := ReadPatch(strings.NewReader("TX .\nX <s> <p> <o> .\n"))
+			Expect(err).To(MatchError(`patch syntax error at line 2: unknown row code "X"`))
+			_, err = ReadPatch(strings.NewReader("A <s> <p> .\n"))
+			Expect(err).To(MatchError("patch syntax error at line 1: expected 3 or 4 terms, got 2"))
+			_, err = ReadPatch(strings.NewReader("A ex:s <p> <o> .\n"))
+			Expect(err).To(MatchError(`patch syntax error at line 1: undeclared prefix "ex"`))
+			_, err = ReadPatch(strings.NewReader(`A "s" <p> <o> .`))
+			Expect(err).To(MatchError(`patch syntax error at line 1: unexpected literal "s"`))
+		})
+	})
+
+	Describe("WriteTo", func() {
+
+		It("should write rows that read back the same", func() {
+			p := &Patch{Rows: []PatchRow{
+				{Op: PatchHeader, Name: "id", Value: "uuid:0123"},
+				{Op: PatchTxBegin},
+				{Op: PatchPrefixAdd, Name: "ex", Value: ex},
+				{Op: PatchAdd, Subject: ex + "s", Predicate: ex + "p", Object: Literal{Value: "x\ty", Language: "en"}, Graph: ex + "g"},
+				{Op: PatchAdd, Subject: "_:b1", Predicate: ex + "p", Object: 42},
+				{Op: PatchAdd, Subject: "_:b1", Predicate: ex + "p", Object: 4.5},
+				{Op: PatchAdd, Subject: "_:b1", Predicate: ex + "p", Object: float32(1.5)},
+				{Op: PatchDelete, Subject: ex + "a b", Predicate: ex + "p", Object: false},
+				{Op: PatchPrefixDelete, Name: "ex"},
+				{Op: PatchTxAbort},
+			}}
+			text := p.String()
+			Expect(text).To(Equal(`H id <uuid:0123> .
+TX .
+PA ex: <http://example.org/> .
+A <http://example.org/s> <http://example.org/p> "x\ty"@en <http://example.org/g> .
+A _:b1 <http://example.org/p> "42"^^<http://www.w3.org/2001/XMLSchema#integer> .
+A _:b1 <http://example.org/p> "4.5E0"^^<http://www.w3.org/2001/XMLSchema#double> .
+A _:b1 <http://example.org/p> "1.5E0"^^<http://www.w3.org/2001/XMLSchema#float> .
+D <http://example.org/a\u0020b> <http://example.org/p> "false"^^<http://www.w3.org/2001/XMLSchema#boolean> .
+PD ex: .
+TA .
+`))
+			p2, err := ReadPatch(strings.NewReader(text))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p2).To(Equal(p))
+		})
+
+		It("should round trip every supported literal type", func() {
+			objects := []interface{}{
+				7, int8(-8), int16(16), int32(-32), int64(64),
+				uint(1), uint8(8), uint16(16), uint32(32), uint64(1 << 63),
+				float32(1.5), 2.5, true,
+				Literal{Value: "x"}, Literal{Value: "y", Language: "en"}, Literal{Value: "z", Datatype: ex + "t"},
+				time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+			}
+			a, b := NewQuadStore(), NewQuadStore()
+			for i, o := range objects {
+				b.Add(ex+"s", ex+"p"+strconv.Itoa(i), o, "")
+			}
+			var buf bytes.Buffer
+			_, err := Diff(a, b).Patch().WriteTo(&buf)
+			Expect(err).NotTo(HaveOccurred())
+			p, err := ReadPatch(&buf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a.ApplyPatch(p)).To(Succeed())
+			Expect(Diff(a, b)).To(BeEmpty())
+			for i, o := range objects {
+				Expect(a.FindObjects(ex+"s", ex+"p"+strconv.Itoa(i), "")).To(Equal([]interface{}{o}))
+			}
+		})
+
+		It("should round trip times with their offsets", func() {
+			times := []time.Time{
+				time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("IST", 5*3600+30*60)),
+				time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("PST", -8*3600)),
+			}
+			p := &Patch{}
+			for _, t := range times {
+				p.Rows = append(p.Rows, PatchRow{Op: PatchAdd, Subject: "s", Predicate: "p", Object: t})
+			}
+			text := p.String()
+			store := NewQuadStore()
+			for n := 0; n < 2; n++ {
+				p, err := ReadPatch(strings.NewReader(text))
+				Expect(err).NotTo(HaveOccurred())
+				for i, row := range p.Rows {
+					t := row.Object.(time.Time)
+					Expect(t.Equal(times[i])).To(BeTrue())
+					_, want := times[i].Zone()
+					_, got := t.Zone()
+					Expect(got).To(Equal(want))
+				}
+				Expect(store.ApplyPatch(p)).To(Succeed())
+			}
+			// Times read back from the same text are the same objects.
+			Expect(store.Size()).To(Equal(uint64(2)))
+		})
+	})
+
+	Describe("ApplyPatch", func() {
+
+		It("should add and delete quads", func() {
+			store := NewQuadStore([]*Quad{
+				{"s1", "p1", "o1", ""},
+				{"s2", "p2", "o2", "g"},
+			})
+			p := &Patch{Rows: []PatchRow{
+				{Op: PatchTxBegin},
+				{Op: PatchDelete, Subject: "s2", Predicate: "p2", Object: "o2", Graph: "g"},
+				{Op: PatchDelete, Subject: "s9", Predicate: "p9", Object: "o9"},
+				{Op: PatchAdd, Subject: "s3", Predicate: "p3", Object: 3},
+				{Op: PatchTxCommit},
+			}}
+			Expect(store.ApplyPatch(p)).To(Succeed())
+			Expect(iterResults(store)).To(ConsistOf([]*Quad{
+				{"s1", "p1", "o1", ""},
+				{"s3", "p3", 3, ""},
+			}))
+		})
+
+		It("should revert aborted transactions", func() {
+			store := NewQuadStore([]*Quad{
+				{"s1", "p1", "o1", ""},
+			})
+			p := &Patch{Rows: []PatchRow{
+				{Op: PatchAdd, Subject: "s2", Predicate: "p2", Object: "o2"},
+				{Op: PatchTxBegin},
+				{Op: PatchDelete, Subject: "s1", Predicate: "p1", Object: "o1"},
+				{Op: PatchAdd, Subject: "s3", Predicate: "p3", Object: "o3"},
+				{Op: PatchTxAbort},
+			}}
+			Expect(store.ApplyPatch(p)).To(Succeed())
+			Expect(iterResults(store)).To(ConsistOf([]*Quad{
+				{"s1", "p1", "o1", ""},
+				{"s2", "p2", "o2", ""},
+			}))
+		})
+
+		It("should apply nothing if a change is rejected", func() {
+			store := NewQuadStore([]*Quad{
+				{"s1", "p1", "o1", ""},
+			})
+			veto := errors.New("vetoed")
+			store.BeforeAdd = func(s, p string, o interface{}, g string) error {
+				if s == "bad" {
+					return veto
+				}
+				return nil
+			}
+			var batches [][]Change
+			store.OnBatch = func(changes []Change) {
+				batches = append(batches, changes)
+			}
+			p := &Patch{Rows: []PatchRow{
+				{Op: PatchTxBegin},
+				{Op: PatchDelete, Subject: "s1", Predicate: "p1", Object: "o1"},
+				{Op: PatchAdd, Subject: "s2", Predicate: "p2", Object: "o2"},
+				{Op: PatchAdd, Subject: "bad", Predicate: "p", Object: "o"},
+				{Op: PatchTxCommit},
+			}}
+			Expect(store.ApplyPatch(p)).To(MatchError(veto))
+			Expect(iterResults(store)).To(ConsistOf([]*Quad{
+				{"s1", "p1", "o1", ""},
+			}))
+			Expect(batches).To(HaveLen(1))
+			Expect(batches[0]).To(HaveLen(4))
+		})
+
+		It("should apply nothing if transactions are unbalanced", func() {
+			store := NewQuadStore()
+			add := PatchRow{Op: PatchAdd, Subject: "s", Predicate: "p", Object: "o"}
+			tx, tc := PatchRow{Op: PatchTxBegin}, PatchRow{Op: PatchTxCommit}
+			for _, rows := range [][]PatchRow{
+				{tx, add},
+				{add, tc},
+				{tx, add, tx, tc},
+				{add, {Op: PatchTxAbort}},
+			} {
+				Expect(store.ApplyPatch(&Patch{Rows: rows})).To(MatchError(ErrPatchTransaction))
+				Expect(store.Size()).To(BeZero())
+			}
+		})
+
+		It("should reject wildcard terms", func() {
+			store := NewQuadStore([]*Quad{
+				{"s1", "p1", "o1", ""},
+			})
+			p := &Patch{Rows: []PatchRow{
+				{Op: PatchDelete, Subject: "*", Predicate: "*", Object: "*", Graph: "*"},
+			}}
+			Expect(store.ApplyPatch(p)).To(MatchError(ErrWildcardTerm))
+			Expect(store.Size()).To(Equal(uint64(1)))
+		})
+
+		It("should be undone as a single step", func() {
+			store := NewQuadStore([]*Quad{
+				{"s1", "p1", "o1", ""},
+			})
+			store.EnableJournal()
+			p := &Patch{Rows: []PatchRow{
+				{Op: PatchDelete, Subject: "s1", Predicate: "p1", Object: "o1"},
+				{Op: PatchAdd, Subject: "s2", Predicate: "p2", Object: "o2"},
+			}}
+			Expect(store.ApplyPatch(p)).To(Succeed())
+			Expect(store.Undo()).To(BeTrue())
+			Expect(iterResults(store)).To(ConsistOf([]*Quad{
+				{"s1", "p1", "o1", ""},
+			}))
+		})
+	})
+})
@@ -0,0 +1,537 @@
+package store4
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// PropertyPath is a parsed SPARQL-style property path,
+// for finding the nodes connected by sequences of edges.
+//
+// Returned by calls to ParsePropertyPath.
+type PropertyPath struct {
+	text string
+	root pathExpr
+}
+
+// String returns the text that the path was parsed from.
+func (p *PropertyPath) String() string {
+	return p.text
+}
+
+// pathPrefixes holds the predeclared prefixes of property paths.
+var pathPrefixes = map[string]string{
+	"rdf":  "http://www.w3.org/1999/02/22-rdf-syntax-ns#",
+	"rdfs": "http://www.w3.org/2000/01/rdf-schema#",
+	"owl":  "http://www.w3.org/2002/07/owl#",
+	"xsd":  XSDNamespace,
+}
+
+// ParsePropertyPath parses a property path in SPARQL syntax:
+//  p            the predicate p
+//  ^path        the path, in reverse
+//  path1/path2  path1, followed by path2
+//  path1|path2  either path1 or path2
+//  path*        zero or more repetitions of path
+//  path+        one or more repetitions of path
+//  path?        zero or one repetition of path
+//  !(p|^q)      any predicate but p, or any reverse predicate but q
+//  (path)       grouping
+//
+// Predicates can be IRIs in angle brackets, prefixed names, or bare names,
+// and "a" is shorthand for rdf:type. Prefixed names are expanded using
+// the given prefixes (which may be nil), and the rdf, rdfs, owl and xsd
+// prefixes are predeclared. Names with other prefixes are used as-is.
+func ParsePropertyPath(text string, prefixes map[string]string) (*PropertyPath, error) {
+	p := &pathParser{src: []rune(text), prefixes: prefixes}
+	root, err := p.alternative()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.next(); tok.kind != pathEOF {
+		return nil, p.errorf(tok, "unexpected %v", tok)
+	}
+	return &PropertyPath{text: text, root: root}, nil
+}
+
+// FindObjectsByPath returns a list of distinct nodes connected to
+// the given subject by the given path, in the given graph.
+//
+// Passing "*" (an asterisk) for the graph evaluates
+// the path over the union of all graphs.
+func (s *QuadStore) FindObjectsByPath(subject string, path *PropertyPath, graph string) []interface{} {
+	var out []interface{}
+	s.evalPath(subject, path, graph, false, func(node interface{}) {
+		out = append(out, node)
+	})
+	return out
+}
+
+// FindSubjectsByPath returns a list of distinct subjects connected to
+// the given object by the given path, in the given graph.
+//
+// Passing "*" (an asterisk) for the graph evaluates
+// the path over the union of all graphs.
+func (s *QuadStore) FindSubjectsByPath(path *PropertyPath, object interface{}, graph string) []string {
+	var out []string
+	s.evalPath(object, path, graph, true, func(node interface{}) {
+		if subject, ok := node.(string); ok {
+			out = append(out, subject)
+		}
+	})
+	return out
+}
+
+// FindObjectsByPath returns a list of distinct nodes connected to
+// the given subject by the given path, in the graph.
+func (g *GraphView) FindObjectsByPath(subject string, path *PropertyPath) []interface{} {
+	return g.QuadStore.FindObjectsByPath(subject, path, g.Graph)
+}
+
+// FindSubjectsByPath returns a list of distinct subjects connected to
+// the given object by the given path, in the graph.
+func (g *GraphView) FindSubjectsByPath(path *PropertyPath, object interface{}) []string {
+	return g.QuadStore.FindSubjectsByPath(path, object, g.Graph)
+}
+
+// evalPath calls the given function once for each node connected to the
+// given start node by the path, or by the reverse path if inverse is true.
+func (s *QuadStore) evalPath(start interface{}, path *PropertyPath, graph string, inverse bool, fn ObjectCallbackFn) {
+	id, ok := s.pool.anyToID(start)
+	if !ok || id == 0 {
+		// Only a zero-length path can connect a node that is not in the store.
+		if path.root.nullable() {
+			fn(start)
+		}
+		return
+	}
+	e := &pathEval{s.pool, graphOperand(s, graph).graphs}
+	for n := range e.eval(path.root, nodeSet{id: {}}, inverse) {
+		fn(s.pool.idToAny(n))
+	}
+}
+
+// nodeSet is a set of node IDs.
+type nodeSet map[uint64]struct{}
+
+// pathEval evaluates property paths over the indexes of some graphs.
+type pathEval struct {
+	pool   *pool
+	graphs []*indexedGraph
+}
+
+// eval returns the set of nodes connected to any of the given nodes
+// by the given path, or by the reverse path if inverse is true.
+func (e *pathEval) eval(x pathExpr, from nodeSet, inverse bool) nodeSet {
+	out := make(nodeSet)
+	switch x := x.(type) {
+	case pathLink:
+		pid, ok := e.pool.stringToID(x.iri)
+		if !ok || pid == 0 {
+			return out
+		}
+		for n := range from {
+			for _, g := range e.graphs {
+				if inverse {
+					// POS: predicate, object, subject.
+					for sid := range g.posIndex[pid][n] {
+						out[sid] = struct{}{}
+					}
+				} else {
+					for oid := range g.spoIndex[n][pid] {
+						out[oid] = struct{}{}
+					}
+				}
+			}
+		}
+	case pathInverse:
+		return e.eval(x.sub, from, !inverse)
+	case pathSeq:
+		cur := from
+		for i := range x {
+			step := x[i]
+			if inverse {
+				step = x[len(x)-1-i]
+			}
+			cur = e.eval(step, cur, inverse)
+			if len(cur) == 0 {
+				break
+			}
+		}
+		return cur
+	case pathAlt:
+		for _, sub := range x {
+			for n := range e.eval(sub, from, inverse) {
+				out[n] = struct{}{}
+			}
+		}
+	case pathRepeat:
+		if x.min == 0 {
+			for n := range from {
+				out[n] = struct{}{}
+			}
+		}
+		frontier := e.eval(x.sub, from, inverse)
+		for len(frontier) > 0 {
+			next := make(nodeSet)
+			for n := range frontier {
+				if _, seen := out[n]; !seen {
+					out[n] = struct{}{}
+					next[n] = struct{}{}
+				}
+			}
+			if !x.many {
+				break
+			}
+			// Only newly reached nodes are expanded, so cycles terminate.
+			frontier = e.eval(x.sub, next, inverse)
+		}
+	case pathNegated:
+		fwd, inv := e.ids(x.fwd), e.ids(x.inv)
+		// Reversing the path swaps the edges that each set applies to.
+		outgoing, incoming := len(x.fwd) > 0, len(x.inv) > 0
+		outgoingExcl, incomingExcl := fwd, inv
+		if inverse {
+			outgoing, incoming = incoming, outgoing
+			outgoingExcl, incomingExcl = inv, fwd
+		}
+		for n := range from {
+			for _, g := range e.graphs {
+				if outgoing {
+					for pid, objects := range g.spoIndex[n] {
+						if _, excluded := outgoingExcl[pid]; !excluded {
+							for oid := range objects {
+								out[oid] = struct{}{}
+							}
+						}
+					}
+				}
+				if incoming {
+					// OSP: object, subject, predicate.
+					for sid, predicates := range g.ospIndex[n] {
+						for pid := range predicates {
+							if _, excluded := incomingExcl[pid]; !excluded {
+								out[sid] = struct{}{}
+								break
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+// ids returns the IDs of those of the given predicates in the pool.
+func (e *pathEval) ids(predicates []string) nodeSet {
+	out := make(nodeSet, len(predicates))
+	for _, p := range predicates {
+		if id, ok := e.pool.stringToID(p); ok {
+			out[id] = struct{}{}
+		}
+	}
+	return out
+}
+
+// pathExpr is a node of a parsed property path.
+type pathExpr interface {
+	// nullable returns true if the path matches zero-length paths.
+	nullable() bool
+}
+
+// pathLink matches a single edge with the given predicate.
+type pathLink struct {
+	iri string
+}
+
+// pathInverse matches its sub-path in reverse.
+type pathInverse struct {
+	sub pathExpr
+}
+
+// pathSeq matches its sub-paths in sequence.
+type pathSeq []pathExpr
+
+// pathAlt matches any of its sub-paths.
+type pathAlt []pathExpr
+
+// pathRepeat matches repetitions of its sub-path: at least min (0 or 1),
+// and at most one, or unboundedly many if many is true.
+type pathRepeat struct {
+	sub  pathExpr
+	min  int
+	many bool
+}
+
+// pathNegated matches a single edge with any predicate not in fwd,
+// or a single reverse edge with any predicate not in inv.
+type pathNegated struct {
+	fwd, inv []string
+}
+
+func (x pathLink) nullable() bool    { return false }
+func (x pathInverse) nullable() bool { return x.sub.nullable() }
+func (x pathNegated) nullable() bool { return false }
+func (x pathRepeat) nullable() bool  { return x.min == 0 || x.sub.nullable() }
+
+func (x pathSeq) nullable() bool {
+	for _, sub := range x {
+		if !sub.nullable() {
+			return false
+		}
+	}
+	return true
+}
+
+func (x pathAlt) nullable() bool {
+	for _, sub := range x {
+		if sub.nullable() {
+			return true
+		}
+	}
+	return false
+}
+
+type pathTokenKind int
+
+const (
+	pathEOF pathTokenKind = iota
+	pathName
+	pathIRI
+	pathPunct
+)
+
+type pathToken struct {
+	kind pathTokenKind
+	text string
+	pos  int
+}
+
+// is returns true if the token is the given punctuation.
+func (t pathToken) is(punct string) bool {
+	return t.kind == pathPunct && t.text == punct
+}
+
+// String returns a description of the token, for use in error messages.
+func (t pathToken) String() string {
+	if t.kind == pathEOF {
+		return "end of path"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// pathParser parses property paths, by recursive descent.
+type pathParser struct {
+	src      []rune
+	pos      int
+	peeked   *pathToken
+	prefixes map[string]string
+}
+
+func (p *pathParser) errorf(tok pathToken, format string, args ...interface{}) error {
+	return fmt.Errorf("property path syntax error at offset %d: %s", tok.pos, fmt.Sprintf(format, args...))
+}
+
+// alternative parses: sequence ('|' sequence)*
+func (p *pathParser) alternative() (pathExpr, error) {
+	var alt pathAlt
+	for {
+		x, err := p.sequence()
+		if err != nil {
+			return nil, err
+		}
+		alt = append(alt, x)
+		if !p.peek().is("|") {
+			break
+		}
+		p.next()
+	}
+	if len(alt) == 1 {
+		return alt[0], nil
+	}
+	return alt, nil
+}
+
+// sequence parses: element ('/' element)*
+func (p *pathParser) sequence() (pathExpr, error) {
+	var seq pathSeq
+	for {
+		x, err := p.element()
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, x)
+		if !p.peek().is("/") {
+			break
+		}
+		p.next()
+	}
+	if len(seq) == 1 {
+		return seq[0], nil
+	}
+	return seq, nil
+}
+
+// element parses: '^'? primary ('*' | '+' | '?')?
+func (p *pathParser) element() (pathExpr, error) {
+	inverse := false
+	if p.peek().is("^") {
+		p.next()
+		inverse = true
+	}
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := p.peek(); {
+	case tok.is("*"):
+		x = pathRepeat{sub: x, min: 0, many: true}
+	case tok.is("+"):
+		x = pathRepeat{sub: x, min: 1, many: true}
+	case tok.is("?"):
+		x = pathRepeat{sub: x, min: 0}
+	}
+	if _, ok := x.(pathRepeat); ok {
+		p.next()
+	}
+	if inverse {
+		x = pathInverse{x}
+	}
+	return x, nil
+}
+
+// primary parses: iri | '!' negated | '(' alternative ')'
+func (p *pathParser) primary() (pathExpr, error) {
+	tok := p.next()
+	switch {
+	case tok.is("("):
+		x, err := p.alternative()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); !tok.is(")") {
+			return nil, p.errorf(tok, "expected \")\", got %v", tok)
+		}
+		return x, nil
+	case tok.is("!"):
+		return p.negated()
+	}
+	iri, err := p.iri(tok)
+	if err != nil {
+		return nil, err
+	}
+	return pathLink{iri}, nil
+}
+
+// negated parses the set of a negated path: one | '(' one ('|' one)* ')',
+// where one is: '^'? iri
+func (p *pathParser) negated() (pathExpr, error) {
+	var x pathNegated
+	one := func() error {
+		tok := p.next()
+		inverse := tok.is("^")
+		if inverse {
+			tok = p.next()
+		}
+		iri, err := p.iri(tok)
+		if err != nil {
+			return err
+		}
+		if inverse {
+			x.inv = append(x.inv, iri)
+		} else {
+			x.fwd = append(x.fwd, iri)
+		}
+		return nil
+	}
+	if !p.peek().is("(") {
+		if err := one(); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}
+	p.next()
+	for {
+		if err := one(); err != nil {
+			return nil, err
+		}
+		tok := p.next()
+		if tok.is(")") {
+			return x, nil
+		}
+		if !tok.is("|") {
+			return nil, p.errorf(tok, "expected \"|\" or \")\", got %v", tok)
+		}
+	}
+}
+
+// iri returns the predicate IRI for the given token.
+func (p *pathParser) iri(tok pathToken) (string, error) {
+	switch tok.kind {
+	case pathIRI:
+		return tok.text, nil
+	case pathName:
+		if tok.text == "a" {
+			return RDFType, nil
+		}
+		if iri, ok := expandPrefixedName(tok.text, p.prefixes, pathPrefixes); ok {
+			return iri, nil
+		}
+		return tok.text, nil
+	}
+	return "", p.errorf(tok, "expected predicate, got %v", tok)
+}
+
+func (p *pathParser) peek() pathToken {
+	if p.peeked == nil {
+		t := p.scan()
+		p.peeked = &t
+	}
+	return *p.peeked
+}
+
+func (p *pathParser) next() pathToken {
+	t := p.peek()
+	p.peeked = nil
+	return t
+}
+
+func (p *pathParser) scan() pathToken {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		return pathToken{pathEOF, "", start}
+	}
+	c := p.src[p.pos]
+	switch {
+	case c == '<':
+		iri, end, err := scanIRIRef(p.src, p.pos)
+		p.pos = end
+		if err != nil {
+			return pathToken{pathPunct, "<", start}
+		}
+		return pathToken{pathIRI, iri, start}
+	case strings.ContainsRune("()|/^*+?!", c):
+		p.pos++
+		return pathToken{pathPunct, string(c), start}
+	}
+	for p.pos < len(p.src) && isPathNameRune(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		// An unexpected character.
+		p.pos++
+		return pathToken{pathPunct, string(c), start}
+	}
+	return pathToken{pathName, string(p.src[start:p.pos]), start}
+}
+
+// isPathNameRune returns true if the given rune may appear
+// within a bare or prefixed name.
+func isPathNameRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("_-:.%#", c)
+}
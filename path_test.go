@@ -0,0 +1,112 @@
+package store4_test
+
+import (
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Property paths", func() {
+
+	var g *GraphView
+
+	BeforeEach(func() {
+		g = NewGraph([][3]string{
+			{"alice", "knows", "bob"},
+			{"bob", "knows", "carol"},
+			{"carol", "knows", "alice"},
+			{"carol", "knows", "dave"},
+			{"alice", "parent", "erin"},
+			{"frank", "parent", "erin"},
+			{"frank", "child", "gina"},
+			{"alice", RDFType, "Person"},
+			{"alice", "likes", "bob"},
+		})
+		g.Add("alice", "age", 42)
+	})
+
+	path := func(text string) *PropertyPath {
+		p, err := ParsePropertyPath(text, map[string]string{"ex": "http://example.org/"})
+		Expect(err).NotTo(HaveOccurred())
+		return p
+	}
+
+	It("should follow a single predicate", func() {
+		Expect(g.FindObjectsByPath("alice", path("knows"))).To(ConsistOf("bob"))
+		Expect(g.FindObjectsByPath("alice", path("<knows>"))).To(ConsistOf("bob"))
+		Expect(g.FindObjectsByPath("alice", path(`<\u006Bnows>`))).To(ConsistOf("bob"))
+		Expect(g.FindObjectsByPath("alice", path("a"))).To(ConsistOf("Person"))
+		Expect(g.FindObjectsByPath("alice", path("rdf:type"))).To(ConsistOf("Person"))
+	})
+
+	It("should follow one or more repetitions, handling cycles", func() {
+		Expect(g.FindObjectsByPath("alice", path("knows+"))).To(ConsistOf("alice", "bob", "carol", "dave"))
+		Expect(g.FindObjectsByPath("dave", path("knows+"))).To(BeEmpty())
+	})
+
+	It("should follow zero or more repetitions", func() {
+		Expect(g.FindObjectsByPath("dave", path("knows*"))).To(ConsistOf("dave"))
+		Expect(g.FindObjectsByPath("bob", path("knows*"))).To(ConsistOf("alice", "bob", "carol", "dave"))
+		Expect(g.FindObjectsByPath("nobody", path("knows*"))).To(ConsistOf("nobody"))
+	})
+
+	It("should follow zero or one repetition", func() {
+		Expect(g.FindObjectsByPath("alice", path("knows?"))).To(ConsistOf("alice", "bob"))
+	})
+
+	It("should follow inverse and sequence paths", func() {
+		Expect(g.FindObjectsByPath("alice", path("parent/^parent"))).To(ConsistOf("alice", "frank"))
+		Expect(g.FindObjectsByPath("alice", path("parent/^parent/child"))).To(ConsistOf("gina"))
+		Expect(g.FindObjectsByPath("gina", path("^(parent/^parent/child)"))).To(ConsistOf("alice", "frank"))
+		Expect(g.FindObjectsByPath("erin", path("^parent/child"))).To(ConsistOf("gina"))
+	})
+
+	It("should follow alternative paths", func() {
+		Expect(g.FindObjectsByPath("alice", path("parent|knows"))).To(ConsistOf("bob", "erin"))
+		Expect(g.FindObjectsByPath("alice", path("(knows|parent)*"))).To(ConsistOf("alice", "bob", "carol", "dave", "erin"))
+	})
+
+	It("should follow negated paths", func() {
+		Expect(g.FindObjectsByPath("alice", path("!rdf:type"))).To(ConsistOf("bob", "erin", 42))
+		Expect(g.FindObjectsByPath("alice", path("!(a|knows|likes)"))).To(ConsistOf("erin", 42))
+		Expect(g.FindObjectsByPath("erin", path("!(^knows)"))).To(ConsistOf("alice", "frank"))
+		Expect(g.FindObjectsByPath("erin", path("!(^parent)"))).To(BeEmpty())
+		Expect(g.FindObjectsByPath("bob", path("!(knows|^likes)"))).To(ConsistOf("alice"))
+	})
+
+	It("should find subjects by path", func() {
+		Expect(g.FindSubjectsByPath(path("knows+"), "dave")).To(ConsistOf("alice", "bob", "carol"))
+		Expect(g.FindSubjectsByPath(path("parent/^parent"), "frank")).To(ConsistOf("alice", "frank"))
+		Expect(g.FindSubjectsByPath(path("age"), 42)).To(ConsistOf("alice"))
+		Expect(g.FindSubjectsByPath(path("age?"), 42)).To(ConsistOf("alice"))
+		Expect(g.FindSubjectsByPath(path("!(age)"), "bob")).To(ConsistOf("alice"))
+	})
+
+	It("should evaluate over all graphs", func() {
+		store := NewQuadStore([]*Quad{
+			{"a", "p", "b", "g1"},
+			{"b", "p", "c", "g2"},
+		})
+		p := path("p+")
+		Expect(store.FindObjectsByPath("a", p, "*")).To(ConsistOf("b", "c"))
+		Expect(store.FindObjectsByPath("a", p, "g1")).To(ConsistOf("b"))
+	})
+
+	It("should expand prefixed names", func() {
+		g.Add("alice", "http://example.org/p", "x")
+		Expect(g.FindObjectsByPath("alice", path("ex:p"))).To(ConsistOf("x"))
+		Expect(path("ex:p").String()).To(Equal("ex:p"))
+	})
+
+	It("should report syntax errors", func() {
+		_, err := ParsePropertyPath("knows/", nil)
+		Expect(err).To(MatchError("property path syntax error at offset 6: expected predicate, got end of path"))
+		_, err = ParsePropertyPath("(knows", nil)
+		Expect(err).To(MatchError(`property path syntax error at offset 6: expected ")", got end of path`))
+		_, err = ParsePropertyPath("knows knows", nil)
+		Expect(err).To(MatchError(`property path syntax error at offset 6: unexpected "knows"`))
+		_, err = ParsePropertyPath("!(a b)", nil)
+		Expect(err).To(MatchError(`property path syntax error at offset 4: expected "|" or ")", got "b"`))
+	})
+})
@@ -14,6 +14,10 @@ type pool struct {
 	idToItemInfo map[uint64]*itemInfo
 	// nextItemID holds the next non-string ID to issue.
 	nextItemID uint64
+
+	// bulk is true while string info is allocated in blocks, from spare.
+	bulk  bool
+	spare []strInfo
 }
 
 func newPool() *pool {
@@ -33,6 +37,39 @@ func newPool() *pool {
 	return s
 }
 
+// reserve prepares the pool for a bulk operation adding up to n new
+// strings: its maps are presized, and string info is allocated in blocks
+// until release is called. The maps are only rebuilt when at least
+// doubling in size, as otherwise copying costs more than growth saves.
+func (s *pool) reserve(n int) {
+	if n <= 0 {
+		return
+	}
+	if m := len(s.idToStrInfo); n >= m {
+		strToID := make(map[string]uint64, m+n+1)
+		for str, id := range s.strToID {
+			strToID[str] = id
+		}
+		idToStrInfo := make(map[uint64]*strInfo, m+n)
+		for id, info := range s.idToStrInfo {
+			idToStrInfo[id] = info
+		}
+		s.strToID = strToID
+		s.idToStrInfo = idToStrInfo
+	}
+	s.bulk = true
+}
+
+// release ends a bulk operation, dropping any unused string info.
+func (s *pool) release() {
+	s.spare = nil
+	s.bulk = false
+}
+
+// strInfoBlockSize is the number of string infos allocated together
+// during bulk operations.
+const strInfoBlockSize = 256
+
 // strInfo holds details for each string.
 type strInfo struct {
 	str      string // The string itself.
@@ -91,10 +128,19 @@ func (s *pool) getOrCreateIDString(str string) uint64 {
 		id = s.nextStrID
 		s.nextStrID++
 		s.strToID[str] = id
-		s.idToStrInfo[id] = &strInfo{
-			str:      str,
-			refCount: 1,
+		var info *strInfo
+		if s.bulk {
+			if len(s.spare) == 0 {
+				s.spare = make([]strInfo, strInfoBlockSize)
+			}
+			info = &s.spare[0]
+			s.spare = s.spare[1:]
+		} else {
+			info = &strInfo{}
 		}
+		info.str = str
+		info.refCount = 1
+		s.idToStrInfo[id] = info
 	}
 	return id
 }
@@ -153,3 +199,13 @@ func (s *pool) releaseRefAny(id uint64) {
 	}
 	info.refCount = c
 }
+
+// addRef increments the reference count of the given ID.
+// The given ID must exist.
+func (s *pool) addRef(id uint64) {
+	if id&(1<<63) == 0 {
+		s.idToStrInfo[id].refCount++
+		return
+	}
+	s.idToItemInfo[id].refCount++
+}
@@ -0,0 +1,270 @@
+package store4
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// PairCallbackFn is the function signature used to implement
+// callback functions that receive a subject-object pair.
+//
+// Used with calls to PredicateView's ForEach.
+type PairCallbackFn func(s string, o interface{})
+
+// PredicateView provides a column-oriented API for working with
+// the subject-object pairs of a single predicate, in the manner
+// of a table column holding a value for each subject.
+//
+// PredicateView reads pairs directly from its QuadStore's POS index.
+//
+// Returned by calls to PredicateView on both QuadStore and GraphView.
+type PredicateView struct {
+	Predicate string
+	Graph     string
+	QuadStore *QuadStore
+}
+
+// PredicateView returns a PredicateView for the given predicate and graph.
+//
+// Passing "*" (an asterisk) for the graph views pairs in all graphs.
+func (s *QuadStore) PredicateView(predicate, graph string) *PredicateView {
+	v := &PredicateView{
+		Predicate: predicate,
+		Graph:     graph,
+		QuadStore: s,
+	}
+	return v
+}
+
+// PredicateView returns a PredicateView for the given predicate.
+func (g *GraphView) PredicateView(predicate string) *PredicateView {
+	return g.QuadStore.PredicateView(predicate, g.Graph)
+}
+
+// ForEach executes the given callback once for each subject-object pair
+// in the PredicateView. If the view spans several graphs, then a pair
+// held in more than one graph is passed once for each.
+func (v *PredicateView) ForEach(fn PairCallbackFn) {
+	s := v.QuadStore
+	v.forEachID(func(oid, sid uint64) {
+		fn(s.pool.idToString(sid), s.pool.idToAny(oid))
+	})
+}
+
+// Map returns a map containing the subject terms of the PredicateView,
+// mapped to their corresponding distinct object terms.
+func (v *PredicateView) Map() map[string][]interface{} {
+	s := v.QuadStore
+	m := make(map[string][]interface{})
+	v.forEachDistinctID(func(oid, sid uint64) {
+		subject := s.pool.idToString(sid)
+		m[subject] = append(m[subject], s.pool.idToAny(oid))
+	})
+	return m
+}
+
+// Invert returns a map containing the object terms of the PredicateView,
+// mapped to their corresponding distinct subject terms.
+func (v *PredicateView) Invert() map[interface{}][]string {
+	s := v.QuadStore
+	m := make(map[interface{}][]string)
+	v.forEachDistinctID(func(oid, sid uint64) {
+		object := s.pool.idToAny(oid)
+		m[object] = append(m[object], s.pool.idToString(sid))
+	})
+	return m
+}
+
+// Count returns a count of pairs in the PredicateView that match the given pattern.
+//
+// Passing "*" (an asterisk) for any parameter acts as a
+// match-everything wildcard for that term.
+func (v *PredicateView) Count(subject string, object interface{}) uint64 {
+	return v.QuadStore.Count(subject, v.Predicate, object, v.Graph)
+}
+
+// Size returns the total count of pairs in the PredicateView.
+func (v *PredicateView) Size() uint64 {
+	return v.QuadStore.Count("*", v.Predicate, "*", v.Graph)
+}
+
+// Empty returns true if the PredicateView has no contents.
+func (v *PredicateView) Empty() bool {
+	return v.Size() == 0
+}
+
+// Subjects returns a list of the distinct subject terms in the PredicateView.
+func (v *PredicateView) Subjects() []string {
+	return v.QuadStore.FindSubjects(v.Predicate, "*", v.Graph)
+}
+
+// Objects returns a list of the distinct object terms in the PredicateView.
+func (v *PredicateView) Objects() []interface{} {
+	s := v.QuadStore
+	seen := make(map[uint64]struct{})
+	var out []interface{}
+	v.forEachID(func(oid, sid uint64) {
+		if _, ok := seen[oid]; ok {
+			return
+		}
+		seen[oid] = struct{}{}
+		out = append(out, s.pool.idToAny(oid))
+	})
+	return out
+}
+
+// Histogram returns a map of the distinct object terms in the PredicateView
+// to the count of distinct subjects having each.
+func (v *PredicateView) Histogram() map[interface{}]uint64 {
+	m := make(map[interface{}]uint64)
+	for object, subjects := range v.Invert() {
+		m[object] = uint64(len(subjects))
+	}
+	return m
+}
+
+// Sum returns the sum of the numeric object terms in the PredicateView,
+// and their count. Objects that are not numbers are ignored, and a pair
+// held in more than one graph is counted once. Literals with numeric
+// datatypes are taken as numbers.
+func (v *PredicateView) Sum() (float64, uint64) {
+	s := v.QuadStore
+	var sum float64
+	var n uint64
+	v.forEachDistinctID(func(oid, sid uint64) {
+		if f, ok := numericValue(s.pool.idToAny(oid)); ok {
+			sum += f
+			n++
+		}
+	})
+	return sum, n
+}
+
+// Average returns the mean of the numeric object terms in the PredicateView.
+// Objects that are not numbers are ignored. Returns false if there are none.
+func (v *PredicateView) Average() (float64, bool) {
+	sum, n := v.Sum()
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+// Min returns the least of the numeric object terms in the PredicateView.
+// Objects that are not numbers are ignored. Returns false if there are none.
+func (v *PredicateView) Min() (float64, bool) {
+	return v.extreme(-1)
+}
+
+// Max returns the greatest of the numeric object terms in the PredicateView.
+// Objects that are not numbers are ignored. Returns false if there are none.
+func (v *PredicateView) Max() (float64, bool) {
+	return v.extreme(1)
+}
+
+// Add a quad to the underlying QuadStore,
+// with the given subject and object values
+// and this PredicateView's Predicate and Graph values.
+// Returns true if the quad was a new quad,
+// or false if the quad already existed.
+//
+// If any of the given terms are "*" (an asterisk),
+// then this method will panic. (The asterisk is reserved
+// for wildcard operations throughout the API).
+func (v *PredicateView) Add(subject string, object interface{}) bool {
+	return v.QuadStore.Add(subject, v.Predicate, object, v.Graph)
+}
+
+// Remove quads from the underlying QuadStore,
+// with the given subject and object values
+// and this PredicateView's Predicate and Graph values.
+// Returns the number of quads removed.
+//
+// Passing "*" (an asterisk) for any parameter acts as a
+// match-everything wildcard for that term.
+func (v *PredicateView) Remove(subject string, object interface{}) uint64 {
+	return v.QuadStore.Remove(subject, v.Predicate, object, v.Graph)
+}
+
+// String returns the contents of the PredicateView in a human-readable format.
+func (v *PredicateView) String() string {
+	var buf bytes.Buffer
+	graph := v.Graph
+	if len(graph) > 0 {
+		buf.WriteString(graph)
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(v.Predicate)
+	buf.WriteByte('\n')
+	m := v.Map()
+	subjects := make([]string, 0, len(m))
+	for subject := range m {
+		subjects = append(subjects, subject)
+	}
+	sort.Strings(subjects)
+	for _, subject := range subjects {
+		objects := m[subject]
+		sortObjects(objects)
+		for _, object := range objects {
+			buf.WriteByte('[')
+			buf.WriteString(subject)
+			buf.WriteByte(' ')
+			buf.WriteString(fmt.Sprint(object))
+			buf.WriteByte(']')
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.String()
+}
+
+// extreme returns the least numeric object term if sign is -1,
+// or the greatest if sign is 1.
+func (v *PredicateView) extreme(sign int) (float64, bool) {
+	s := v.QuadStore
+	var out float64
+	found := false
+	v.forEachDistinctID(func(oid, sid uint64) {
+		f, ok := numericValue(s.pool.idToAny(oid))
+		if !ok {
+			return
+		}
+		if !found || sign < 0 && f < out || sign > 0 && f > out {
+			out = f
+			found = true
+		}
+	})
+	return out, found
+}
+
+// forEachID calls the given function with the IDs of the object and
+// subject of each pair in the view, reading them from the POS index.
+func (v *PredicateView) forEachID(fn func(oid, sid uint64)) {
+	s := v.QuadStore
+	pid, ok := s.pool.stringToID(v.Predicate)
+	if !ok {
+		return
+	}
+	s.graphs.forEachMatch(v.Graph, func(graph string, g *indexedGraph) {
+		g.posIndex.forEachMatch(pid, func(_ uint64, objects indexMid) {
+			for oid, subjects := range objects {
+				for sid := range subjects {
+					fn(oid, sid)
+				}
+			}
+		})
+	})
+}
+
+// forEachDistinctID is like forEachID, but calls the given function
+// only once for a pair held in more than one graph.
+func (v *PredicateView) forEachDistinctID(fn func(oid, sid uint64)) {
+	seen := make(map[[2]uint64]struct{})
+	v.forEachID(func(oid, sid uint64) {
+		if _, ok := seen[[2]uint64{sid, oid}]; ok {
+			return
+		}
+		seen[[2]uint64{sid, oid}] = struct{}{}
+		fn(oid, sid)
+	})
+}
@@ -0,0 +1,175 @@
+package store4_test
+
+import (
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type Pair struct {
+	S string
+	O interface{}
+}
+
+var _ = Describe("PredicateView", func() {
+
+	var store *QuadStore
+
+	BeforeEach(func() {
+		store = NewQuadStore()
+		store.Add("alice", "age", 30, "")
+		store.Add("bob", "age", 40, "")
+		store.Add("carol", "age", 30, "")
+		store.Add("carol", "age", "thirty", "")
+		store.Add("dave", "age", 50.5, "c4")
+		store.Add("bob", "age", 40, "c4")
+		store.Add("alice", "name", "Alice", "")
+	})
+
+	Describe("Creating a PredicateView", func() {
+
+		It("should view pairs with the predicate in the graph", func() {
+			view := store.PredicateView("age", "")
+			var results []Pair
+			view.ForEach(func(s string, o interface{}) {
+				results = append(results, Pair{s, o})
+			})
+			Expect(results).To(ConsistOf([]Pair{
+				{"alice", 30},
+				{"bob", 40},
+				{"carol", 30},
+				{"carol", "thirty"},
+			}))
+			Expect(view.Size()).To(BeEquivalentTo(4))
+			Expect(view.Empty()).To(BeFalse())
+		})
+
+		It("should view pairs in all graphs", func() {
+			view := store.PredicateView("age", "*")
+			Expect(view.Size()).To(BeEquivalentTo(6))
+			Expect(view.Subjects()).To(ConsistOf("alice", "bob", "carol", "dave"))
+		})
+
+		It("should be available from a GraphView", func() {
+			view := store.GraphView("c4").PredicateView("age")
+			Expect(view.Map()).To(Equal(map[string][]interface{}{
+				"bob":  {40},
+				"dave": {50.5},
+			}))
+		})
+
+		It("should be empty for a missing predicate", func() {
+			view := store.PredicateView("missing", "")
+			Expect(view.Empty()).To(BeTrue())
+			Expect(view.Map()).To(BeEmpty())
+			Expect(view.Objects()).To(BeEmpty())
+		})
+	})
+
+	Describe("Reading columns", func() {
+
+		var view *PredicateView
+
+		BeforeEach(func() {
+			view = store.PredicateView("age", "*")
+		})
+
+		It("should map subjects to distinct objects", func() {
+			m := view.Map()
+			Expect(m).To(HaveLen(4))
+			Expect(m["bob"]).To(Equal([]interface{}{40}))
+			Expect(m["carol"]).To(ConsistOf(30, "thirty"))
+		})
+
+		It("should map objects to distinct subjects", func() {
+			m := view.Invert()
+			Expect(m).To(HaveLen(4))
+			Expect(m[30]).To(ConsistOf("alice", "carol"))
+			Expect(m[40]).To(Equal([]string{"bob"}))
+		})
+
+		It("should list distinct objects", func() {
+			Expect(view.Objects()).To(ConsistOf(30, 40, 50.5, "thirty"))
+		})
+
+		It("should count matching pairs", func() {
+			Expect(view.Count("*", 30)).To(BeEquivalentTo(2))
+			Expect(view.Count("bob", "*")).To(BeEquivalentTo(2))
+		})
+
+		It("should count subjects for each object", func() {
+			Expect(view.Histogram()).To(Equal(map[interface{}]uint64{
+				30: 2, 40: 1, 50.5: 1, "thirty": 1,
+			}))
+		})
+	})
+
+	Describe("Aggregating", func() {
+
+		It("should aggregate numeric objects", func() {
+			view := store.PredicateView("age", "")
+			sum, n := view.Sum()
+			Expect(sum).To(Equal(100.0))
+			Expect(n).To(BeEquivalentTo(3))
+			avg, ok := view.Average()
+			Expect(ok).To(BeTrue())
+			Expect(avg).To(BeNumerically("~", 100.0/3))
+			min, _ := view.Min()
+			Expect(min).To(Equal(30.0))
+			max, _ := store.PredicateView("age", "*").Max()
+			Expect(max).To(Equal(50.5))
+		})
+
+		It("should aggregate each pair once across graphs", func() {
+			view := store.PredicateView("age", "*")
+			sum, n := view.Sum()
+			Expect(sum).To(Equal(150.5))
+			Expect(n).To(BeEquivalentTo(4))
+			avg, _ := view.Average()
+			Expect(avg).To(Equal(150.5 / 4))
+		})
+
+		It("should aggregate Literals with numeric datatypes", func() {
+			store.Add("erin", "age", Literal{Value: "20", Datatype: XSDNamespace + "integer"}, "")
+			store.Add("frank", "age", Literal{Value: "70.5", Datatype: XSDNamespace + "decimal"}, "")
+			store.Add("gina", "age", Literal{Value: "10", Language: "en"}, "")
+			view := store.PredicateView("age", "")
+			sum, n := view.Sum()
+			Expect(sum).To(Equal(190.5))
+			Expect(n).To(BeEquivalentTo(5))
+			min, _ := view.Min()
+			Expect(min).To(Equal(20.0))
+			max, _ := view.Max()
+			Expect(max).To(Equal(70.5))
+		})
+
+		It("should report when there are no numeric objects", func() {
+			view := store.PredicateView("name", "")
+			_, ok := view.Average()
+			Expect(ok).To(BeFalse())
+			_, ok = view.Min()
+			Expect(ok).To(BeFalse())
+			_, ok = view.Max()
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("Modifying via a PredicateView", func() {
+
+		It("should add and remove pairs", func() {
+			view := store.PredicateView("age", "")
+			Expect(view.Add("erin", 25)).To(BeTrue())
+			Expect(store.Count("erin", "age", 25, "")).To(BeEquivalentTo(1))
+			Expect(view.Remove("carol", "*")).To(BeEquivalentTo(2))
+			Expect(view.Subjects()).To(ConsistOf("alice", "bob", "erin"))
+		})
+	})
+
+	Describe("String", func() {
+
+		It("should list pairs in order", func() {
+			Expect(store.PredicateView("age", "c4").String()).To(Equal("c4\nage\n[bob 40]\n[dave 50.5]\n"))
+		})
+	})
+})
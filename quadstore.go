@@ -2,6 +2,7 @@ package store4
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"reflect"
 	"sort"
@@ -22,12 +23,29 @@ type QuadCallbackFn func(s, p string, o interface{}, g string)
 // type QuadTestFn func(s, p, o, g string) bool
 type QuadTestFn func(s, p string, o interface{}, g string) bool
 
+// QuadValidateFn is the function signature used to implement
+// callback functions that validate a quad.
+// A non-nil response means that the quad has been rejected.
+//
+// Used with QuadStore's BeforeAdd and BeforeRemove hooks.
+type QuadValidateFn func(s, p string, o interface{}, g string) error
+
+// ErrWildcardTerm is returned by TryAdd when any of the given
+// terms are "*" (an asterisk), which is reserved for wildcard operations.
+var ErrWildcardTerm = errors.New("unexpected use of wildcard '*' for term")
+
 // StringCallbackFn is the function signature used to implement
 // callback functions that receive a string.
 //
 // Used with calls to FindSubjects, FindPredicates and FindGraphs.
 type StringCallbackFn func(s string)
 
+// BatchCallbackFn is the function signature used to implement
+// callback functions that receive a batch of changes.
+//
+// Used with QuadStore's OnBatch hook.
+type BatchCallbackFn func(changes []Change)
+
 // ObjectCallbackFn is the function signature used to implement
 // callback functions that receive an object.
 //
@@ -52,13 +70,50 @@ type ObjectCallbackFn func(o interface{})
 // have been decremented, and all internal indexes will be
 // in a consistent state — so it is safe to remove further quads (or add
 // quads) from within the callback, should one wish to do so.
+//
+// If provided, the BeforeAdd callback will be called once for every
+// new quad, before it is added to the store. It is not called if the
+// quad already exists in the store. If the callback returns an error,
+// the quad is rejected, and the store is left untouched.
+//
+// Likewise: if provided, the BeforeRemove callback will be called once
+// for every quad matching a call to Remove, before anything is removed
+// from the store. If the callback returns an error for any of the quads,
+// then none of the quads are removed.
+//
+// Add and Remove panic when a quad is rejected — use TryAdd and
+// TryRemove to receive the error instead.
 type QuadStore struct {
+	// BeforeAdd is called whenever a new quad is about to be added
+	// to the store (pre-addition), and may reject the quad.
+	BeforeAdd QuadValidateFn
+	// BeforeRemove is called whenever a quad is about to be removed
+	// from the store (pre-removal), and may reject the removal.
+	BeforeRemove QuadValidateFn
 	// OnAdd is called whenever a new quad is added to the store
 	// (post-addition).
 	OnAdd QuadCallbackFn
 	// OnRemove is called whenever a quad is removed from the store
 	// (post-removal).
 	OnRemove QuadCallbackFn
+	// OnBatch is called once for each call to AddAll or RemoveAll
+	// (post-batch), with the changes made by the call.
+	OnBatch BatchCallbackFn
+	// subscriptions hold the registered change handlers, in registration order.
+	subscriptions []*subscription
+	// journal records changes, if enabled.
+	journal *journal
+	// reachability holds the attached reachability indexes.
+	reachability []*ReachabilityIndex
+	// nextBlankNode numbers the blank nodes created by Marshal.
+	nextBlankNode uint64
+	// batch holds the changes made by the current AddAll or RemoveAll call,
+	// if there is an OnBatch callback to report them to.
+	batch *[]Change
+	// batchDepth counts the bulk operations in progress.
+	batchDepth int
+	// sizeHint is used to presize graphs during bulk operations.
+	sizeHint int
 	// size is a count of quads in the store.
 	size uint64
 	// graphs hold the store's graphs.
@@ -74,12 +129,44 @@ type graphMap map[string]*indexedGraph
 // held only in the indexes, which are indexed
 // three ways: SPO, POS and OSP.
 type indexedGraph struct {
-	size     uint64
+	size uint64
+	// explicit is true if the graph was created by CreateGraph,
+	// in which case it is kept when empty.
+	explicit bool
 	spoIndex indexRoot
 	posIndex indexRoot
 	ospIndex indexRoot
 }
 
+// newIndexedGraph returns a new empty graph,
+// with its indexes presized for the given number of triples.
+func newIndexedGraph(hint int) *indexedGraph {
+	return &indexedGraph{
+		spoIndex: make(indexRoot, hint),
+		posIndex: make(indexRoot),
+		ospIndex: make(indexRoot, hint),
+	}
+}
+
+// reserve presizes the graph's indexes for the given number of new triples.
+// As with pool's reserve, the indexes are only rebuilt when at least doubling in size.
+func (g *indexedGraph) reserve(n int) {
+	if n <= 0 || n < len(g.spoIndex) {
+		return
+	}
+	g.spoIndex = g.spoIndex.grow(n)
+	g.ospIndex = g.ospIndex.grow(n)
+}
+
+// grow returns a copy of the index, presized for n more keys.
+func (idx indexRoot) grow(n int) indexRoot {
+	r := make(indexRoot, len(idx)+n)
+	for k, v := range idx {
+		r[k] = v
+	}
+	return r
+}
+
 // index is map-based index consisting of three layers.
 type indexRoot map[uint64]indexMid
 type indexMid map[uint64]indexLeaf
@@ -137,33 +224,58 @@ func NewQuadStore(args ...interface{}) *QuadStore {
 		pool:   newPool(),
 	}
 	// Initialise store with any given data.
+	addFn := func(subject, predicate string, object interface{}, graph string) {
+		s.Add(subject, predicate, object, graph)
+	}
 	for _, arg := range args {
-		switch arg := arg.(type) {
-		default:
-			if !addQuadFromInterfaces(s, arg) {
-				initWithReflection(s, arg)
-			}
-		case [4]string:
-			// Single string quad.
-			s.Add(arg[0], arg[1], arg[2], arg[3])
-		case [3]string:
-			// Single string triple.
-			s.Add(arg[0], arg[1], arg[2], "")
-		case [][4]string:
-			// Slice of string quads.
-			for _, q := range arg {
-				s.Add(q[0], q[1], q[2], q[3])
-			}
-		case [][3]string:
-			// Slice of string triples.
-			for _, q := range arg {
-				s.Add(q[0], q[1], q[2], "")
-			}
-		}
+		forEachQuadArg(arg, addFn)
 	}
 	return s
 }
 
+// forEachQuadArg calls the given callback once for each quad
+// held in arg, which may be any of the types accepted by NewQuadStore.
+// It panics if the type of arg cannot be handled.
+func forEachQuadArg(arg interface{}, fn QuadCallbackFn) {
+	switch arg := arg.(type) {
+	default:
+		if !addQuadFromInterfaces(arg, fn) {
+			initWithReflection(arg, fn)
+		}
+	case [4]string:
+		// Single string quad.
+		fn(arg[0], arg[1], arg[2], arg[3])
+	case [3]string:
+		// Single string triple.
+		fn(arg[0], arg[1], arg[2], "")
+	case [][4]string:
+		// Slice of string quads.
+		for _, q := range arg {
+			fn(q[0], q[1], q[2], q[3])
+		}
+	case [][3]string:
+		// Slice of string triples.
+		for _, q := range arg {
+			fn(q[0], q[1], q[2], "")
+		}
+	}
+}
+
+// countQuadArg returns the count of quads held in arg,
+// which may be any of the types accepted by NewQuadStore.
+func countQuadArg(arg interface{}) int {
+	switch arg := arg.(type) {
+	case [][4]string:
+		return len(arg)
+	case [][3]string:
+		return len(arg)
+	}
+	if v := reflect.ValueOf(arg); v.Kind() == reflect.Slice {
+		return v.Len()
+	}
+	return 1
+}
+
 type tripler interface {
 	Subject() string
 	Predicate() string
@@ -184,26 +296,26 @@ type simplerGrapher interface {
 	G() string
 }
 
-func addQuadFromInterfaces(s *QuadStore, arg interface{}) bool {
+func addQuadFromInterfaces(arg interface{}, add QuadCallbackFn) bool {
 	gr := ""
 	if t, ok := arg.(tripler); ok {
 		if g, ok := arg.(grapher); ok {
 			gr = g.Graph()
 		}
-		s.Add(t.Subject(), t.Predicate(), t.Object(), gr)
+		add(t.Subject(), t.Predicate(), t.Object(), gr)
 		return true
 	}
 	if t, ok := arg.(simplerTripler); ok {
 		if g, ok := arg.(simplerGrapher); ok {
 			gr = g.G()
 		}
-		s.Add(t.S(), t.P(), t.O(), gr)
+		add(t.S(), t.P(), t.O(), gr)
 		return true
 	}
 	return false
 }
 
-func initWithReflection(s *QuadStore, arg interface{}) {
+func initWithReflection(arg interface{}, add QuadCallbackFn) {
 	m := make([]int, 4)
 	t := reflect.TypeOf(arg)
 	k := t.Kind()
@@ -212,13 +324,13 @@ func initWithReflection(s *QuadStore, arg interface{}) {
 		// Pointer to single quad- or triple-like struct.
 		t = reflect.Indirect(reflect.ValueOf(arg)).Type()
 		if findMappings(t, m) {
-			s.Add(quadFromStruct(arg, m))
+			add(quadFromStruct(arg, m))
 			return
 		}
 	case reflect.Struct:
 		// Single quad-like or triple-like struct.
 		if findMappings(t, m) {
-			s.Add(quadFromStruct(arg, m))
+			add(quadFromStruct(arg, m))
 			return
 		}
 	case reflect.Slice:
@@ -234,20 +346,20 @@ func initWithReflection(s *QuadStore, arg interface{}) {
 		el := val.Index(0)
 		iface := el.Interface()
 		t := reflect.Indirect(el).Type()
-		if addQuadFromInterfaces(s, iface) {
+		if addQuadFromInterfaces(iface, add) {
 			for i := 1; i < length; i++ {
 				el = val.Index(i)
 				iface = el.Interface()
-				addQuadFromInterfaces(s, iface)
+				addQuadFromInterfaces(iface, add)
 			}
 			return
 		}
 		if findMappings(t, m) {
-			s.Add(quadFromStruct(iface, m))
+			add(quadFromStruct(iface, m))
 			for i := 1; i < length; i++ {
 				el = val.Index(i)
 				iface = el.Interface()
-				s.Add(quadFromStruct(iface, m))
+				add(quadFromStruct(iface, m))
 			}
 			return
 		}
@@ -329,22 +441,46 @@ func (s *QuadStore) Empty() bool {
 //
 // If any of the given terms are "*" (an asterisk), then this method will panic.
 // (The asterisk is reserved for wildcard operations throughout the API).
+//
+// If the quad is rejected by the BeforeAdd callback, then this method
+// will panic with the returned error.
 func (s *QuadStore) Add(subject, predicate string, object interface{}, graph string) bool {
+	ok, err := s.TryAdd(subject, predicate, object, graph)
+	if err == ErrWildcardTerm {
+		// Callers may recover this panic value as a string.
+		panic("Unexpected use of wildcard '*' for term")
+	}
+	if err != nil {
+		panic(err)
+	}
+	return ok
+}
+
+// TryAdd adds a quad to the store. Returns true if the quad was a new quad,
+// or false if the quad already existed.
+//
+// Unlike Add, TryAdd does not panic: if any of the given terms are "*"
+// (an asterisk) then ErrWildcardTerm is returned, and if the quad is
+// rejected by the BeforeAdd callback then the callback's error is returned.
+// In both cases, the store is left untouched.
+func (s *QuadStore) TryAdd(subject, predicate string, object interface{}, graph string) (bool, error) {
 	// Disallow wildcard terms
 	// Optimisation: we check the other params after resolvng to IDs.
 	if graph == "*" {
-		panic("Unexpected use of wildcard '*' for term")
+		return false, ErrWildcardTerm
 	}
-	// Find the graph.
-	g, ok := s.graphs[graph]
-	// Create the graph if it doesn't exist yet.
-	if !ok {
-		g = &indexedGraph{
-			spoIndex: make(indexRoot),
-			posIndex: make(indexRoot),
-			ospIndex: make(indexRoot),
+	if s.BeforeAdd != nil {
+		// Validation must happen before the pool or indexes are modified,
+		// so here we check for wildcards and existing quads using lookups only.
+		if subject == "*" || predicate == "*" || object == "*" {
+			return false, ErrWildcardTerm
+		}
+		if s.contains(subject, predicate, object, graph) {
+			return false, nil
+		}
+		if err := s.BeforeAdd(subject, predicate, object, graph); err != nil {
+			return false, err
 		}
-		s.graphs[graph] = g
 	}
 	// Get internal IDs for each term.
 	sid := s.pool.getOrCreateIDString(subject)
@@ -355,25 +491,88 @@ func (s *QuadStore) Add(subject, predicate string, object interface{}, graph str
 	// the wildcard, so we avoid three extra string compares earlier in
 	// this function, and instead test for wildcards with numerics here.
 	if sid == 0 || pid == 0 || oid == 0 {
-		panic("Unexpected use of wildcard '*' for term")
+		// Release any references we took, leaving the pool untouched.
+		if sid != 0 {
+			s.pool.releaseRefString(sid)
+		}
+		if pid != 0 {
+			s.pool.releaseRefString(pid)
+		}
+		if oid != 0 {
+			s.pool.releaseRefAny(oid)
+		}
+		return false, ErrWildcardTerm
+	}
+	// Find the graph.
+	g, ok := s.graphs[graph]
+	// Create the graph if it doesn't exist yet.
+	if !ok {
+		g = newIndexedGraph(s.sizeHint)
+		s.graphs[graph] = g
+	} else if s.sizeHint > 0 {
+		g.reserve(s.sizeHint)
 	}
+	// Only presize the first graph added to in a batch, so that
+	// batches spanning many graphs do not over-allocate.
+	s.sizeHint = 0
 	// Add triple to all indexes.
 	if !addToIndex(g.spoIndex, sid, pid, oid) {
 		// Already existed.
 		s.pool.releaseRefString(sid)
 		s.pool.releaseRefString(pid)
 		s.pool.releaseRefAny(oid)
-		return false
+		return false, nil
 	}
 	addToIndex(g.posIndex, pid, oid, sid)
 	addToIndex(g.ospIndex, oid, sid, pid)
 	// Update size.
 	s.size++
 	g.size++
+	s.added(subject, predicate, object, graph)
+	return true, nil
+}
+
+// added reports a quad that has been added to the store to the journal,
+// the OnAdd callback, subscriptions and any current batch.
+func (s *QuadStore) added(subject, predicate string, object interface{}, graph string) {
+	if s.journal != nil {
+		// Changes made in reaction to this one are undone with it.
+		s.BeginGroup()
+		defer s.EndGroup()
+		s.journal.record(Change{Added, subject, predicate, object, graph})
+	}
 	if s.OnAdd != nil {
 		s.OnAdd(subject, predicate, object, graph)
 	}
-	return true
+	if len(s.subscriptions) > 0 {
+		s.notify(Change{Added, subject, predicate, object, graph})
+	}
+	if s.batch != nil {
+		*s.batch = append(*s.batch, Change{Added, subject, predicate, object, graph})
+	}
+}
+
+// observed returns true if changes to the store are reported anywhere,
+// and so must be resolved to terms.
+func (s *QuadStore) observed() bool {
+	return s.OnAdd != nil || s.OnRemove != nil || len(s.subscriptions) > 0 || s.batch != nil || s.journal != nil
+}
+
+// contains returns true if the store holds the given quad.
+// None of the given terms may be wildcards.
+func (s *QuadStore) contains(subject, predicate string, object interface{}, graph string) bool {
+	g, ok := s.graphs[graph]
+	if !ok {
+		return false
+	}
+	sid, sok := s.pool.stringToID(subject)
+	pid, pok := s.pool.stringToID(predicate)
+	oid, ook := s.pool.anyToID(object)
+	if !sok || !pok || !ook {
+		return false
+	}
+	_, ok = g.spoIndex[sid][pid][oid]
+	return ok
 }
 
 // addToIndex adds a triple to the given index,
@@ -401,7 +600,43 @@ func addToIndex(index0 indexRoot, key0, key1, key2 uint64) bool {
 //
 // Passing "*" (an asterisk) for any parameter acts as a
 // match-everything wildcard for that term.
+//
+// If any of the matching quads are rejected by the BeforeRemove callback,
+// then this method will panic with the returned error.
 func (s *QuadStore) Remove(subject, predicate string, object interface{}, graph string) uint64 {
+	count, err := s.TryRemove(subject, predicate, object, graph)
+	if err != nil {
+		panic(err)
+	}
+	return count
+}
+
+// TryRemove removes quads from the store. Returns the number of quads removed.
+//
+// Passing "*" (an asterisk) for any parameter acts as a
+// match-everything wildcard for that term.
+//
+// Unlike Remove, TryRemove does not panic: if any of the matching
+// quads are rejected by the BeforeRemove callback, then the callback's
+// error is returned, and no quads are removed.
+func (s *QuadStore) TryRemove(subject, predicate string, object interface{}, graph string) (uint64, error) {
+	if s.BeforeRemove != nil {
+		// Validate every matching quad before removing any of them.
+		var err error
+		s.SomeWith(subject, predicate, object, graph, func(s1, p1 string, o1 interface{}, g1 string) bool {
+			err = s.BeforeRemove(s1, p1, o1, g1)
+			return err != nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return s.remove(subject, predicate, object, graph), nil
+}
+
+// remove removes quads matching the given pattern from the store,
+// without validation. Returns the number of quads removed.
+func (s *QuadStore) remove(subject, predicate string, object interface{}, graph string) uint64 {
 	// Find internal identifiers for terms.
 	sid, sok := s.pool.stringToID(subject)
 	pid, pok := s.pool.stringToID(predicate)
@@ -411,6 +646,12 @@ func (s *QuadStore) Remove(subject, predicate string, object interface{}, graph
 		return 0
 	}
 
+	// A single call is undone as a single step.
+	if s.journal != nil {
+		s.BeginGroup()
+		defer s.EndGroup()
+	}
+
 	removeFromIndex := func(index0 indexRoot, key0, key1, key2 uint64, fn func(key0, key1, key2 uint64)) {
 		index0.forEachMatch(key0, func(key0 uint64, index1 indexMid) {
 			index1.forEachMatch(key1, func(key1 uint64, index2 indexLeaf) {
@@ -440,8 +681,8 @@ func (s *QuadStore) Remove(subject, predicate string, object interface{}, graph
 		removeFn := func(sid, pid, oid uint64) {
 			s.size--
 			g.size--
-			if s.OnRemove != nil {
-				s.OnRemove(s.pool.idToString(sid), s.pool.idToString(pid), s.pool.idToAny(oid), graph)
+			if s.observed() {
+				s.removed(s.pool.idToString(sid), s.pool.idToString(pid), s.pool.idToAny(oid), graph)
 			}
 			s.pool.releaseRefString(sid)
 			s.pool.releaseRefString(pid)
@@ -454,13 +695,30 @@ func (s *QuadStore) Remove(subject, predicate string, object interface{}, graph
 		removeFromIndex(g.ospIndex, oid, sid, pid, nil)
 		removeFromIndex(g.spoIndex, sid, pid, oid, removeFn)
 		// Cleanup empty graphs.
-		if g.size == 0 {
+		if g.size == 0 && !g.explicit {
 			delete(s.graphs, graph)
 		}
 	})
 	return count
 }
 
+// removed reports a quad that has been removed from the store to the
+// journal, the OnRemove callback, subscriptions and any current batch.
+func (s *QuadStore) removed(subject, predicate string, object interface{}, graph string) {
+	if s.journal != nil {
+		s.journal.record(Change{Removed, subject, predicate, object, graph})
+	}
+	if s.OnRemove != nil {
+		s.OnRemove(subject, predicate, object, graph)
+	}
+	if len(s.subscriptions) > 0 {
+		s.notify(Change{Removed, subject, predicate, object, graph})
+	}
+	if s.batch != nil {
+		*s.batch = append(*s.batch, Change{Removed, subject, predicate, object, graph})
+	}
+}
+
 // Inversion of control - the index buckets themselves
 // take care of any wilcards and call back as they need to.
 
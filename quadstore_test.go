@@ -1,6 +1,10 @@
 package store4_test
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	. "github.com/jimsmart/store4"
 
 	. "github.com/onsi/ginkgo"
@@ -473,6 +477,13 @@ var _ = Describe("QuadStore", func() {
 					Expect(func() { store.Add("s1", "p1", "o1", "*") }).To(Panic())
 				})
 			})
+
+			Context("with any wildcard term", func() {
+				It("should panic with a string", func() {
+					Expect(func() { store.Add("*", "p1", "o1", "") }).To(PanicWith("Unexpected use of wildcard '*' for term"))
+					Expect(func() { store.Add("s1", "p1", "o1", "*") }).To(PanicWith("Unexpected use of wildcard '*' for term"))
+				})
+			})
 		})
 
 	})
@@ -512,6 +523,32 @@ var _ = Describe("QuadStore", func() {
 		})
 	})
 
+	Describe("time object values", func() {
+		// Objects are the same if they are equal Go values, so times
+		// must also have the same location to be the same object.
+		utc := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		x := utc.In(time.FixedZone("X", 3600))
+		y := utc.In(time.FixedZone("Y", 3600))
+
+		It("should keep the same instant in different locations as different objects", func() {
+			store := NewQuadStore()
+			Expect(store.Add("s", "p", x, "")).To(BeTrue())
+			Expect(store.Add("s", "p", y, "")).To(BeTrue())
+			Expect(store.Add("s", "p", utc, "")).To(BeTrue())
+			Expect(store.Size()).To(Equal(uint64(3)))
+			Expect(store.FindObjects("s", "p", "")).To(ConsistOf(x, y, utc))
+			Expect(store.Remove("s", "p", y, "")).To(Equal(uint64(1)))
+			Expect(store.FindObjects("s", "p", "")).To(ConsistOf(x, utc))
+		})
+
+		It("should treat equal times as the same object", func() {
+			store := NewQuadStore()
+			Expect(store.Add("s", "p", utc, "")).To(BeTrue())
+			Expect(store.Add("s", "p", x.UTC(), "")).To(BeFalse())
+			Expect(store.Count("s", "p", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), "")).To(Equal(uint64(1)))
+		})
+	})
+
 	Describe("A QuadStore initialised with 3 elements", func() {
 		store := NewQuadStore([][3]string{
 			{"s1", "p1", "o1"},
@@ -2162,4 +2199,155 @@ var _ = Describe("QuadStore", func() {
 			Expect(count).To(Equal(2))
 		})
 	})
+
+	Describe("BeforeAdd", func() {
+
+		frozen := errors.New("graph is frozen")
+
+		newStore := func() *QuadStore {
+			store := NewQuadStore([]*Quad{
+				{"s1", "p1", "o1", ""},
+				{"s1", "p2", "o2", "frozen"},
+			})
+			store.BeforeAdd = func(s, p string, o interface{}, g string) error {
+				if g == "frozen" {
+					return frozen
+				}
+				if p == "age" {
+					if _, ok := o.(int); !ok {
+						return fmt.Errorf("age must be an int, got %T", o)
+					}
+				}
+				return nil
+			}
+			return store
+		}
+
+		It("should be called before adding a quad", func() {
+			store := newStore()
+			var size uint64
+			store.BeforeAdd = func(s, p string, o interface{}, g string) error {
+				size = store.Size()
+				return nil
+			}
+			Expect(store.Add("s2", "p1", "o1", "")).To(BeTrue())
+			Expect(size).To(Equal(uint64(2)))
+			Expect(store.Size()).To(Equal(uint64(3)))
+		})
+		It("should not be called when adding an already existing quad", func() {
+			store := newStore()
+			count := 0
+			store.BeforeAdd = func(s, p string, o interface{}, g string) error {
+				count++
+				return nil
+			}
+			Expect(store.Add("s1", "p1", "o1", "")).To(BeFalse())
+			Expect(count).To(Equal(0))
+		})
+		It("should cause Add to panic when rejecting a quad", func() {
+			store := newStore()
+			Expect(func() { store.Add("s2", "age", "old", "") }).To(PanicWith(MatchError("age must be an int, got string")))
+			Expect(store.Size()).To(Equal(uint64(2)))
+		})
+		It("should cause TryAdd to return the error when rejecting a quad", func() {
+			store := newStore()
+			ok, err := store.TryAdd("s2", "p1", "o1", "frozen")
+			Expect(ok).To(BeFalse())
+			Expect(err).To(Equal(frozen))
+		})
+		It("should leave the store untouched when rejecting a quad", func() {
+			store := newStore()
+			before := store.Stats()
+			_, err := store.TryAdd("s9", "p9", "o9", "frozen")
+			Expect(err).To(HaveOccurred())
+			Expect(store.Stats()).To(Equal(before))
+			Expect(store.FindGraphs("*", "*", "*")).To(ConsistOf("", "frozen"))
+		})
+		It("should allow quads that are accepted", func() {
+			store := newStore()
+			ok, err := store.TryAdd("s2", "age", 23, "")
+			Expect(ok).To(BeTrue())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store.Count("s2", "age", 23, "")).To(Equal(uint64(1)))
+		})
+		It("should cause TryAdd to return an error for wildcard terms", func() {
+			store := newStore()
+			for _, q := range [][4]string{
+				{"*", "p1", "o1", ""},
+				{"s1", "*", "o1", ""},
+				{"s1", "p1", "*", ""},
+				{"s1", "p1", "o1", "*"},
+			} {
+				_, err := store.TryAdd(q[0], q[1], q[2], q[3])
+				Expect(err).To(Equal(ErrWildcardTerm))
+			}
+			Expect(store.Size()).To(Equal(uint64(2)))
+		})
+	})
+
+	Describe("TryAdd", func() {
+		It("should return an error for wildcard terms, leaving the store untouched", func() {
+			store := NewQuadStore([4]string{"s1", "p1", "o1", ""})
+			before := store.Stats()
+			for _, q := range [][4]string{
+				{"*", "p2", "o2", "g2"},
+				{"s2", "*", "o2", "g2"},
+				{"s2", "p2", "*", "g2"},
+				{"s2", "p2", "o2", "*"},
+			} {
+				_, err := store.TryAdd(q[0], q[1], q[2], q[3])
+				Expect(err).To(Equal(ErrWildcardTerm))
+			}
+			Expect(store.Stats()).To(Equal(before))
+		})
+	})
+
+	Describe("BeforeRemove", func() {
+
+		newStore := func() *QuadStore {
+			store := NewQuadStore([]*Quad{
+				{"s1", "p1", "o1", ""},
+				{"s1", "p1", "o2", ""},
+				{"s1", "p2", "o2", "frozen"},
+				{"s2", "p1", "o1", "frozen"},
+			})
+			store.BeforeRemove = func(s, p string, o interface{}, g string) error {
+				if g == "frozen" {
+					return errors.New("graph is frozen")
+				}
+				return nil
+			}
+			return store
+		}
+
+		It("should be called for each matching quad", func() {
+			store := newStore()
+			count := 0
+			store.BeforeRemove = func(s, p string, o interface{}, g string) error {
+				count++
+				return nil
+			}
+			Expect(store.Remove("s1", "*", "*", "*")).To(Equal(uint64(3)))
+			Expect(count).To(Equal(3))
+		})
+		It("should cause Remove to panic when rejecting a quad", func() {
+			store := newStore()
+			Expect(func() { store.Remove("s1", "p2", "o2", "frozen") }).To(Panic())
+			Expect(store.Size()).To(Equal(uint64(4)))
+		})
+		It("should cause TryRemove to reject all quads if any are rejected", func() {
+			store := newStore()
+			n, err := store.TryRemove("s1", "*", "*", "*")
+			Expect(n).To(BeZero())
+			Expect(err).To(MatchError("graph is frozen"))
+			Expect(store.Size()).To(Equal(uint64(4)))
+		})
+		It("should allow removals that are accepted", func() {
+			store := newStore()
+			n, err := store.TryRemove("s1", "*", "*", "")
+			Expect(n).To(Equal(uint64(2)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store.Size()).To(Equal(uint64(2)))
+		})
+	})
 })
@@ -0,0 +1,309 @@
+package store4
+
+// RDFSReasoner materializes RDFS entailments for a QuadStore,
+// and keeps them up to date as the store changes.
+//
+// The reasoner implements the rdfs2, rdfs3, rdfs5, rdfs7, rdfs9
+// and rdfs11 entailment rules — which cover rdfs:domain, rdfs:range,
+// rdfs:subPropertyOf and rdfs:subClassOf. Inferred quads are held
+// in their own graph, so they can be told apart from asserted data.
+// The inferred graph holds every entailed triple, including any
+// that are also asserted.
+//
+// When quads are added, the reasoner forward-chains from the new quads.
+// When quads are removed, the reasoner uses the DRed (delete and rederive)
+// algorithm: it first deletes every inferred quad that may depend on
+// the removed quad, then rederives those that are still supported.
+//
+// The inferred graph is managed by the reasoner, and should not be
+// modified by other means while the reasoner is attached.
+//
+// Returned by calls to NewRDFSReasoner.
+type RDFSReasoner struct {
+	// SourceGraph is the graph holding asserted data,
+	// or "*" (an asterisk) to reason over all other graphs.
+	SourceGraph string
+	// InferredGraph is the graph holding inferred quads.
+	InferredGraph string
+	// QuadStore is the store being reasoned over.
+	QuadStore *QuadStore
+
+	unsubscribe func()
+}
+
+// triple is a subject-predicate-object triple, used internally
+// by the reasoners.
+type triple struct {
+	s string
+	p string
+	o interface{}
+}
+
+// NewRDFSReasoner attaches a new RDFSReasoner to the given store,
+// reasoning over the triples in sourceGraph and holding inferred
+// triples in inferredGraph. The closure of any existing data is
+// materialized immediately.
+//
+// Passing "*" (an asterisk) for sourceGraph reasons over all graphs
+// other than inferredGraph. NewRDFSReasoner will panic if inferredGraph
+// is "*", or if it is the same as sourceGraph.
+func NewRDFSReasoner(s *QuadStore, sourceGraph, inferredGraph string) *RDFSReasoner {
+	if inferredGraph == "*" {
+		panic("Unexpected use of wildcard '*' for inferred graph")
+	}
+	if inferredGraph == sourceGraph {
+		panic("Inferred graph must differ from source graph")
+	}
+	r := &RDFSReasoner{
+		SourceGraph:   sourceGraph,
+		InferredGraph: inferredGraph,
+		QuadStore:     s,
+	}
+	r.Materialize()
+	r.unsubscribe = s.Subscribe("*", "*", "*", sourceGraph, r.handleChange)
+	return r
+}
+
+// Detach stops the reasoner from tracking changes to the store.
+// Any inferred quads are left in place.
+func (r *RDFSReasoner) Detach() {
+	if r.unsubscribe != nil {
+		r.unsubscribe()
+		r.unsubscribe = nil
+	}
+}
+
+// Materialize computes the closure of all source data, adding
+// any missing inferred quads to the inferred graph.
+func (r *RDFSReasoner) Materialize() {
+	var queue []triple
+	r.forEach("*", "*", "*", func(t triple) {
+		queue = append(queue, t)
+	})
+	r.propagate(queue)
+}
+
+func (r *RDFSReasoner) handleChange(c Change) {
+	if c.Graph == r.InferredGraph {
+		// Ignore our own changes.
+		return
+	}
+	t := triple{c.Subject, c.Predicate, c.Object}
+	if c.Op == Added {
+		r.propagate([]triple{t})
+		return
+	}
+	if r.asserted(t) {
+		// Still asserted elsewhere, so nothing has changed.
+		return
+	}
+	r.retract(t)
+}
+
+// propagate forward-chains from the given triples, adding
+// all new consequences to the inferred graph.
+func (r *RDFSReasoner) propagate(queue []triple) {
+	for len(queue) > 0 {
+		x := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		for _, c := range r.consequences(x) {
+			if r.QuadStore.Add(c.s, c.p, c.o, r.InferredGraph) {
+				queue = append(queue, c)
+			}
+		}
+	}
+}
+
+// retract removes the consequences of a triple that is no longer asserted,
+// using DRed: overdelete everything that may depend on the triple, then
+// rederive whatever remains supported.
+func (r *RDFSReasoner) retract(t triple) {
+	s := r.QuadStore
+	deleted := make(map[triple]struct{})
+	var order []triple
+	if s.contains(t.s, t.p, t.o, r.InferredGraph) {
+		// An inferred copy may only have been supported by the triple itself.
+		deleted[t] = struct{}{}
+		order = append(order, t)
+	}
+	// Overdelete.
+	queue := []triple{t}
+	for len(queue) > 0 {
+		x := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		for _, c := range r.consequences(x) {
+			if _, ok := deleted[c]; ok {
+				continue
+			}
+			if s.contains(c.s, c.p, c.o, r.InferredGraph) {
+				deleted[c] = struct{}{}
+				order = append(order, c)
+				queue = append(queue, c)
+			}
+		}
+	}
+	for _, c := range order {
+		s.Remove(c.s, c.p, c.o, r.InferredGraph)
+	}
+	// Rederive.
+	for _, c := range order {
+		if !s.contains(c.s, c.p, c.o, r.InferredGraph) && r.derivable(c) {
+			s.Add(c.s, c.p, c.o, r.InferredGraph)
+			r.propagate([]triple{c})
+		}
+	}
+}
+
+// consequences returns the triples that can be inferred in a single
+// step, using the given triple as one of the premises.
+func (r *RDFSReasoner) consequences(x triple) []triple {
+	var out []triple
+	emit := func(s, p string, o interface{}) {
+		out = append(out, triple{s, p, o})
+	}
+	// The given triple as a schema triple.
+	if c, ok := x.o.(string); ok {
+		switch x.p {
+		case RDFSDomain:
+			// rdfs2: (p domain c) (s p o) -> (s type c)
+			r.forEach("*", x.s, "*", func(t triple) {
+				emit(t.s, RDFType, c)
+			})
+		case RDFSRange:
+			// rdfs3: (p range c) (s p o) -> (o type c)
+			r.forEach("*", x.s, "*", func(t triple) {
+				if o, ok := t.o.(string); ok {
+					emit(o, RDFType, c)
+				}
+			})
+		case RDFSSubPropertyOf:
+			// rdfs5: (p spo q) (q spo r) -> (p spo r)
+			r.forEach(c, RDFSSubPropertyOf, "*", func(t triple) {
+				emit(x.s, RDFSSubPropertyOf, t.o)
+			})
+			r.forEach("*", RDFSSubPropertyOf, x.s, func(t triple) {
+				emit(t.s, RDFSSubPropertyOf, c)
+			})
+			// rdfs7: (p spo q) (s p o) -> (s q o)
+			r.forEach("*", x.s, "*", func(t triple) {
+				emit(t.s, c, t.o)
+			})
+		case RDFSSubClassOf:
+			// rdfs11: (c sco d) (d sco e) -> (c sco e)
+			r.forEach(c, RDFSSubClassOf, "*", func(t triple) {
+				emit(x.s, RDFSSubClassOf, t.o)
+			})
+			r.forEach("*", RDFSSubClassOf, x.s, func(t triple) {
+				emit(t.s, RDFSSubClassOf, c)
+			})
+			// rdfs9: (c sco d) (s type c) -> (s type d)
+			r.forEach("*", RDFType, x.s, func(t triple) {
+				emit(t.s, RDFType, c)
+			})
+		}
+	}
+	// The given triple as an instance triple.
+	r.forEach(x.p, RDFSDomain, "*", func(t triple) {
+		if c, ok := t.o.(string); ok {
+			emit(x.s, RDFType, c)
+		}
+	})
+	if o, ok := x.o.(string); ok {
+		r.forEach(x.p, RDFSRange, "*", func(t triple) {
+			if c, ok := t.o.(string); ok {
+				emit(o, RDFType, c)
+			}
+		})
+	}
+	r.forEach(x.p, RDFSSubPropertyOf, "*", func(t triple) {
+		if q, ok := t.o.(string); ok {
+			emit(x.s, q, x.o)
+		}
+	})
+	if x.p == RDFType {
+		if c, ok := x.o.(string); ok {
+			r.forEach(c, RDFSSubClassOf, "*", func(t triple) {
+				emit(x.s, RDFType, t.o)
+			})
+		}
+	}
+	return out
+}
+
+// derivable returns true if the given triple can be inferred
+// in a single step from the triples currently in the store.
+func (r *RDFSReasoner) derivable(x triple) bool {
+	found := false
+	if c, ok := x.o.(string); ok {
+		switch x.p {
+		case RDFType:
+			// rdfs2.
+			r.forEach("*", RDFSDomain, c, func(t triple) {
+				found = found || r.some(x.s, t.s, "*")
+			})
+			// rdfs3.
+			r.forEach("*", RDFSRange, c, func(t triple) {
+				found = found || r.some("*", t.s, x.s)
+			})
+			// rdfs9.
+			r.forEach(x.s, RDFType, "*", func(t triple) {
+				if d, ok := t.o.(string); ok {
+					found = found || r.some(d, RDFSSubClassOf, c)
+				}
+			})
+		case RDFSSubClassOf:
+			// rdfs11.
+			r.forEach(x.s, RDFSSubClassOf, "*", func(t triple) {
+				if d, ok := t.o.(string); ok {
+					found = found || r.some(d, RDFSSubClassOf, c)
+				}
+			})
+		case RDFSSubPropertyOf:
+			// rdfs5.
+			r.forEach(x.s, RDFSSubPropertyOf, "*", func(t triple) {
+				if q, ok := t.o.(string); ok {
+					found = found || r.some(q, RDFSSubPropertyOf, c)
+				}
+			})
+		}
+	}
+	// rdfs7.
+	r.forEach("*", RDFSSubPropertyOf, x.p, func(t triple) {
+		found = found || r.some(x.s, t.s, x.o)
+	})
+	return found
+}
+
+// asserted returns true if the given triple is held in any source graph.
+func (r *RDFSReasoner) asserted(t triple) bool {
+	haltFn := func(s, p string, o interface{}, g string) bool {
+		return g != r.InferredGraph
+	}
+	return r.QuadStore.SomeWith(t.s, t.p, t.o, r.SourceGraph, haltFn)
+}
+
+// some returns true if any source or inferred triple matches the given pattern.
+func (r *RDFSReasoner) some(subject, predicate string, object interface{}) bool {
+	s := r.QuadStore
+	haltFn := func(s, p string, o interface{}, g string) bool {
+		return true
+	}
+	if s.SomeWith(subject, predicate, object, r.SourceGraph, haltFn) {
+		return true
+	}
+	return r.SourceGraph != "*" && s.SomeWith(subject, predicate, object, r.InferredGraph, haltFn)
+}
+
+// forEach calls the given function for each source or inferred triple
+// matching the given pattern. Triples held in more than one graph may
+// be visited more than once.
+func (r *RDFSReasoner) forEach(subject, predicate string, object interface{}, fn func(t triple)) {
+	s := r.QuadStore
+	callbackFn := func(s, p string, o interface{}, g string) {
+		fn(triple{s, p, o})
+	}
+	s.ForEachWith(subject, predicate, object, r.SourceGraph, callbackFn)
+	if r.SourceGraph != "*" {
+		s.ForEachWith(subject, predicate, object, r.InferredGraph, callbackFn)
+	}
+}
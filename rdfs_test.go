@@ -0,0 +1,234 @@
+package store4_test
+
+import (
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RDFSReasoner", func() {
+
+	const inferred = "urn:inferred"
+
+	inferredTriples := func(store *QuadStore) []*Triple {
+		var out []*Triple
+		store.GraphView(inferred).ForEach(func(s, p string, o interface{}) {
+			out = append(out, &Triple{s, p, o})
+		})
+		return out
+	}
+
+	Describe("Materializing existing data", func() {
+
+		store := NewQuadStore([][3]string{
+			{"Dog", RDFSSubClassOf, "Mammal"},
+			{"Mammal", RDFSSubClassOf, "Animal"},
+			{"rex", RDFType, "Dog"},
+			{"owns", RDFSDomain, "Person"},
+			{"owns", RDFSRange, "Thing"},
+			{"alice", "owns", "rex"},
+			{"hasMother", RDFSSubPropertyOf, "hasParent"},
+			{"hasParent", RDFSSubPropertyOf, "hasAncestor"},
+			{"alice", "hasMother", "carol"},
+		})
+		store.Add("alice", "owns", 42, "")
+		NewRDFSReasoner(store, "", inferred)
+
+		It("should infer all entailments", func() {
+			Expect(inferredTriples(store)).To(ConsistOf([]*Triple{
+				// rdfs11
+				{"Dog", RDFSSubClassOf, "Animal"},
+				// rdfs9
+				{"rex", RDFType, "Mammal"},
+				{"rex", RDFType, "Animal"},
+				// rdfs2
+				{"alice", RDFType, "Person"},
+				// rdfs3 (literals are not typed)
+				{"rex", RDFType, "Thing"},
+				// rdfs5
+				{"hasMother", RDFSSubPropertyOf, "hasAncestor"},
+				// rdfs7
+				{"alice", "hasParent", "carol"},
+				{"alice", "hasAncestor", "carol"},
+			}))
+		})
+
+		It("should leave the source graph untouched", func() {
+			Expect(store.GraphView("").Size()).To(Equal(uint64(10)))
+		})
+	})
+
+	Describe("Adding data", func() {
+
+		It("should infer entailments incrementally", func() {
+			store := NewQuadStore()
+			NewRDFSReasoner(store, "", inferred)
+			store.Add("rex", RDFType, "Dog", "")
+			Expect(inferredTriples(store)).To(BeEmpty())
+			store.Add("Dog", RDFSSubClassOf, "Mammal", "")
+			Expect(inferredTriples(store)).To(ConsistOf([]*Triple{
+				{"rex", RDFType, "Mammal"},
+			}))
+			store.Add("Mammal", RDFSSubClassOf, "Animal", "")
+			Expect(inferredTriples(store)).To(ConsistOf([]*Triple{
+				{"rex", RDFType, "Mammal"},
+				{"rex", RDFType, "Animal"},
+				{"Dog", RDFSSubClassOf, "Animal"},
+			}))
+		})
+
+		It("should chain through subproperties into domains", func() {
+			store := NewQuadStore()
+			NewRDFSReasoner(store, "", inferred)
+			store.Add("hasParent", RDFSDomain, "Person", "")
+			store.Add("hasMother", RDFSSubPropertyOf, "hasParent", "")
+			store.Add("alice", "hasMother", "carol", "")
+			Expect(inferredTriples(store)).To(ConsistOf([]*Triple{
+				{"alice", "hasParent", "carol"},
+				{"alice", RDFType, "Person"},
+			}))
+		})
+
+		It("should ignore other graphs", func() {
+			store := NewQuadStore()
+			NewRDFSReasoner(store, "", inferred)
+			store.Add("Dog", RDFSSubClassOf, "Mammal", "")
+			store.Add("rex", RDFType, "Dog", "other")
+			Expect(inferredTriples(store)).To(BeEmpty())
+		})
+	})
+
+	Describe("Removing data", func() {
+
+		newStore := func() *QuadStore {
+			store := NewQuadStore([][3]string{
+				{"Dog", RDFSSubClassOf, "Mammal"},
+				{"Mammal", RDFSSubClassOf, "Animal"},
+				{"rex", RDFType, "Dog"},
+			})
+			NewRDFSReasoner(store, "", inferred)
+			return store
+		}
+
+		It("should retract unsupported entailments", func() {
+			store := newStore()
+			store.Remove("Dog", RDFSSubClassOf, "Mammal", "")
+			Expect(inferredTriples(store)).To(BeEmpty())
+		})
+
+		It("should keep entailments that are still supported", func() {
+			store := newStore()
+			store.Add("Dog", RDFSSubClassOf, "Animal", "")
+			store.Remove("Dog", RDFSSubClassOf, "Mammal", "")
+			Expect(inferredTriples(store)).To(ConsistOf([]*Triple{
+				{"rex", RDFType, "Animal"},
+			}))
+		})
+
+		It("should rederive entailments with alternative derivations", func() {
+			store := newStore()
+			store.Add("Dog", RDFSSubClassOf, "Pet", "")
+			store.Add("Pet", RDFSSubClassOf, "Animal", "")
+			store.Remove("Mammal", RDFSSubClassOf, "Animal", "")
+			Expect(inferredTriples(store)).To(ConsistOf([]*Triple{
+				{"rex", RDFType, "Mammal"},
+				{"rex", RDFType, "Pet"},
+				{"rex", RDFType, "Animal"},
+				{"Dog", RDFSSubClassOf, "Animal"},
+			}))
+		})
+
+		It("should retract entailments that only supported each other", func() {
+			store := NewQuadStore([][3]string{
+				{"A", RDFSSubClassOf, "B"},
+				{"B", RDFSSubClassOf, "A"},
+				{"x", RDFType, "A"},
+			})
+			NewRDFSReasoner(store, "", inferred)
+			// Asserted triples that are also entailed appear in the inferred graph.
+			Expect(inferredTriples(store)).To(ConsistOf([]*Triple{
+				{"A", RDFSSubClassOf, "A"},
+				{"A", RDFSSubClassOf, "B"},
+				{"B", RDFSSubClassOf, "A"},
+				{"B", RDFSSubClassOf, "B"},
+				{"x", RDFType, "B"},
+				{"x", RDFType, "A"},
+			}))
+			store.Remove("x", RDFType, "A", "")
+			Expect(inferredTriples(store)).To(ConsistOf([]*Triple{
+				{"A", RDFSSubClassOf, "A"},
+				{"A", RDFSSubClassOf, "B"},
+				{"B", RDFSSubClassOf, "A"},
+				{"B", RDFSSubClassOf, "B"},
+			}))
+		})
+
+		It("should retract consequences of the removed instance data", func() {
+			store := newStore()
+			store.Remove("rex", "*", "*", "")
+			Expect(inferredTriples(store)).To(ConsistOf([]*Triple{
+				{"Dog", RDFSSubClassOf, "Animal"},
+			}))
+		})
+	})
+
+	Describe("Reasoning over all graphs", func() {
+
+		It("should use quads from every graph except the inferred graph", func() {
+			store := NewQuadStore([][4]string{
+				{"Dog", RDFSSubClassOf, "Mammal", "schema"},
+				{"rex", RDFType, "Dog", "data"},
+				{"rex", RDFType, "Dog", "more"},
+			})
+			NewRDFSReasoner(store, "*", inferred)
+			Expect(inferredTriples(store)).To(ConsistOf([]*Triple{
+				{"rex", RDFType, "Mammal"},
+			}))
+			store.Remove("rex", RDFType, "Dog", "data")
+			Expect(inferredTriples(store)).To(HaveLen(1))
+			store.Remove("rex", RDFType, "Dog", "more")
+			Expect(inferredTriples(store)).To(BeEmpty())
+		})
+	})
+
+	Describe("With a journal", func() {
+
+		It("should undo entailments together with the change that caused them", func() {
+			store := NewQuadStore([][3]string{
+				{"Dog", RDFSSubClassOf, "Mammal"},
+				{"Mammal", RDFSSubClassOf, "Animal"},
+			})
+			NewRDFSReasoner(store, "", inferred)
+			store.EnableJournal()
+			store.Add("rex", RDFType, "Dog", "")
+			Expect(store.FindObjects("rex", RDFType, inferred)).To(ConsistOf("Mammal", "Animal"))
+			Expect(store.Undo()).To(BeTrue())
+			Expect(store.Count("rex", "*", "*", "*")).To(BeZero())
+			Expect(store.Undo()).To(BeFalse())
+			Expect(store.Redo()).To(BeTrue())
+			Expect(store.FindObjects("rex", RDFType, inferred)).To(ConsistOf("Mammal", "Animal"))
+		})
+	})
+
+	Describe("Detach", func() {
+
+		It("should stop tracking changes", func() {
+			store := NewQuadStore([3]string{"Dog", RDFSSubClassOf, "Mammal"})
+			r := NewRDFSReasoner(store, "", inferred)
+			r.Detach()
+			r.Detach()
+			store.Add("rex", RDFType, "Dog", "")
+			Expect(inferredTriples(store)).To(BeEmpty())
+		})
+	})
+
+	Describe("Invalid graphs", func() {
+
+		It("should panic", func() {
+			store := NewQuadStore()
+			Expect(func() { NewRDFSReasoner(store, "", "*") }).To(Panic())
+			Expect(func() { NewRDFSReasoner(store, "g", "g") }).To(Panic())
+		})
+	})
+})
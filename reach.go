@@ -0,0 +1,261 @@
+package store4
+
+// ReachabilityIndex maintains the transitive closure of a single
+// predicate in a QuadStore, so that reachability queries can be
+// answered by lookup rather than by traversal.
+//
+// The index tracks changes to the store, updating incrementally:
+// when an edge is added, its source's ancestors gain its target's
+// descendants; when an edge is removed, the reachable sets of the
+// source and its ancestors are recomputed.
+//
+// Returned by calls to NewReachabilityIndex.
+type ReachabilityIndex struct {
+	// Predicate is the predicate whose closure is indexed.
+	Predicate string
+	// Graph is the graph holding the edges,
+	// or "*" (an asterisk) for edges in any graph.
+	Graph string
+	// QuadStore is the store being indexed.
+	QuadStore *QuadStore
+	// ClosurePredicate and ClosureGraph are where the closure
+	// is materialized as quads, after a call to Materialize.
+	ClosurePredicate string
+	ClosureGraph     string
+
+	materialized bool
+	// reach maps each node to the nodes reachable from it.
+	reach map[string]map[interface{}]struct{}
+	// reachedBy maps each node to the nodes it is reachable from.
+	reachedBy map[interface{}]map[string]struct{}
+
+	unsubscribe func()
+}
+
+// NewReachabilityIndex attaches a new ReachabilityIndex to the given store,
+// indexing the transitive closure of the given predicate in the given graph.
+//
+// Passing "*" (an asterisk) for graph indexes edges in all graphs.
+// NewReachabilityIndex will panic if predicate is "*".
+func NewReachabilityIndex(s *QuadStore, predicate, graph string) *ReachabilityIndex {
+	if predicate == "*" {
+		panic(ErrWildcardTerm)
+	}
+	r := &ReachabilityIndex{
+		Predicate: predicate,
+		Graph:     graph,
+		QuadStore: s,
+		reach:     make(map[string]map[interface{}]struct{}),
+		reachedBy: make(map[interface{}]map[string]struct{}),
+	}
+	s.ForSubjects(predicate, "*", graph, func(subject string) {
+		for t := range r.compute(subject) {
+			r.addPair(subject, t)
+		}
+	})
+	r.unsubscribe = s.Subscribe("*", predicate, "*", graph, r.handleChange)
+	s.reachability = append(s.reachability, r)
+	return r
+}
+
+// Detach stops the index from tracking changes to the store.
+// Any materialized quads are left in place.
+func (r *ReachabilityIndex) Detach() {
+	if r.unsubscribe == nil {
+		return
+	}
+	r.unsubscribe()
+	r.unsubscribe = nil
+	s := r.QuadStore
+	for i, x := range s.reachability {
+		if x == r {
+			s.reachability = append(s.reachability[:i], s.reachability[i+1:]...)
+			break
+		}
+	}
+}
+
+// Reachable returns true if b can be reached from a
+// by following one or more edges.
+func (r *ReachabilityIndex) Reachable(a string, b interface{}) bool {
+	_, ok := r.reach[a][b]
+	return ok
+}
+
+// Descendants returns a list of the nodes reachable from
+// the given node by following one or more edges.
+func (r *ReachabilityIndex) Descendants(node string) []interface{} {
+	out := make([]interface{}, 0, len(r.reach[node]))
+	for t := range r.reach[node] {
+		out = append(out, t)
+	}
+	return out
+}
+
+// Ancestors returns a list of the nodes from which the given
+// node can be reached by following one or more edges.
+func (r *ReachabilityIndex) Ancestors(node interface{}) []string {
+	out := make([]string, 0, len(r.reachedBy[node]))
+	for x := range r.reachedBy[node] {
+		out = append(out, x)
+	}
+	return out
+}
+
+// Materialize adds the closure to the store as quads, with the given
+// predicate and graph, and keeps them up to date while the index
+// is attached. Returns the count of quads added.
+//
+// Materialize will panic if predicate or graph are "*", or if
+// the quads would themselves be edges tracked by the index.
+func (r *ReachabilityIndex) Materialize(predicate, graph string) uint64 {
+	if predicate == "*" || graph == "*" {
+		panic(ErrWildcardTerm)
+	}
+	if predicate == r.Predicate && (r.Graph == "*" || r.Graph == graph) {
+		panic("Closure quads must differ from indexed edges")
+	}
+	r.ClosurePredicate = predicate
+	r.ClosureGraph = graph
+	r.materialized = true
+	var quads []quad
+	for x, targets := range r.reach {
+		for t := range targets {
+			quads = append(quads, quad{x, predicate, t, graph})
+		}
+	}
+	return r.QuadStore.addQuads(quads)
+}
+
+// handleChange updates the index for an added or removed edge.
+func (r *ReachabilityIndex) handleChange(c Change) {
+	if c.Op == Added {
+		r.addEdge(c.Subject, c.Object)
+	} else {
+		r.removeEdge(c.Subject, c.Object)
+	}
+}
+
+// addEdge updates the index for a new edge from u to v.
+func (r *ReachabilityIndex) addEdge(u string, v interface{}) {
+	if r.Reachable(u, v) {
+		// Everything reachable through v is already reachable from u.
+		return
+	}
+	targets := []interface{}{v}
+	if vs, ok := v.(string); ok {
+		for t := range r.reach[vs] {
+			targets = append(targets, t)
+		}
+	}
+	sources := []string{u}
+	for x := range r.reachedBy[u] {
+		sources = append(sources, x)
+	}
+	for _, x := range sources {
+		for _, t := range targets {
+			r.addPair(x, t)
+		}
+	}
+}
+
+// removeEdge updates the index for a removed edge from u to v.
+func (r *ReachabilityIndex) removeEdge(u string, v interface{}) {
+	if r.QuadStore.Count(u, r.Predicate, v, r.Graph) > 0 {
+		// The edge remains in another graph.
+		return
+	}
+	sources := []string{u}
+	for x := range r.reachedBy[u] {
+		sources = append(sources, x)
+	}
+	for _, x := range sources {
+		current := r.compute(x)
+		for t := range r.reach[x] {
+			if _, ok := current[t]; !ok {
+				r.removePair(x, t)
+			}
+		}
+	}
+}
+
+// compute returns the nodes reachable from the given node,
+// by traversing the edges in the store.
+func (r *ReachabilityIndex) compute(node string) map[interface{}]struct{} {
+	out := make(map[interface{}]struct{})
+	queue := []string{node}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		r.QuadStore.ForObjects(n, r.Predicate, r.Graph, func(o interface{}) {
+			if _, ok := out[o]; ok {
+				return
+			}
+			out[o] = struct{}{}
+			if os, ok := o.(string); ok {
+				queue = append(queue, os)
+			}
+		})
+	}
+	return out
+}
+
+// addPair records that t is reachable from x.
+func (r *ReachabilityIndex) addPair(x string, t interface{}) {
+	targets, ok := r.reach[x]
+	if !ok {
+		targets = make(map[interface{}]struct{})
+		r.reach[x] = targets
+	}
+	if _, ok := targets[t]; ok {
+		return
+	}
+	targets[t] = struct{}{}
+	sources, ok := r.reachedBy[t]
+	if !ok {
+		sources = make(map[string]struct{})
+		r.reachedBy[t] = sources
+	}
+	sources[x] = struct{}{}
+	if r.materialized {
+		r.QuadStore.Add(x, r.ClosurePredicate, t, r.ClosureGraph)
+	}
+}
+
+// removePair records that t is no longer reachable from x.
+func (r *ReachabilityIndex) removePair(x string, t interface{}) {
+	delete(r.reach[x], t)
+	if len(r.reach[x]) == 0 {
+		delete(r.reach, x)
+	}
+	delete(r.reachedBy[t], x)
+	if len(r.reachedBy[t]) == 0 {
+		delete(r.reachedBy, t)
+	}
+	if r.materialized {
+		r.QuadStore.Remove(x, r.ClosurePredicate, t, r.ClosureGraph)
+	}
+}
+
+// Reachable returns true if b can be reached from a by following
+// one or more edges with the given predicate, in the given graph.
+//
+// If a ReachabilityIndex is attached for the predicate and graph,
+// then it is used to answer the query. Otherwise, the graph is traversed.
+//
+// Passing "*" (an asterisk) for the graph follows edges in all graphs.
+func (s *QuadStore) Reachable(a string, b interface{}, predicate, graph string) bool {
+	for _, r := range s.reachability {
+		if r.Predicate == predicate && r.Graph == graph {
+			return r.Reachable(a, b)
+		}
+	}
+	path := &PropertyPath{root: pathRepeat{sub: pathLink{predicate}, min: 1, many: true}}
+	found := false
+	s.evalPath(a, path, graph, false, func(node interface{}) {
+		if node == b {
+			found = true
+		}
+	})
+	return found
+}
@@ -0,0 +1,115 @@
+package store4_test
+
+import (
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ReachabilityIndex", func() {
+
+	var store *QuadStore
+
+	BeforeEach(func() {
+		store = NewQuadStore([]*Quad{
+			{"wheel", "partOf", "car", ""},
+			{"spoke", "partOf", "wheel", ""},
+			{"car", "partOf", "fleet", ""},
+			{"engine", "partOf", "car", ""},
+			{"spoke", "madeOf", "steel", ""},
+		})
+	})
+
+	It("should answer reachability from existing data", func() {
+		r := NewReachabilityIndex(store, "partOf", "")
+		Expect(r.Reachable("spoke", "fleet")).To(BeTrue())
+		Expect(r.Reachable("spoke", "engine")).To(BeFalse())
+		Expect(r.Reachable("fleet", "spoke")).To(BeFalse())
+		Expect(r.Reachable("spoke", "steel")).To(BeFalse())
+		Expect(r.Descendants("spoke")).To(ConsistOf("wheel", "car", "fleet"))
+		Expect(r.Ancestors("car")).To(ConsistOf("wheel", "spoke", "engine"))
+	})
+
+	It("should update as edges are added", func() {
+		r := NewReachabilityIndex(store, "partOf", "")
+		store.Add("fleet", "partOf", "company", "")
+		Expect(r.Reachable("spoke", "company")).To(BeTrue())
+		store.Add("bolt", "partOf", "spoke", "")
+		Expect(r.Reachable("bolt", "company")).To(BeTrue())
+		// Edges in other graphs are not indexed.
+		store.Add("company", "partOf", "group", "other")
+		Expect(r.Reachable("bolt", "group")).To(BeFalse())
+	})
+
+	It("should update as edges are removed", func() {
+		r := NewReachabilityIndex(store, "partOf", "")
+		store.Remove("wheel", "partOf", "car", "")
+		Expect(r.Reachable("spoke", "car")).To(BeFalse())
+		Expect(r.Reachable("spoke", "wheel")).To(BeTrue())
+		Expect(r.Reachable("engine", "fleet")).To(BeTrue())
+		Expect(r.Ancestors("car")).To(ConsistOf("engine"))
+	})
+
+	It("should keep pairs with another path", func() {
+		r := NewReachabilityIndex(store, "partOf", "")
+		store.Add("spoke", "partOf", "car", "")
+		store.Remove("wheel", "partOf", "car", "")
+		Expect(r.Reachable("spoke", "fleet")).To(BeTrue())
+	})
+
+	It("should handle cycles", func() {
+		r := NewReachabilityIndex(store, "partOf", "")
+		store.Add("fleet", "partOf", "spoke", "")
+		Expect(r.Reachable("car", "car")).To(BeTrue())
+		Expect(r.Reachable("fleet", "engine")).To(BeFalse())
+		store.Remove("fleet", "partOf", "spoke", "")
+		Expect(r.Reachable("car", "car")).To(BeFalse())
+		Expect(r.Reachable("spoke", "fleet")).To(BeTrue())
+	})
+
+	It("should index edges across all graphs", func() {
+		store.Add("fleet", "partOf", "company", "other")
+		r := NewReachabilityIndex(store, "partOf", "*")
+		Expect(r.Reachable("spoke", "company")).To(BeTrue())
+		store.Add("wheel", "partOf", "car", "other")
+		store.Remove("wheel", "partOf", "car", "")
+		Expect(r.Reachable("spoke", "company")).To(BeTrue())
+	})
+
+	It("should materialize the closure as quads", func() {
+		r := NewReachabilityIndex(store, "partOf", "")
+		Expect(r.Materialize("partOfTransitive", "closure")).To(Equal(uint64(8)))
+		Expect(store.FindObjects("spoke", "partOfTransitive", "closure")).To(ConsistOf("wheel", "car", "fleet"))
+		store.Remove("car", "partOf", "fleet", "")
+		Expect(store.FindSubjects("partOfTransitive", "fleet", "closure")).To(BeEmpty())
+		store.Add("car", "partOf", "fleet", "")
+		Expect(store.FindSubjects("partOfTransitive", "fleet", "closure")).To(ConsistOf("car", "wheel", "spoke", "engine"))
+		Expect(func() { r.Materialize("partOf", "") }).To(Panic())
+	})
+
+	It("should stop tracking when detached", func() {
+		r := NewReachabilityIndex(store, "partOf", "")
+		r.Detach()
+		store.Add("fleet", "partOf", "company", "")
+		Expect(r.Reachable("spoke", "company")).To(BeFalse())
+	})
+
+	Describe("QuadStore.Reachable", func() {
+
+		It("should traverse without an index", func() {
+			Expect(store.Reachable("spoke", "fleet", "partOf", "")).To(BeTrue())
+			Expect(store.Reachable("fleet", "spoke", "partOf", "")).To(BeFalse())
+			Expect(store.Reachable("car", "car", "partOf", "")).To(BeFalse())
+		})
+
+		It("should use an attached index", func() {
+			r := NewReachabilityIndex(store, "partOf", "")
+			store.Add("fleet", "partOf", "company", "")
+			Expect(store.Reachable("spoke", "company", "partOf", "")).To(BeTrue())
+			r.Detach()
+			store.Remove("fleet", "partOf", "company", "")
+			Expect(store.Reachable("spoke", "company", "partOf", "")).To(BeFalse())
+		})
+	})
+})
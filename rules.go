@@ -0,0 +1,705 @@
+package store4
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Rule is a Datalog-style rule over quads. Whenever all of the patterns
+// in its Body match quads in the store, the patterns in its Head
+// (with variables substituted) are added to the store.
+//
+// Rules can be constructed directly, or parsed from text with ParseRules.
+type Rule struct {
+	// Name is an optional name for the rule.
+	Name string
+	// Body holds the patterns that must all match.
+	Body []Pattern
+	// Head holds the patterns that are added when the body matches.
+	Head []Pattern
+}
+
+// Pattern is a quad pattern, used in the body and head of a Rule.
+//
+// Any term that is a string beginning with "?" (a question mark)
+// is a variable. When used in the body of a rule, passing "*"
+// (an asterisk) for any term acts as a match-everything wildcard.
+type Pattern struct {
+	Subject   string
+	Predicate string
+	Object    interface{}
+	Graph     string
+}
+
+// String returns the rule in the text syntax accepted by ParseRules.
+func (r *Rule) String() string {
+	var buf strings.Builder
+	buf.WriteByte('[')
+	if len(r.Name) > 0 {
+		buf.WriteString(r.Name)
+		buf.WriteString(": ")
+	}
+	for _, p := range r.Body {
+		buf.WriteString(p.String())
+		buf.WriteByte(' ')
+	}
+	buf.WriteString("->")
+	for _, p := range r.Head {
+		buf.WriteByte(' ')
+		buf.WriteString(p.String())
+	}
+	buf.WriteByte(']')
+	return buf.String()
+}
+
+// String returns the pattern in the text syntax accepted by ParseRules.
+func (p Pattern) String() string {
+	terms := []string{formatRuleTerm(p.Subject), formatRuleTerm(p.Predicate), formatRuleTerm(p.Object)}
+	if len(p.Graph) > 0 {
+		terms = append(terms, formatRuleTerm(p.Graph))
+	}
+	return "(" + strings.Join(terms, " ") + ")"
+}
+
+func formatRuleTerm(t interface{}) string {
+	switch t := t.(type) {
+	case string:
+		if isVariable(t) || t == "*" {
+			return t
+		}
+		return "<" + t + ">"
+	case bool, int, int64, float64:
+		return fmt.Sprint(t)
+	}
+	return strconv.Quote(fmt.Sprint(t))
+}
+
+// isVariable returns true if the given term is a rule variable.
+func isVariable(t interface{}) bool {
+	s, ok := t.(string)
+	return ok && len(s) > 1 && s[0] == '?'
+}
+
+// quad is a subject-predicate-object-graph quad, used internally.
+type quad struct {
+	s string
+	p string
+	o interface{}
+	g string
+}
+
+// bindings maps variable names to their values.
+type bindings map[string]interface{}
+
+// RuleEngine evaluates rules over a QuadStore, adding derived quads
+// to the store until a fixpoint is reached.
+//
+// Evaluation is semi-naive: after an initial pass, each newly derived
+// quad is only joined against the store's contents, rather than
+// re-evaluating every rule from scratch.
+//
+// In incremental mode (see Attach), the engine keeps derived quads up to
+// date as the store changes. Added quads are forward-chained. Removed quads
+// are handled using the DRed (delete and rederive) algorithm: every
+// derived quad that may depend on the removed quad is deleted, then those
+// still supported are rederived. Only quads that were added by the engine
+// are ever deleted by it — a quad already present in the store when it
+// is first derived is treated as asserted. However, asserting a quad that
+// the engine has already derived does not change the store, so the engine
+// can not tell that it happened: such a quad remains treated as derived,
+// and is deleted if it loses its support.
+//
+// Returned by calls to NewRuleEngine.
+type RuleEngine struct {
+	// Rules holds the rules to evaluate.
+	Rules []*Rule
+	// QuadStore is the store being evaluated over.
+	QuadStore *QuadStore
+
+	// derived holds the quads added by the engine.
+	derived map[quad]struct{}
+	// running is true while the engine is modifying the store.
+	running     bool
+	unsubscribe func()
+}
+
+// NewRuleEngine returns a new RuleEngine for the given store and rules.
+//
+// NewRuleEngine will panic if any rule has a variable in its head
+// that does not appear in its body.
+func NewRuleEngine(s *QuadStore, rules ...*Rule) *RuleEngine {
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			panic(err)
+		}
+	}
+	return &RuleEngine{
+		Rules:     rules,
+		QuadStore: s,
+		derived:   make(map[quad]struct{}),
+	}
+}
+
+// validate checks that all head variables are bound by the body.
+func (r *Rule) validate() error {
+	vars := make(map[string]bool)
+	for _, p := range r.Body {
+		for _, t := range p.terms() {
+			if isVariable(t) {
+				vars[t.(string)] = true
+			}
+		}
+	}
+	for _, p := range r.Head {
+		for _, t := range p.terms() {
+			if isVariable(t) && !vars[t.(string)] {
+				return fmt.Errorf("rule %s: head variable %s does not appear in body", r, t)
+			}
+			if t == "*" {
+				return fmt.Errorf("rule %s: unexpected use of wildcard '*' in head", r)
+			}
+		}
+	}
+	return nil
+}
+
+func (p Pattern) terms() [4]interface{} {
+	return [4]interface{}{p.Subject, p.Predicate, p.Object, p.Graph}
+}
+
+// Run evaluates all rules to a fixpoint, adding derived quads to the
+// store. Returns the number of quads added.
+func (e *RuleEngine) Run() uint64 {
+	var queue []quad
+	for _, r := range e.Rules {
+		e.solve(r.Body, -1, bindings{}, func(b bindings) bool {
+			queue = append(queue, r.instantiate(b)...)
+			return false
+		})
+	}
+	var count uint64
+	var delta []quad
+	for _, q := range queue {
+		if e.add(q) {
+			count++
+			delta = append(delta, q)
+		}
+	}
+	return count + e.propagate(delta)
+}
+
+// Attach switches the engine to incremental mode, in which derived quads
+// are kept up to date as quads are added to and removed from the store.
+// Attach first calls Run, to bring the store up to date.
+func (e *RuleEngine) Attach() {
+	if e.unsubscribe != nil {
+		return
+	}
+	e.Run()
+	e.unsubscribe = e.QuadStore.Subscribe("*", "*", "*", "*", e.handleChange)
+}
+
+// Detach stops the engine from tracking changes to the store.
+// Any derived quads are left in place.
+func (e *RuleEngine) Detach() {
+	if e.unsubscribe != nil {
+		e.unsubscribe()
+		e.unsubscribe = nil
+	}
+}
+
+func (e *RuleEngine) handleChange(c Change) {
+	if e.running {
+		// Ignore our own changes.
+		return
+	}
+	q := quad{c.Subject, c.Predicate, c.Object, c.Graph}
+	if c.Op == Added {
+		e.propagate([]quad{q})
+		return
+	}
+	// A derived quad removed by other means is no longer ours.
+	delete(e.derived, q)
+	e.retract(q)
+}
+
+// add adds a derived quad to the store, returning true if it was new.
+func (e *RuleEngine) add(q quad) bool {
+	e.running = true
+	defer func() { e.running = false }()
+	if !e.QuadStore.Add(q.s, q.p, q.o, q.g) {
+		return false
+	}
+	e.derived[q] = struct{}{}
+	return true
+}
+
+// remove removes a derived quad from the store.
+func (e *RuleEngine) remove(q quad) {
+	e.running = true
+	defer func() { e.running = false }()
+	e.QuadStore.Remove(q.s, q.p, q.o, q.g)
+}
+
+// propagate forward-chains from the given quads, adding all new
+// consequences to the store. Returns the number of quads added.
+func (e *RuleEngine) propagate(queue []quad) uint64 {
+	var count uint64
+	for len(queue) > 0 {
+		x := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		for _, q := range e.consequences(x) {
+			if e.add(q) {
+				count++
+				queue = append(queue, q)
+			}
+		}
+	}
+	return count
+}
+
+// consequences returns the quads derived by a single rule application
+// in which the given quad matches one of the body patterns.
+func (e *RuleEngine) consequences(x quad) []quad {
+	var out []quad
+	for _, r := range e.Rules {
+		for i, p := range r.Body {
+			b, ok := p.unify(x, bindings{})
+			if !ok {
+				continue
+			}
+			e.solve(r.Body, i, b, func(b bindings) bool {
+				out = append(out, r.instantiate(b)...)
+				return false
+			})
+		}
+	}
+	return out
+}
+
+// retract removes derived quads that are no longer supported
+// after the given quad has been removed, using DRed.
+func (e *RuleEngine) retract(x quad) {
+	// Overdelete.
+	deleted := make(map[quad]struct{})
+	var order []quad
+	queue := []quad{x}
+	for len(queue) > 0 {
+		y := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		for _, q := range e.consequences(y) {
+			if _, ok := deleted[q]; ok {
+				continue
+			}
+			if _, ok := e.derived[q]; ok {
+				deleted[q] = struct{}{}
+				order = append(order, q)
+				queue = append(queue, q)
+			}
+		}
+	}
+	for _, q := range order {
+		delete(e.derived, q)
+		e.remove(q)
+	}
+	// Rederive.
+	for _, q := range order {
+		if e.derivable(q) && e.add(q) {
+			e.propagate([]quad{q})
+		}
+	}
+}
+
+// derivable returns true if any rule derives the given quad
+// from the current contents of the store.
+func (e *RuleEngine) derivable(x quad) bool {
+	for _, r := range e.Rules {
+		for _, p := range r.Head {
+			b, ok := p.unify(x, bindings{})
+			if !ok {
+				continue
+			}
+			if e.solve(r.Body, -1, b, func(b bindings) bool { return true }) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// solve finds all extensions of the given bindings that satisfy every
+// body pattern (other than the one at index skip), calling fn for each.
+// Iteration stops when fn returns true, in which case solve returns true.
+func (e *RuleEngine) solve(body []Pattern, skip int, b bindings, fn func(b bindings) bool) bool {
+	i := 0
+	for i < len(body) && i == skip {
+		i++
+	}
+	if i >= len(body) {
+		return fn(b)
+	}
+	p := body[i]
+	rest := func(b bindings) bool {
+		return e.solve(body[i+1:], skip-i-1, b, fn)
+	}
+	s, sok := p.substitute(p.Subject, b)
+	pr, pok := p.substitute(p.Predicate, b)
+	o := p.substituteObject(b)
+	g, gok := p.substitute(p.Graph, b)
+	if !sok || !pok || !gok {
+		return false
+	}
+	// Collect matches first, so the store is not iterated while recursing.
+	var matches []quad
+	e.QuadStore.ForEachWith(s, pr, o, g, func(s, p string, o interface{}, g string) {
+		matches = append(matches, quad{s, p, o, g})
+	})
+	for _, q := range matches {
+		if b2, ok := p.unify(q, b); ok && rest(b2) {
+			return true
+		}
+	}
+	return false
+}
+
+// substitute returns the value of the given term under the given bindings,
+// as a term usable for matching. Unbound variables become wildcards.
+// Returns false if the term must be a string, but is bound to a non-string.
+func (p Pattern) substitute(t interface{}, b bindings) (string, bool) {
+	ts, ok := t.(string)
+	if !ok {
+		return "", false
+	}
+	if !isVariable(ts) {
+		return ts, true
+	}
+	v, ok := b[ts]
+	if !ok {
+		return "*", true
+	}
+	vs, ok := v.(string)
+	return vs, ok
+}
+
+// substituteObject returns the value of the pattern's object under the
+// given bindings, which may be a non-string term. An unbound variable
+// becomes a wildcard.
+func (p Pattern) substituteObject(b bindings) interface{} {
+	if !isVariable(p.Object) {
+		return p.Object
+	}
+	if v, ok := b[p.Object.(string)]; ok {
+		return v
+	}
+	return "*"
+}
+
+// unify matches the pattern against the given quad, extending the
+// given bindings. Returns false if the pattern does not match.
+func (p Pattern) unify(q quad, b bindings) (bindings, bool) {
+	out := b
+	copied := false
+	bind := func(t, v interface{}) bool {
+		if t == "*" {
+			return true
+		}
+		if !isVariable(t) {
+			return t == v
+		}
+		name := t.(string)
+		if x, ok := out[name]; ok {
+			return x == v
+		}
+		if !copied {
+			out = make(bindings, len(b)+4)
+			for k, x := range b {
+				out[k] = x
+			}
+			copied = true
+		}
+		out[name] = v
+		return true
+	}
+	if bind(p.Subject, q.s) && bind(p.Predicate, q.p) && bind(p.Object, q.o) && bind(p.Graph, q.g) {
+		return out, true
+	}
+	return nil, false
+}
+
+// instantiate returns the head quads of the rule under the given bindings.
+// Quads that would have a non-string subject, predicate or graph are skipped.
+func (r *Rule) instantiate(b bindings) []quad {
+	out := make([]quad, 0, len(r.Head))
+	for _, p := range r.Head {
+		s, sok := p.substitute(p.Subject, b)
+		pr, pok := p.substitute(p.Predicate, b)
+		g, gok := p.substitute(p.Graph, b)
+		if !sok || !pok || !gok {
+			continue
+		}
+		o := p.Object
+		if isVariable(o) {
+			o = b[o.(string)]
+		}
+		out = append(out, quad{s, pr, o, g})
+	}
+	return out
+}
+
+// ParseRules parses rules from the given text.
+//
+// Each rule is enclosed in square brackets, with an optional name,
+// followed by the body patterns, an arrow, and the head patterns:
+//  [indirect: (?x manages ?y) (?y manages ?z) -> (?x indirectlyManages ?z)]
+//
+// Each pattern holds three terms (subject, predicate and object),
+// and an optional fourth term (graph). Patterns without a graph term
+// match the default (unnamed) graph. The terms may be:
+//  ?x                   a variable
+//  *                    a wildcard (body only)
+//  <http://example/p>   an IRI
+//  ex:p                 a prefixed name, or bare name
+//  "text" or 'text'     a quoted string, with N-Triples escapes
+//  42, 4.2, true        an int, float64 or bool object
+//
+// Prefixes are declared with:
+//  @prefix ex: <http://example.org/> .
+//
+// Comments start with a '#' and run to the end of the line.
+func ParseRules(text string) ([]*Rule, error) {
+	p := &ruleParser{
+		ruleLexer: newRuleLexer(text),
+		prefixes:  make(map[string]string),
+	}
+	return p.parse()
+}
+
+type ruleParser struct {
+	*ruleLexer
+	prefixes map[string]string
+}
+
+func (p *ruleParser) parse() ([]*Rule, error) {
+	var rules []*Rule
+	for {
+		tok := p.next()
+		switch {
+		case tok.kind == tokEOF:
+			return rules, nil
+		case tok.kind == tokIdent && tok.text == "@prefix":
+			if err := p.parsePrefix(); err != nil {
+				return nil, err
+			}
+		case tok.kind == tokPunct && tok.text == "[":
+			r, err := p.parseRule()
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, r)
+		default:
+			return nil, p.errorf(tok, "unexpected %v", tok)
+		}
+	}
+}
+
+func (p *ruleParser) parsePrefix() error {
+	name := p.next()
+	if name.kind != tokIdent || !strings.HasSuffix(name.text, ":") {
+		return p.errorf(name, "expected prefix name, got %v", name)
+	}
+	iri := p.next()
+	if iri.kind != tokIRI {
+		return p.errorf(iri, "expected IRI, got %v", iri)
+	}
+	dot := p.next()
+	if dot.kind != tokPunct || dot.text != "." {
+		return p.errorf(dot, "expected '.', got %v", dot)
+	}
+	p.prefixes[strings.TrimSuffix(name.text, ":")] = iri.text
+	return nil
+}
+
+func (p *ruleParser) parseRule() (*Rule, error) {
+	r := &Rule{}
+	tok := p.next()
+	if tok.kind == tokIdent && strings.HasSuffix(tok.text, ":") {
+		r.Name = strings.TrimSuffix(tok.text, ":")
+		tok = p.next()
+	}
+	inHead := false
+	for {
+		switch {
+		case tok.kind == tokPunct && tok.text == "(":
+			pat, err := p.parsePattern()
+			if err != nil {
+				return nil, err
+			}
+			if inHead {
+				r.Head = append(r.Head, pat)
+			} else {
+				r.Body = append(r.Body, pat)
+			}
+		case tok.kind == tokPunct && tok.text == "->" && !inHead:
+			inHead = true
+		case tok.kind == tokPunct && tok.text == "]":
+			if len(r.Body) == 0 || len(r.Head) == 0 {
+				return nil, p.errorf(tok, "rule must have a body and a head")
+			}
+			if err := r.validate(); err != nil {
+				return nil, p.errorf(tok, "%v", err)
+			}
+			return r, nil
+		default:
+			return nil, p.errorf(tok, "unexpected %v", tok)
+		}
+		tok = p.next()
+	}
+}
+
+func (p *ruleParser) parsePattern() (Pattern, error) {
+	var terms []interface{}
+	for {
+		tok := p.next()
+		if tok.kind == tokPunct && tok.text == ")" {
+			break
+		}
+		t, err := p.term(tok)
+		if err != nil {
+			return Pattern{}, err
+		}
+		terms = append(terms, t)
+		if len(terms) > 4 {
+			return Pattern{}, p.errorf(tok, "too many terms in pattern")
+		}
+	}
+	if len(terms) < 3 {
+		return Pattern{}, p.errorf(p.last, "too few terms in pattern")
+	}
+	pat := Pattern{Object: terms[2]}
+	var ok [3]bool
+	pat.Subject, ok[0] = terms[0].(string)
+	pat.Predicate, ok[1] = terms[1].(string)
+	ok[2] = true
+	if len(terms) == 4 {
+		pat.Graph, ok[2] = terms[3].(string)
+	}
+	if !ok[0] || !ok[1] || !ok[2] {
+		return Pattern{}, p.errorf(p.last, "only objects may be literals")
+	}
+	return pat, nil
+}
+
+func (p *ruleParser) term(tok ruleToken) (interface{}, error) {
+	switch tok.kind {
+	case tokIRI, tokString:
+		return tok.text, nil
+	case tokIdent:
+		t := tok.text
+		if t == "*" || isVariable(t) {
+			return t, nil
+		}
+		if t == "true" || t == "false" {
+			return t == "true", nil
+		}
+		if n, ok := parseNumber(t); ok {
+			return n, nil
+		}
+		if iri, ok := expandPrefixedName(t, p.prefixes); ok {
+			return iri, nil
+		}
+		return t, nil
+	}
+	return nil, p.errorf(tok, "unexpected %v", tok)
+}
+
+type ruleTokenKind int
+
+const (
+	tokEOF ruleTokenKind = iota
+	tokIdent
+	tokIRI
+	tokString
+	tokPunct
+)
+
+type ruleToken struct {
+	kind ruleTokenKind
+	text string
+	line int
+}
+
+// String returns a description of the token, for use in error messages.
+func (t ruleToken) String() string {
+	if t.kind == tokEOF {
+		return "end of input"
+	}
+	return strconv.Quote(t.text)
+}
+
+// ruleLexer splits rule text into tokens.
+type ruleLexer struct {
+	src  []rune
+	pos  int
+	line int
+	last ruleToken
+}
+
+func newRuleLexer(text string) *ruleLexer {
+	return &ruleLexer{src: []rune(text), line: 1}
+}
+
+func (l *ruleLexer) errorf(tok ruleToken, format string, args ...interface{}) error {
+	return fmt.Errorf("rule syntax error at line %d: %s", tok.line, fmt.Sprintf(format, args...))
+}
+
+func (l *ruleLexer) next() ruleToken {
+	l.last = l.scan()
+	return l.last
+}
+
+func (l *ruleLexer) scan() ruleToken {
+	pos, lines := skipSpace(l.src, l.pos)
+	l.pos, l.line = pos, l.line+lines
+	if l.pos >= len(l.src) {
+		return ruleToken{kind: tokEOF, line: l.line}
+	}
+	line := l.line
+	c := l.src[l.pos]
+	switch {
+	case c == '(' || c == ')' || c == '[' || c == ']':
+		l.pos++
+		return ruleToken{tokPunct, string(c), line}
+	case c == '-' && l.pos+1 < len(l.src) && l.src[l.pos+1] == '>':
+		l.pos += 2
+		return ruleToken{tokPunct, "->", line}
+	case c == '.' && (l.pos+1 >= len(l.src) || unicode.IsSpace(l.src[l.pos+1])):
+		l.pos++
+		return ruleToken{tokPunct, ".", line}
+	case c == '<' || c == '"' || c == '\'':
+		var text string
+		var err error
+		kind := tokString
+		if c == '<' {
+			text, pos, err = scanIRIRef(l.src, l.pos)
+			kind = tokIRI
+		} else {
+			text, pos, err = scanString(l.src, l.pos)
+		}
+		l.pos = pos
+		if err != nil {
+			return ruleToken{tokPunct, string(c), line}
+		}
+		return ruleToken{kind, text, line}
+	}
+	start := l.pos
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if unicode.IsSpace(c) || strings.ContainsRune("()[]<\"'#", c) {
+			break
+		}
+		l.pos++
+	}
+	return ruleToken{tokIdent, string(l.src[start:l.pos]), line}
+}
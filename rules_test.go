@@ -0,0 +1,267 @@
+package store4_test
+
+import (
+	"errors"
+
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rules", func() {
+
+	Describe("ParseRules", func() {
+
+		It("should parse rules with names, prefixes and comments", func() {
+			rules, err := ParseRules(`
+				# Management chains.
+				@prefix ex: <http://example.org/> .
+				[indirect: (?x ex:manages ?y) (?y ex:manages ?z)
+				    -> (?x ex:indirectlyManages ?z)]
+				[(?x age ?a g1) -> (?x hasAge ?a) (?x "label" 42)]
+			`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rules).To(Equal([]*Rule{
+				{
+					Name: "indirect",
+					Body: []Pattern{
+						{"?x", "http://example.org/manages", "?y", ""},
+						{"?y", "http://example.org/manages", "?z", ""},
+					},
+					Head: []Pattern{
+						{"?x", "http://example.org/indirectlyManages", "?z", ""},
+					},
+				},
+				{
+					Body: []Pattern{
+						{"?x", "age", "?a", "g1"},
+					},
+					Head: []Pattern{
+						{"?x", "hasAge", "?a", ""},
+						{"?x", "label", 42, ""},
+					},
+				},
+			}))
+		})
+
+		It("should parse literal objects", func() {
+			rules, err := ParseRules(`[(?x p *) -> (?x a 1) (?x b 2.5) (?x c true) (?x d "say \"hi\"")]`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rules[0].Body[0].Object).To(Equal("*"))
+			objects := []interface{}{}
+			for _, p := range rules[0].Head {
+				objects = append(objects, p.Object)
+			}
+			Expect(objects).To(Equal([]interface{}{1, 2.5, true, `say "hi"`}))
+		})
+
+		It("should decode escapes and numbers as in other syntaxes", func() {
+			rules, err := ParseRules(`[(?x <ex:\u0070> "a\tb\u00E9") (?x q 'c\'d') -> (?x r 1e3) (?x s -2) (?x t Infinity)]`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rules[0].Body).To(Equal([]Pattern{
+				{"?x", "ex:p", "a\tbé", ""},
+				{"?x", "q", "c'd", ""},
+			}))
+			objects := []interface{}{}
+			for _, p := range rules[0].Head {
+				objects = append(objects, p.Object)
+			}
+			Expect(objects).To(Equal([]interface{}{1e3, -2, "Infinity"}))
+		})
+
+		It("should round trip through String", func() {
+			text := `[r1: (?x <knows> ?y) (?y <knows> ?z <g>) -> (?x <friendOfFriend> ?z)]`
+			rules, err := ParseRules(text)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rules[0].String()).To(Equal(text))
+		})
+
+		It("should report syntax errors with line numbers", func() {
+			_, err := ParseRules("[(?x p ?y) -> (?x q ?y)]\n[(?x p) -> (?x q ?y)]")
+			Expect(err).To(MatchError("rule syntax error at line 2: too few terms in pattern"))
+			_, err = ParseRules("[(?x p ?y) -> (?x q ?z)]")
+			Expect(err).To(MatchError(ContainSubstring("head variable ?z does not appear in body")))
+			_, err = ParseRules("[(?x p ?y) -> (?x q *)]")
+			Expect(err).To(MatchError(ContainSubstring("wildcard")))
+			_, err = ParseRules("[(?x p ?y) ->")
+			Expect(err).To(MatchError("rule syntax error at line 1: unexpected end of input"))
+			_, err = ParseRules("[(?x p ?y) -> (?x q ?y)] )")
+			Expect(err).To(MatchError(`rule syntax error at line 1: unexpected ")"`))
+			_, err = ParseRules("[(?x p ?y) -> ]")
+			Expect(err).To(MatchError(ContainSubstring("must have a body and a head")))
+			_, err = ParseRules("[(?x p ?y q r) -> (?x q ?y)]")
+			Expect(err).To(MatchError(ContainSubstring("too many terms")))
+			_, err = ParseRules("[(?x 1 ?y) -> (?x q ?y)]")
+			Expect(err).To(MatchError(ContainSubstring("only objects may be literals")))
+			_, err = ParseRules("@prefix ex <http://example.org/> .")
+			Expect(err).To(MatchError(ContainSubstring("expected prefix name")))
+			_, err = ParseRules("@prefix ex: http://example.org/ .")
+			Expect(err).To(MatchError(ContainSubstring("expected IRI")))
+			_, err = ParseRules("@prefix ex: <http://example.org/>")
+			Expect(err).To(MatchError(ContainSubstring("expected '.'")))
+		})
+	})
+
+	Describe("RuleEngine", func() {
+
+		indirect, _ := ParseRules(`[(?x manages ?y) (?y manages ?z) -> (?x indirectlyManages ?z)]
+		                           [(?x indirectlyManages ?y) (?y manages ?z) -> (?x indirectlyManages ?z)]`)
+
+		It("should panic for invalid rules", func() {
+			r := &Rule{
+				Body: []Pattern{{"?x", "p", "?y", ""}},
+				Head: []Pattern{{"?x", "p", "?z", ""}},
+			}
+			Expect(func() { NewRuleEngine(NewQuadStore(), r) }).To(Panic())
+		})
+
+		It("should evaluate rules to a fixpoint", func() {
+			store := NewQuadStore([][3]string{
+				{"a", "manages", "b"},
+				{"b", "manages", "c"},
+				{"c", "manages", "d"},
+			})
+			e := NewRuleEngine(store, indirect...)
+			Expect(e.Run()).To(Equal(uint64(3)))
+			Expect(store.FindObjects("a", "indirectlyManages", "")).To(ConsistOf("c", "d"))
+			Expect(store.FindObjects("b", "indirectlyManages", "")).To(ConsistOf("d"))
+			Expect(e.Run()).To(BeZero())
+		})
+
+		It("should support variables in any position, and literal objects", func() {
+			rules, _ := ParseRules(`[(?p inverseOf ?q) (?x ?p ?y) -> (?y ?q ?x)]
+			                        [(?x age ?a ?g) -> (?x hasAge ?a ?g)]`)
+			store := NewQuadStore([][3]string{
+				{"parentOf", "inverseOf", "childOf"},
+				{"alice", "parentOf", "bob"},
+			})
+			store.Add("alice", "age", 42, "g1")
+			store.Add("bob", "parentOf", 7, "")
+			NewRuleEngine(store, rules...).Run()
+			Expect(store.Count("bob", "childOf", "alice", "")).To(Equal(uint64(1)))
+			Expect(store.Count("alice", "hasAge", 42, "g1")).To(Equal(uint64(1)))
+			// Literals can not become subjects.
+			Expect(store.Count("*", "childOf", "bob", "")).To(BeZero())
+		})
+
+		It("should match int and float literals in rule bodies", func() {
+			rules, _ := ParseRules(`[(?x age 42) -> (?x is answer)]
+			                        [(?x height 1.5) -> (?x is short)]`)
+			store := NewQuadStore()
+			store.Add("alice", "age", 42, "")
+			store.Add("bob", "age", 7, "")
+			store.Add("alice", "height", 1.5, "")
+			store.Add("bob", "height", 1.9, "")
+			Expect(NewRuleEngine(store, rules...).Run()).To(Equal(uint64(2)))
+			Expect(store.FindObjects("alice", "is", "")).To(ConsistOf("answer", "short"))
+			Expect(store.FindObjects("bob", "is", "")).To(BeEmpty())
+		})
+
+		It("should join on variables bound to non-string values", func() {
+			rules, _ := ParseRules(`[(?x age ?a) (?y age ?a) -> (?x sameAge ?y)]
+			                        [(?x height ?h) (?y height ?h) -> (?x sameHeight ?y)]`)
+			store := NewQuadStore()
+			store.Add("alice", "age", 42, "")
+			store.Add("bob", "age", 42, "")
+			store.Add("carol", "age", 7, "")
+			store.Add("alice", "height", 1.5, "")
+			store.Add("carol", "height", 1.5, "")
+			NewRuleEngine(store, rules...).Run()
+			Expect(store.FindObjects("alice", "sameAge", "")).To(ConsistOf("alice", "bob"))
+			Expect(store.FindObjects("carol", "sameAge", "")).To(ConsistOf("carol"))
+			Expect(store.FindObjects("alice", "sameHeight", "")).To(ConsistOf("alice", "carol"))
+		})
+
+		It("should respect repeated variables", func() {
+			rules, _ := ParseRules(`[(?x knows ?x) -> (?x is narcissist)]`)
+			store := NewQuadStore([][3]string{
+				{"a", "knows", "a"},
+				{"b", "knows", "c"},
+			})
+			NewRuleEngine(store, rules...).Run()
+			Expect(store.FindSubjects("is", "narcissist", "")).To(ConsistOf("a"))
+		})
+
+		Describe("in incremental mode", func() {
+
+			newStore := func() (*QuadStore, *RuleEngine) {
+				store := NewQuadStore([][3]string{
+					{"a", "manages", "b"},
+					{"b", "manages", "c"},
+				})
+				e := NewRuleEngine(store, indirect...)
+				e.Attach()
+				e.Attach()
+				return store, e
+			}
+
+			It("should derive quads as data is added", func() {
+				store, _ := newStore()
+				Expect(store.FindObjects("a", "indirectlyManages", "")).To(ConsistOf("c"))
+				store.Add("c", "manages", "d", "")
+				Expect(store.FindObjects("a", "indirectlyManages", "")).To(ConsistOf("c", "d"))
+				Expect(store.FindObjects("b", "indirectlyManages", "")).To(ConsistOf("d"))
+			})
+
+			It("should retract derived quads as data is removed", func() {
+				store, _ := newStore()
+				store.Add("c", "manages", "d", "")
+				store.Remove("b", "manages", "c", "")
+				Expect(store.FindObjects("*", "indirectlyManages", "")).To(BeEmpty())
+			})
+
+			It("should rederive quads with alternative support", func() {
+				store, _ := newStore()
+				store.Add("a", "manages", "x", "")
+				store.Add("x", "manages", "c", "")
+				store.Remove("b", "manages", "c", "")
+				Expect(store.FindObjects("a", "indirectlyManages", "")).To(ConsistOf("c"))
+			})
+
+			It("should never remove asserted quads", func() {
+				store := NewQuadStore([][3]string{
+					{"a", "manages", "b"},
+					{"b", "manages", "c"},
+					{"a", "indirectlyManages", "c"},
+				})
+				NewRuleEngine(store, indirect...).Attach()
+				store.Add("c", "manages", "d", "")
+				store.Remove("b", "manages", "c", "")
+				// (a indirectlyManages d) is still supported by the asserted quad.
+				Expect(store.FindObjects("a", "indirectlyManages", "")).To(ConsistOf("c", "d"))
+				Expect(store.FindObjects("b", "indirectlyManages", "")).To(BeEmpty())
+			})
+
+			It("should treat a derived quad that is asserted later as derived", func() {
+				store, _ := newStore()
+				Expect(store.Add("a", "indirectlyManages", "c", "")).To(BeFalse())
+				store.Remove("b", "manages", "c", "")
+				Expect(store.FindObjects("a", "indirectlyManages", "")).To(BeEmpty())
+			})
+
+			It("should keep working after a retraction is vetoed", func() {
+				store, _ := newStore()
+				store.BeforeRemove = func(s, p string, o interface{}, g string) error {
+					if p == "indirectlyManages" {
+						return errors.New("vetoed")
+					}
+					return nil
+				}
+				Expect(func() { store.Remove("b", "manages", "c", "") }).To(Panic())
+				store.BeforeRemove = nil
+				store.Add("x", "manages", "y", "")
+				store.Add("y", "manages", "z", "")
+				Expect(store.FindObjects("x", "indirectlyManages", "")).To(ConsistOf("z"))
+			})
+
+			It("should stop tracking changes when detached", func() {
+				store, e := newStore()
+				e.Detach()
+				e.Detach()
+				store.Add("c", "manages", "d", "")
+				Expect(store.FindObjects("a", "indirectlyManages", "")).To(ConsistOf("c"))
+			})
+		})
+	})
+})
@@ -0,0 +1,157 @@
+package store4
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The functions here scan the terms shared by the package's text syntaxes,
+// so that IRIs, strings, numbers and prefixed names are read the same way
+// by each of them.
+
+// errInvalidEscape is returned when a \u or \U escape is malformed.
+var errInvalidEscape = errors.New("invalid escape")
+
+// scanIRIRef scans an IRI enclosed in angle brackets, beginning at
+// src[pos], decoding any escapes. Returns the IRI and the position
+// following the closing '>'. If the IRI is unterminated before the end
+// of the line, or holds an invalid escape, then an error is returned,
+// along with the position at which scanning stopped.
+func scanIRIRef(src []rune, pos int) (string, int, error) {
+	return scanQuoted(src, pos, '>')
+}
+
+// scanString scans a string quoted by the character at src[pos],
+// either a double or single quote, decoding any escapes. Returns the string
+// and the position following the closing quote. If the string is
+// unterminated before the end of the line, or holds an invalid escape,
+// then an error is returned, along with the position at which scanning stopped.
+func scanString(src []rune, pos int) (string, int, error) {
+	return scanQuoted(src, pos, src[pos])
+}
+
+// scanQuoted scans text following src[pos] up to the given closing rune,
+// decoding the escapes of N-Triples: \t, \b, \n, \r, \f, \uXXXX and
+// \UXXXXXXXX, and a backslash before any other character.
+func scanQuoted(src []rune, pos int, end rune) (string, int, error) {
+	var b strings.Builder
+	i := pos + 1
+	for ; i < len(src) && src[i] != '\n'; i++ {
+		c := src[i]
+		if c == end {
+			return b.String(), i + 1, nil
+		}
+		if c != '\\' {
+			b.WriteRune(c)
+			continue
+		}
+		i++
+		if i >= len(src) {
+			break
+		}
+		switch e := src[i]; e {
+		case 'u', 'U':
+			n := 4
+			if e == 'U' {
+				n = 8
+			}
+			if i+n >= len(src) {
+				return "", i, errInvalidEscape
+			}
+			r, err := strconv.ParseUint(string(src[i+1:i+1+n]), 16, 32)
+			if err != nil {
+				return "", i, errInvalidEscape
+			}
+			b.WriteRune(rune(r))
+			i += n
+		case 't':
+			b.WriteByte('\t')
+		case 'b':
+			b.WriteByte('\b')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		default:
+			b.WriteRune(e)
+		}
+	}
+	return "", i, fmt.Errorf("unterminated %q", string(end))
+}
+
+// numberPattern matches the INTEGER, DECIMAL and DOUBLE forms of Turtle.
+var numberPattern = regexp.MustCompile(`^[+-]?([0-9]+|[0-9]*\.[0-9]+|([0-9]+\.[0-9]*|\.[0-9]+|[0-9]+)[eE][+-]?[0-9]+)$`)
+
+// parseNumber parses a numeric literal, returning an int for an integer,
+// or a float64 for a decimal or double. Returns false if the given text
+// is not a number.
+func parseNumber(text string) (interface{}, bool) {
+	if !numberPattern.MatchString(text) {
+		return nil, false
+	}
+	if !strings.ContainsAny(text, ".eE") {
+		i, err := strconv.Atoi(text)
+		return i, err == nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	return f, err == nil
+}
+
+// isDecimal returns true if the given numeric literal is a decimal,
+// rather than an integer or double.
+func isDecimal(text string) bool {
+	return strings.Contains(text, ".") && !strings.ContainsAny(text, "eE")
+}
+
+// expandPrefixedName expands a prefixed name, such as "ex:name", using
+// the first of the given prefix maps that declares its prefix.
+// Returns false if the name has no prefix, or its prefix is undeclared.
+func expandPrefixedName(name string, prefixes ...map[string]string) (string, bool) {
+	i := strings.IndexByte(name, ':')
+	if i < 0 {
+		return "", false
+	}
+	for _, m := range prefixes {
+		if ns, ok := m[name[:i]]; ok {
+			return ns + name[i+1:], true
+		}
+	}
+	return "", false
+}
+
+// prefixOf returns the prefix of a prefixed name.
+func prefixOf(name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// skipSpace skips whitespace and '#' comments in src from pos, returning
+// the new position and the number of lines skipped.
+func skipSpace(src []rune, pos int) (int, int) {
+	lines := 0
+	for pos < len(src) {
+		c := src[pos]
+		if c == '\n' {
+			lines++
+		}
+		if c == '#' {
+			for pos < len(src) && src[pos] != '\n' {
+				pos++
+			}
+			continue
+		}
+		if !unicode.IsSpace(c) {
+			break
+		}
+		pos++
+	}
+	return pos, lines
+}
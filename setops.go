@@ -0,0 +1,365 @@
+package store4
+
+// setOp is a set operation over triples or quads.
+type setOp int
+
+const (
+	setUnion setOp = iota
+	setIntersection
+	setDifference
+	setSymmetricDifference
+)
+
+// TripleSetView is a lazy, read-only view of the result of a set operation
+// over the triples of two GraphViews. The result is evaluated afresh by each
+// method call, so it always reflects the current contents of both operands.
+//
+// Triples are compared exactly, so blank nodes are matched by label.
+//
+// Returned by calls to GraphView's Union, Intersection,
+// Difference and SymmetricDifference.
+type TripleSetView struct {
+	op   setOp
+	a, b *GraphView
+}
+
+// Union returns a view of the triples that are in either this graph
+// or the other graph.
+func (g *GraphView) Union(other *GraphView) *TripleSetView {
+	return &TripleSetView{setUnion, g, other}
+}
+
+// Intersection returns a view of the triples that are in both this graph
+// and the other graph.
+func (g *GraphView) Intersection(other *GraphView) *TripleSetView {
+	return &TripleSetView{setIntersection, g, other}
+}
+
+// Difference returns a view of the triples that are in this graph
+// but not in the other graph.
+func (g *GraphView) Difference(other *GraphView) *TripleSetView {
+	return &TripleSetView{setDifference, g, other}
+}
+
+// SymmetricDifference returns a view of the triples that are in
+// exactly one of this graph and the other graph.
+func (g *GraphView) SymmetricDifference(other *GraphView) *TripleSetView {
+	return &TripleSetView{setSymmetricDifference, g, other}
+}
+
+// Some tests whether some triple in the view passes the test
+// implemented by the given function, halting iteration
+// as soon as the callback returns true.
+func (v *TripleSetView) Some(fn TripleTestFn) bool {
+	a := graphOperand(v.a.QuadStore, v.a.Graph)
+	b := graphOperand(v.b.QuadStore, v.b.Graph)
+	return setSome(v.op, a, b, func(s *QuadStore, sid, pid, oid uint64) bool {
+		return fn(s.pool.idToString(sid), s.pool.idToString(pid), s.pool.idToAny(oid))
+	})
+}
+
+// ForEach executes the given callback once for each triple in the view.
+func (v *TripleSetView) ForEach(fn TripleCallbackFn) {
+	v.Some(func(s, p string, o interface{}) bool {
+		fn(s, p, o)
+		return false
+	})
+}
+
+// Contains returns true if the view holds the given triple.
+func (v *TripleSetView) Contains(subject, predicate string, object interface{}) bool {
+	return setContains(v.op, v.a.QuadStore.containsAny(subject, predicate, object, v.a.Graph),
+		v.b.QuadStore.containsAny(subject, predicate, object, v.b.Graph))
+}
+
+// Size returns the count of triples in the view.
+func (v *TripleSetView) Size() uint64 {
+	var n uint64
+	v.ForEach(func(s, p string, o interface{}) {
+		n++
+	})
+	return n
+}
+
+// Empty returns true if the view has no contents.
+func (v *TripleSetView) Empty() bool {
+	return !v.Some(func(s, p string, o interface{}) bool {
+		return true
+	})
+}
+
+// CopyTo materializes the view, adding its triples to the given graph.
+// Returns the count of triples that were new to the graph.
+//
+// The destination may be one of the view's operands.
+// As with Add, CopyTo will panic if any triple is rejected
+// by the destination store's BeforeAdd callback.
+func (v *TripleSetView) CopyTo(dst *GraphView) uint64 {
+	var quads []quad
+	v.ForEach(func(s, p string, o interface{}) {
+		quads = append(quads, quad{s, p, o, dst.Graph})
+	})
+	return dst.QuadStore.addQuads(quads)
+}
+
+// QuadSetView is a lazy, read-only view of the result of a set operation
+// over the quads of two stores, applied graph by graph. The result is
+// evaluated afresh by each method call, so it always reflects
+// the current contents of both operands.
+//
+// Quads are compared exactly, so blank nodes are matched by label.
+//
+// Returned by calls to QuadStore's Union, Intersection,
+// Difference and SymmetricDifference.
+type QuadSetView struct {
+	op   setOp
+	a, b *QuadStore
+}
+
+// Union returns a view of the quads that are in either this store
+// or the other store.
+func (s *QuadStore) Union(other *QuadStore) *QuadSetView {
+	return &QuadSetView{setUnion, s, other}
+}
+
+// Intersection returns a view of the quads that are in both this store
+// and the other store.
+func (s *QuadStore) Intersection(other *QuadStore) *QuadSetView {
+	return &QuadSetView{setIntersection, s, other}
+}
+
+// Difference returns a view of the quads that are in this store
+// but not in the other store.
+func (s *QuadStore) Difference(other *QuadStore) *QuadSetView {
+	return &QuadSetView{setDifference, s, other}
+}
+
+// SymmetricDifference returns a view of the quads that are in
+// exactly one of this store and the other store.
+func (s *QuadStore) SymmetricDifference(other *QuadStore) *QuadSetView {
+	return &QuadSetView{setSymmetricDifference, s, other}
+}
+
+// Some tests whether some quad in the view passes the test
+// implemented by the given function, halting iteration
+// as soon as the callback returns true.
+func (v *QuadSetView) Some(fn QuadTestFn) bool {
+	graphs := make(map[string]struct{}, len(v.a.graphs))
+	for g := range v.a.graphs {
+		graphs[g] = struct{}{}
+	}
+	if v.op == setUnion || v.op == setSymmetricDifference {
+		for g := range v.b.graphs {
+			graphs[g] = struct{}{}
+		}
+	}
+	for g := range graphs {
+		a := graphOperand(v.a, g)
+		b := graphOperand(v.b, g)
+		found := setSome(v.op, a, b, func(s *QuadStore, sid, pid, oid uint64) bool {
+			return fn(s.pool.idToString(sid), s.pool.idToString(pid), s.pool.idToAny(oid), g)
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// ForEach executes the given callback once for each quad in the view.
+func (v *QuadSetView) ForEach(fn QuadCallbackFn) {
+	v.Some(func(s, p string, o interface{}, g string) bool {
+		fn(s, p, o, g)
+		return false
+	})
+}
+
+// Contains returns true if the view holds the given quad.
+//
+// Passing "*" (an asterisk) for the graph returns true
+// if the view holds the triple in any graph.
+func (v *QuadSetView) Contains(subject, predicate string, object interface{}, graph string) bool {
+	if graph != "*" {
+		return setContains(v.op, v.a.containsAny(subject, predicate, object, graph),
+			v.b.containsAny(subject, predicate, object, graph))
+	}
+	for _, s := range []*QuadStore{v.a, v.b} {
+		for g := range s.graphs {
+			if v.Contains(subject, predicate, object, g) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Size returns the count of quads in the view.
+func (v *QuadSetView) Size() uint64 {
+	var n uint64
+	v.ForEach(func(s, p string, o interface{}, g string) {
+		n++
+	})
+	return n
+}
+
+// Empty returns true if the view has no contents.
+func (v *QuadSetView) Empty() bool {
+	return !v.Some(func(s, p string, o interface{}, g string) bool {
+		return true
+	})
+}
+
+// CopyTo materializes the view, adding its quads to the given store.
+// Returns the count of quads that were new to the store.
+//
+// The destination may be one of the view's operands.
+// As with Add, CopyTo will panic if any quad is rejected
+// by the destination store's BeforeAdd callback.
+func (v *QuadSetView) CopyTo(dst *QuadStore) uint64 {
+	var quads []quad
+	v.ForEach(func(s, p string, o interface{}, g string) {
+		quads = append(quads, quad{s, p, o, g})
+	})
+	return dst.addQuads(quads)
+}
+
+// addQuads adds the given quads to the store as a batch,
+// returning the count of quads that were new.
+func (s *QuadStore) addQuads(quads []quad) uint64 {
+	defer s.beginBatch(len(quads))()
+	var added uint64
+	for _, q := range quads {
+		if s.Add(q.s, q.p, q.o, q.g) {
+			added++
+		}
+	}
+	return added
+}
+
+// containsAny returns true if the store holds the given quad,
+// where the graph may be "*" (an asterisk) to match any graph.
+func (s *QuadStore) containsAny(subject, predicate string, object interface{}, graph string) bool {
+	if subject == "*" || predicate == "*" || object == "*" {
+		return false
+	}
+	return graphOperand(s, graph).hasTerms(subject, predicate, object)
+}
+
+// setContains returns whether an item is in the result of the given
+// set operation, given whether it is in each of the operands.
+func setContains(op setOp, inA, inB bool) bool {
+	switch op {
+	case setUnion:
+		return inA || inB
+	case setIntersection:
+		return inA && inB
+	case setDifference:
+		return inA && !inB
+	}
+	return inA != inB
+}
+
+// setOperand is one side of a set operation:
+// the distinct triples held in some graphs of a store.
+type setOperand struct {
+	store  *QuadStore
+	graphs []*indexedGraph
+}
+
+// graphOperand returns an operand for the given graph of the store,
+// or for all of its graphs if graph is "*" (an asterisk).
+func graphOperand(s *QuadStore, graph string) setOperand {
+	o := setOperand{store: s}
+	s.graphs.forEachMatch(graph, func(key string, g *indexedGraph) {
+		o.graphs = append(o.graphs, g)
+	})
+	return o
+}
+
+// size returns an upper bound on the count of triples in the operand.
+func (o setOperand) size() uint64 {
+	var n uint64
+	for _, g := range o.graphs {
+		n += g.size
+	}
+	return n
+}
+
+// has returns true if the operand holds the triple with the given IDs.
+func (o setOperand) has(sid, pid, oid uint64) bool {
+	for _, g := range o.graphs {
+		if _, ok := g.spoIndex[sid][pid][oid]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTerms returns true if the operand holds the given triple.
+func (o setOperand) hasTerms(subject, predicate string, object interface{}) bool {
+	sid, sok := o.store.pool.stringToID(subject)
+	pid, pok := o.store.pool.stringToID(predicate)
+	oid, ook := o.store.pool.anyToID(object)
+	return sok && pok && ook && o.has(sid, pid, oid)
+}
+
+// hasFrom returns true if the operand holds the triple with the given IDs,
+// which belong to the given store's pool. IDs are used directly
+// when both share a store, and are otherwise looked up by term.
+func (o setOperand) hasFrom(from *QuadStore, sid, pid, oid uint64) bool {
+	if from == o.store {
+		return o.has(sid, pid, oid)
+	}
+	return o.hasTerms(from.pool.idToString(sid), from.pool.idToString(pid), from.pool.idToAny(oid))
+}
+
+// some calls the given function once for each distinct triple in the operand,
+// halting iteration as soon as the function returns true.
+func (o setOperand) some(fn func(sid, pid, oid uint64) bool) bool {
+	for i, g := range o.graphs {
+		// Triples held by earlier graphs have already been seen.
+		earlier := setOperand{o.store, o.graphs[:i]}
+		for sid, pm := range g.spoIndex {
+			for pid, om := range pm {
+				for oid := range om {
+					if i > 0 && earlier.has(sid, pid, oid) {
+						continue
+					}
+					if fn(sid, pid, oid) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// setSome calls the given function once for each triple in the result of
+// the given set operation, halting iteration as soon as the function
+// returns true. Each triple is passed as IDs, with the store they belong to.
+func setSome(op setOp, a, b setOperand, fn func(s *QuadStore, sid, pid, oid uint64) bool) bool {
+	// without calls fn for the triples of x that are not in y.
+	without := func(x, y setOperand) bool {
+		return x.some(func(sid, pid, oid uint64) bool {
+			return !y.hasFrom(x.store, sid, pid, oid) && fn(x.store, sid, pid, oid)
+		})
+	}
+	switch op {
+	case setUnion:
+		return a.some(func(sid, pid, oid uint64) bool {
+			return fn(a.store, sid, pid, oid)
+		}) || without(b, a)
+	case setIntersection:
+		// Iterate over the smaller operand.
+		if b.size() < a.size() {
+			a, b = b, a
+		}
+		return a.some(func(sid, pid, oid uint64) bool {
+			return b.hasFrom(a.store, sid, pid, oid) && fn(a.store, sid, pid, oid)
+		})
+	case setDifference:
+		return without(a, b)
+	}
+	return without(a, b) || without(b, a)
+}
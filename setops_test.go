@@ -0,0 +1,203 @@
+package store4_test
+
+import (
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Set operations", func() {
+
+	triples := func(v *TripleSetView) []*Triple {
+		var out []*Triple
+		v.ForEach(func(s, p string, o interface{}) {
+			out = append(out, &Triple{s, p, o})
+		})
+		return out
+	}
+
+	quads := func(v *QuadSetView) []*Quad {
+		var out []*Quad
+		v.ForEach(func(s, p string, o interface{}, g string) {
+			out = append(out, &Quad{s, p, o, g})
+		})
+		return out
+	}
+
+	Describe("GraphView", func() {
+
+		var staging, production *GraphView
+
+		sharedStore := func() {
+			store := NewQuadStore([]*Quad{
+				{"s1", "p1", "o1", "staging"},
+				{"s2", "p2", 2, "staging"},
+				{"s2", "p2", 2, "production"},
+				{"s3", "p3", "o3", "production"},
+			})
+			staging = store.GraphView("staging")
+			production = store.GraphView("production")
+		}
+
+		separateStores := func() {
+			staging = NewGraph([][3]string{
+				{"s1", "p1", "o1"},
+			})
+			staging.Add("s2", "p2", 2)
+			production = NewQuadStore().GraphView("production")
+			production.Add("s2", "p2", 2)
+			production.Add("s3", "p3", "o3")
+		}
+
+		for _, setup := range []struct {
+			name string
+			fn   func()
+		}{
+			{"in the same store", sharedStore},
+			{"in different stores", separateStores},
+		} {
+			setup := setup
+
+			Context(setup.name, func() {
+
+				BeforeEach(setup.fn)
+
+				It("should compute the union", func() {
+					v := staging.Union(production)
+					Expect(triples(v)).To(ConsistOf([]*Triple{
+						{"s1", "p1", "o1"},
+						{"s2", "p2", 2},
+						{"s3", "p3", "o3"},
+					}))
+					Expect(v.Size()).To(Equal(uint64(3)))
+					Expect(v.Contains("s3", "p3", "o3")).To(BeTrue())
+				})
+
+				It("should compute the intersection", func() {
+					v := staging.Intersection(production)
+					Expect(triples(v)).To(ConsistOf([]*Triple{
+						{"s2", "p2", 2},
+					}))
+					Expect(v.Contains("s2", "p2", 2)).To(BeTrue())
+					Expect(v.Contains("s1", "p1", "o1")).To(BeFalse())
+				})
+
+				It("should compute the difference", func() {
+					v := staging.Difference(production)
+					Expect(triples(v)).To(ConsistOf([]*Triple{
+						{"s1", "p1", "o1"},
+					}))
+					Expect(v.Contains("s2", "p2", 2)).To(BeFalse())
+				})
+
+				It("should compute the symmetric difference", func() {
+					v := staging.SymmetricDifference(production)
+					Expect(triples(v)).To(ConsistOf([]*Triple{
+						{"s1", "p1", "o1"},
+						{"s3", "p3", "o3"},
+					}))
+					Expect(v.Empty()).To(BeFalse())
+				})
+
+				It("should reflect later changes", func() {
+					v := staging.Difference(production)
+					production.Add("s1", "p1", "o1")
+					Expect(v.Empty()).To(BeTrue())
+					Expect(v.Size()).To(BeZero())
+				})
+
+				It("should materialize into a graph", func() {
+					dst := NewGraph()
+					Expect(staging.SymmetricDifference(production).CopyTo(dst)).To(Equal(uint64(2)))
+					Expect(dst.Size()).To(Equal(uint64(2)))
+				})
+
+				It("should materialize into an operand", func() {
+					Expect(staging.Union(production).CopyTo(staging)).To(Equal(uint64(1)))
+					Expect(staging.Size()).To(Equal(uint64(3)))
+				})
+			})
+		}
+
+		It("should treat a wildcard graph as the union of all graphs", func() {
+			store := NewQuadStore([]*Quad{
+				{"s1", "p1", "o1", "g1"},
+				{"s1", "p1", "o1", "g2"},
+				{"s2", "p2", "o2", "g2"},
+			})
+			other := NewGraph([][3]string{
+				{"s2", "p2", "o2"},
+			})
+			v := store.GraphView("*").Difference(other)
+			Expect(triples(v)).To(Equal([]*Triple{
+				{"s1", "p1", "o1"},
+			}))
+			Expect(store.GraphView("*").Union(other).Size()).To(Equal(uint64(2)))
+		})
+	})
+
+	Describe("QuadStore", func() {
+
+		var a, b *QuadStore
+
+		BeforeEach(func() {
+			a = NewQuadStore([]*Quad{
+				{"s1", "p1", "o1", ""},
+				{"s2", "p2", "o2", "g1"},
+				{"s3", "p3", "o3", "g1"},
+			})
+			b = NewQuadStore([]*Quad{
+				{"s1", "p1", "o1", "g1"},
+				{"s2", "p2", "o2", "g1"},
+				{"s4", "p4", "o4", "g2"},
+			})
+		})
+
+		It("should compute the union", func() {
+			Expect(quads(a.Union(b))).To(ConsistOf([]*Quad{
+				{"s1", "p1", "o1", ""},
+				{"s1", "p1", "o1", "g1"},
+				{"s2", "p2", "o2", "g1"},
+				{"s3", "p3", "o3", "g1"},
+				{"s4", "p4", "o4", "g2"},
+			}))
+		})
+
+		It("should compute the intersection", func() {
+			Expect(quads(a.Intersection(b))).To(ConsistOf([]*Quad{
+				{"s2", "p2", "o2", "g1"},
+			}))
+		})
+
+		It("should compute the difference", func() {
+			Expect(quads(a.Difference(b))).To(ConsistOf([]*Quad{
+				{"s1", "p1", "o1", ""},
+				{"s3", "p3", "o3", "g1"},
+			}))
+			Expect(a.Difference(b).Contains("s1", "p1", "o1", "")).To(BeTrue())
+			Expect(a.Difference(b).Contains("s1", "p1", "o1", "*")).To(BeTrue())
+			Expect(a.Difference(b).Contains("s2", "p2", "o2", "g1")).To(BeFalse())
+		})
+
+		It("should compute the symmetric difference", func() {
+			v := a.SymmetricDifference(b)
+			Expect(quads(v)).To(ConsistOf([]*Quad{
+				{"s1", "p1", "o1", ""},
+				{"s1", "p1", "o1", "g1"},
+				{"s3", "p3", "o3", "g1"},
+				{"s4", "p4", "o4", "g2"},
+			}))
+			Expect(v.Size()).To(Equal(uint64(4)))
+		})
+
+		It("should materialize into a store", func() {
+			dst := NewQuadStore()
+			Expect(a.Intersection(b).CopyTo(dst)).To(Equal(uint64(1)))
+			Expect(iterResults(dst)).To(ConsistOf([]*Quad{
+				{"s2", "p2", "o2", "g1"},
+			}))
+			Expect(a.Intersection(NewQuadStore()).Empty()).To(BeTrue())
+		})
+	})
+})
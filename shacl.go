@@ -0,0 +1,614 @@
+package store4
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IRIs from the SHACL vocabulary, used internally.
+const (
+	shNodeShape       = SHACLNamespace + "NodeShape"
+	shPropertyShape   = SHACLNamespace + "PropertyShape"
+	shTargetNode      = SHACLNamespace + "targetNode"
+	shTargetClass     = SHACLNamespace + "targetClass"
+	shTargetSubjects  = SHACLNamespace + "targetSubjectsOf"
+	shTargetObjects   = SHACLNamespace + "targetObjectsOf"
+	shProperty        = SHACLNamespace + "property"
+	shPath            = SHACLNamespace + "path"
+	shNode            = SHACLNamespace + "node"
+	shMinCount        = SHACLNamespace + "minCount"
+	shMaxCount        = SHACLNamespace + "maxCount"
+	shDatatype        = SHACLNamespace + "datatype"
+	shClass           = SHACLNamespace + "class"
+	shPattern         = SHACLNamespace + "pattern"
+	shFlags           = SHACLNamespace + "flags"
+	shIn              = SHACLNamespace + "in"
+	shMinInclusive    = SHACLNamespace + "minInclusive"
+	shMaxInclusive    = SHACLNamespace + "maxInclusive"
+	shMinExclusive    = SHACLNamespace + "minExclusive"
+	shMaxExclusive    = SHACLNamespace + "maxExclusive"
+	shSeverity        = SHACLNamespace + "severity"
+	shMessage         = SHACLNamespace + "message"
+	shDeactivated     = SHACLNamespace + "deactivated"
+	shViolation       = SHACLNamespace + "Violation"
+	shReport          = SHACLNamespace + "ValidationReport"
+	shResultType      = SHACLNamespace + "ValidationResult"
+	shConforms        = SHACLNamespace + "conforms"
+	shResult          = SHACLNamespace + "result"
+	shFocusNode       = SHACLNamespace + "focusNode"
+	shResultPath      = SHACLNamespace + "resultPath"
+	shValue           = SHACLNamespace + "value"
+	shSourceShape     = SHACLNamespace + "sourceShape"
+	shSourceComponent = SHACLNamespace + "sourceConstraintComponent"
+	shResultSeverity  = SHACLNamespace + "resultSeverity"
+	shResultMessage   = SHACLNamespace + "resultMessage"
+	rdfsClass         = "http://www.w3.org/2000/01/rdf-schema#Class"
+)
+
+// ValidationReport holds the results of validating data against
+// SHACL shapes, corresponding to a sh:ValidationReport.
+//
+// Returned by calls to SHACLValidator's Validate and ValidateStore.
+type ValidationReport struct {
+	// Conforms is true if validation produced no results.
+	Conforms bool
+	// Results holds the validation results, ordered by
+	// source shape, then focus node.
+	Results []ValidationResult
+}
+
+// ValidationResult is a single constraint violation,
+// corresponding to a sh:ValidationResult.
+type ValidationResult struct {
+	// FocusNode is the node that was validated.
+	FocusNode interface{}
+	// ResultPath is the path of the property shape that produced the result,
+	// or "" if the result was produced by a node shape.
+	ResultPath string
+	// Value is the value that violated the constraint,
+	// or nil if the constraint applies to all values (such as sh:minCount).
+	Value interface{}
+	// SourceShape is the shape holding the violated constraint.
+	SourceShape string
+	// SourceConstraintComponent is the IRI of the violated constraint's
+	// component, such as sh:MinCountConstraintComponent.
+	SourceConstraintComponent string
+	// Severity is the shape's sh:severity, sh:Violation by default.
+	Severity string
+	// Message is the shape's sh:message, if any.
+	Message string
+}
+
+// Graph returns the report as a new graph, using the SHACL vocabulary.
+// The report itself is the blank node "_:report", and its results are
+// the blank nodes "_:result1", "_:result2", and so on.
+func (r *ValidationReport) Graph() *GraphView {
+	g := NewGraph()
+	const report = "_:report"
+	g.Add(report, RDFType, shReport)
+	g.Add(report, shConforms, r.Conforms)
+	for i, x := range r.Results {
+		node := fmt.Sprintf("_:result%d", i+1)
+		g.Add(report, shResult, node)
+		g.Add(node, RDFType, shResultType)
+		g.Add(node, shFocusNode, x.FocusNode)
+		if x.ResultPath != "" {
+			g.Add(node, shResultPath, x.ResultPath)
+		}
+		if x.Value != nil {
+			g.Add(node, shValue, x.Value)
+		}
+		g.Add(node, shSourceShape, x.SourceShape)
+		g.Add(node, shSourceComponent, x.SourceConstraintComponent)
+		g.Add(node, shResultSeverity, x.Severity)
+		if x.Message != "" {
+			g.Add(node, shResultMessage, x.Message)
+		}
+	}
+	return g
+}
+
+// SHACLValidator validates data against a set of SHACL shapes.
+//
+// The validator supports a subset of SHACL Core: node shapes and
+// property shapes (with predicate paths), the sh:targetNode, sh:targetClass,
+// sh:targetSubjectsOf and sh:targetObjectsOf targets (and implicit class
+// targets), and the sh:minCount, sh:maxCount, sh:datatype, sh:class,
+// sh:pattern, sh:in, sh:minInclusive, sh:maxInclusive, sh:minExclusive,
+// sh:maxExclusive, sh:node and sh:property constraints.
+//
+// Values are mapped to XSD datatypes by their Go type (or, for Literals,
+// by their Datatype):
+// strings are xsd:string, bools are xsd:boolean, integers are xsd:integer,
+// float32 is xsd:float, float64 is xsd:double, and time.Time is xsd:dateTime.
+// Strings beginning with "_:" are treated as blank nodes.
+//
+// Returned by calls to NewSHACLValidator.
+type SHACLValidator struct {
+	// shapes holds all parsed shapes, ordered by name.
+	shapes []*shaclShape
+}
+
+// shaclShape is a parsed node or property shape.
+type shaclShape struct {
+	name     string
+	path     string
+	severity string
+	message  string
+	// Targets.
+	targetNodes    []interface{}
+	targetClasses  []string
+	targetSubjects []string
+	targetObjects  []string
+	// Constraints.
+	minCount   int
+	maxCount   int
+	datatype   string
+	classes    []string
+	patterns   []*regexp.Regexp
+	in         []interface{}
+	hasIn      bool
+	ranges     []shaclRange
+	nodes      []*shaclShape
+	properties []*shaclShape
+}
+
+// shaclRange is a value range constraint.
+type shaclRange struct {
+	// component is the local name of the constraint component.
+	component string
+	limit     interface{}
+	// ok reports whether the result of comparing a value with limit is allowed.
+	ok func(cmp int) bool
+}
+
+// shaclParser reads shapes from a shapes graph.
+type shaclParser struct {
+	g      *GraphView
+	shapes map[string]*shaclShape
+}
+
+// NewSHACLValidator returns a new SHACLValidator for the shapes held in
+// the given shapes graph. Shapes are read once, so later changes to the
+// shapes graph have no effect on the validator.
+//
+// Returns an error if any shape is malformed, for example if it
+// has an invalid sh:pattern, or a sh:path that is not a predicate.
+func NewSHACLValidator(shapes *GraphView) (*SHACLValidator, error) {
+	p := &shaclParser{
+		g:      shapes,
+		shapes: make(map[string]*shaclShape),
+	}
+	seen := make(map[string]bool)
+	var names []string
+	collect := func(s string) {
+		if !seen[s] {
+			seen[s] = true
+			names = append(names, s)
+		}
+	}
+	for _, t := range []string{shNodeShape, shPropertyShape} {
+		shapes.ForSubjects(RDFType, t, collect)
+	}
+	for _, t := range []string{shTargetNode, shTargetClass, shTargetSubjects, shTargetObjects} {
+		shapes.ForSubjects(t, "*", collect)
+	}
+	sort.Strings(names)
+	v := &SHACLValidator{}
+	for _, name := range names {
+		s, err := p.shape(name)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			v.shapes = append(v.shapes, s)
+		}
+	}
+	return v, nil
+}
+
+// shape parses the named shape, and any shapes it references.
+// Returns nil if the shape is deactivated.
+func (p *shaclParser) shape(name string) (*shaclShape, error) {
+	if s, ok := p.shapes[name]; ok {
+		return s, nil
+	}
+	g := p.g
+	if g.Count(name, shDeactivated, true) > 0 {
+		return nil, nil
+	}
+	s := &shaclShape{
+		name:     name,
+		severity: shViolation,
+		minCount: -1,
+		maxCount: -1,
+	}
+	// Register before parsing references, so that cycles terminate.
+	p.shapes[name] = s
+	errorf := func(format string, args ...interface{}) error {
+		return fmt.Errorf("invalid SHACL shape %s: %s", name, fmt.Sprintf(format, args...))
+	}
+	var err error
+	single := func(predicate string) interface{} {
+		os := g.FindObjects(name, predicate)
+		if len(os) > 1 && err == nil {
+			err = errorf("more than one value for %s", predicate)
+		}
+		if len(os) == 0 {
+			return nil
+		}
+		return os[0]
+	}
+	iri := func(predicate string) string {
+		o := single(predicate)
+		if o == nil {
+			return ""
+		}
+		str, ok := o.(string)
+		if !ok && err == nil {
+			err = errorf("%s must be an IRI", predicate)
+		}
+		return str
+	}
+	count := func(predicate string) int {
+		o := single(predicate)
+		if o == nil {
+			return -1
+		}
+		f, ok := toFloat64(o)
+		if (!ok || f < 0 || f != float64(int(f))) && err == nil {
+			err = errorf("%s must be a non-negative integer", predicate)
+		}
+		return int(f)
+	}
+	iris := func(predicate string) []string {
+		var out []string
+		for _, o := range g.FindObjects(name, predicate) {
+			str, ok := o.(string)
+			if !ok && err == nil {
+				err = errorf("%s must be an IRI", predicate)
+			}
+			out = append(out, str)
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	s.path = iri(shPath)
+	if sev := iri(shSeverity); sev != "" {
+		s.severity = sev
+	}
+	if msg, ok := single(shMessage).(string); ok {
+		s.message = msg
+	}
+	s.targetNodes = g.FindObjects(name, shTargetNode)
+	sortObjects(s.targetNodes)
+	s.targetClasses = iris(shTargetClass)
+	if g.Count(name, RDFType, rdfsClass) > 0 {
+		// Implicit class target.
+		s.targetClasses = append(s.targetClasses, name)
+	}
+	s.targetSubjects = iris(shTargetSubjects)
+	s.targetObjects = iris(shTargetObjects)
+
+	s.minCount = count(shMinCount)
+	s.maxCount = count(shMaxCount)
+	s.datatype = iri(shDatatype)
+	s.classes = iris(shClass)
+	if pattern := single(shPattern); pattern != nil {
+		str, _ := pattern.(string)
+		flags, _ := single(shFlags).(string)
+		if strings.Trim(flags, "ismx") != "" && err == nil {
+			err = errorf("unsupported sh:flags %q", flags)
+		}
+		if flags != "" {
+			str = "(?" + flags + ")" + str
+		}
+		re, rerr := regexp.Compile(str)
+		if rerr != nil && err == nil {
+			err = errorf("invalid sh:pattern: %v", rerr)
+		}
+		s.patterns = append(s.patterns, re)
+	}
+	if list, ok := single(shIn).(string); ok {
+		var lerr error
+		s.hasIn = true
+		s.in, lerr = p.list(list, errorf)
+		if err == nil {
+			err = lerr
+		}
+	}
+	ranges := []struct {
+		predicate, component string
+		ok                   func(cmp int) bool
+	}{
+		{shMinInclusive, "MinInclusiveConstraintComponent", func(c int) bool { return c >= 0 }},
+		{shMaxInclusive, "MaxInclusiveConstraintComponent", func(c int) bool { return c <= 0 }},
+		{shMinExclusive, "MinExclusiveConstraintComponent", func(c int) bool { return c > 0 }},
+		{shMaxExclusive, "MaxExclusiveConstraintComponent", func(c int) bool { return c < 0 }},
+	}
+	for _, r := range ranges {
+		if limit := single(r.predicate); limit != nil {
+			s.ranges = append(s.ranges, shaclRange{r.component, limit, r.ok})
+		}
+	}
+	nodes, properties := iris(shNode), iris(shProperty)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range nodes {
+		x, err := p.shape(ref)
+		if err != nil {
+			return nil, err
+		}
+		if x != nil {
+			s.nodes = append(s.nodes, x)
+		}
+	}
+	for _, ref := range properties {
+		x, err := p.shape(ref)
+		if err != nil {
+			return nil, err
+		}
+		if x == nil {
+			continue
+		}
+		if x.path == "" {
+			return nil, errorf("property shape %s has no sh:path", ref)
+		}
+		s.properties = append(s.properties, x)
+	}
+	return s, nil
+}
+
+// list reads the members of an RDF list from the shapes graph.
+func (p *shaclParser) list(head string, errorf func(format string, args ...interface{}) error) ([]interface{}, error) {
+	var out []interface{}
+	seen := make(map[string]bool)
+	for node := head; node != RDFNil; {
+		if seen[node] {
+			return nil, errorf("cyclic list %s", head)
+		}
+		seen[node] = true
+		first := p.g.FindObjects(node, RDFFirst)
+		rest := p.g.FindObjects(node, RDFRest)
+		if len(first) != 1 || len(rest) != 1 {
+			return nil, errorf("malformed list %s", head)
+		}
+		out = append(out, first[0])
+		next, ok := rest[0].(string)
+		if !ok {
+			return nil, errorf("malformed list %s", head)
+		}
+		node = next
+	}
+	return out, nil
+}
+
+// ValidateStore validates all graphs in the given store, as if they
+// were a single graph, and returns the validation report.
+func (v *SHACLValidator) ValidateStore(s *QuadStore) *ValidationReport {
+	return v.Validate(s.GraphView("*"))
+}
+
+// Validate validates the given data graph, and returns the validation report.
+func (v *SHACLValidator) Validate(data *GraphView) *ValidationReport {
+	x := &shaclValidation{
+		data:     data,
+		checking: make(map[shaclCheck]bool),
+	}
+	for _, s := range v.shapes {
+		for _, focus := range x.targets(s) {
+			x.validate(s, focus, &x.results)
+		}
+	}
+	return &ValidationReport{
+		Conforms: len(x.results) == 0,
+		Results:  x.results,
+	}
+}
+
+// shaclValidation holds the state of a single validation.
+type shaclValidation struct {
+	data    *GraphView
+	results []ValidationResult
+	// checking holds the sh:node checks in progress, so that
+	// recursive shapes terminate.
+	checking map[shaclCheck]bool
+}
+
+// shaclCheck is a focus node being checked against a shape.
+type shaclCheck struct {
+	shape *shaclShape
+	focus interface{}
+}
+
+// targets returns the focus nodes of the given shape, without duplicates.
+func (x *shaclValidation) targets(s *shaclShape) []interface{} {
+	seen := make(map[interface{}]bool)
+	var out []interface{}
+	add := func(o interface{}) {
+		if !seen[o] {
+			seen[o] = true
+			out = append(out, o)
+		}
+	}
+	for _, n := range s.targetNodes {
+		add(n)
+	}
+	for _, c := range s.targetClasses {
+		for _, sub := range x.subClasses(c) {
+			x.data.ForSubjects(RDFType, sub, func(s string) { add(s) })
+		}
+	}
+	for _, p := range s.targetSubjects {
+		x.data.ForSubjects(p, "*", func(s string) { add(s) })
+	}
+	for _, p := range s.targetObjects {
+		x.data.ForObjects("*", p, add)
+	}
+	sortObjects(out)
+	return out
+}
+
+// subClasses returns the given class and all of its subclasses in the data graph.
+func (x *shaclValidation) subClasses(class string) []string {
+	seen := map[string]bool{class: true}
+	out := []string{class}
+	for i := 0; i < len(out); i++ {
+		x.data.ForSubjects(RDFSSubClassOf, out[i], func(s string) {
+			if !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		})
+	}
+	return out
+}
+
+// isInstance returns true if the given node is an instance of the given
+// class, or of one of its subclasses, in the data graph.
+func (x *shaclValidation) isInstance(node interface{}, class string) bool {
+	s, ok := node.(string)
+	if !ok {
+		return false
+	}
+	for _, c := range x.subClasses(class) {
+		if x.data.Count(s, RDFType, c) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// values returns the value nodes of the given shape for the given focus node.
+func (x *shaclValidation) values(s *shaclShape, focus interface{}) []interface{} {
+	if s.path == "" {
+		return []interface{}{focus}
+	}
+	subject, ok := focus.(string)
+	if !ok {
+		return nil
+	}
+	seen := make(map[interface{}]bool)
+	var out []interface{}
+	x.data.ForObjects(subject, s.path, func(o interface{}) {
+		if !seen[o] {
+			seen[o] = true
+			out = append(out, o)
+		}
+	})
+	sortObjects(out)
+	return out
+}
+
+// conforms returns true if the given node conforms to the given shape.
+func (x *shaclValidation) conforms(s *shaclShape, node interface{}) bool {
+	key := shaclCheck{s, node}
+	if x.checking[key] {
+		// Assume recursive checks conform.
+		return true
+	}
+	x.checking[key] = true
+	defer delete(x.checking, key)
+	var results []ValidationResult
+	x.validate(s, node, &results)
+	return len(results) == 0
+}
+
+// validate validates the given focus node against the given shape,
+// appending any results.
+func (x *shaclValidation) validate(s *shaclShape, focus interface{}, results *[]ValidationResult) {
+	report := func(component string, value interface{}) {
+		*results = append(*results, ValidationResult{
+			FocusNode:                 focus,
+			ResultPath:                s.path,
+			Value:                     value,
+			SourceShape:               s.name,
+			SourceConstraintComponent: SHACLNamespace + component,
+			Severity:                  s.severity,
+			Message:                   s.message,
+		})
+	}
+	values := x.values(s, focus)
+	if s.minCount >= 0 && len(values) < s.minCount {
+		report("MinCountConstraintComponent", nil)
+	}
+	if s.maxCount >= 0 && len(values) > s.maxCount {
+		report("MaxCountConstraintComponent", nil)
+	}
+	for _, v := range values {
+		if s.datatype != "" && xsdDatatype(v) != s.datatype {
+			report("DatatypeConstraintComponent", v)
+		}
+		for _, c := range s.classes {
+			if !x.isInstance(v, c) {
+				report("ClassConstraintComponent", v)
+			}
+		}
+		for _, re := range s.patterns {
+			if isBlankNode(v) || !re.MatchString(lexicalForm(v)) {
+				report("PatternConstraintComponent", v)
+			}
+		}
+		if s.hasIn && !containsValue(s.in, v) {
+			report("InConstraintComponent", v)
+		}
+		for _, r := range s.ranges {
+			if cmp, ok := compareValues(v, r.limit); !ok || !r.ok(cmp) {
+				report(r.component, v)
+			}
+		}
+		for _, n := range s.nodes {
+			if !x.conforms(n, v) {
+				report("NodeConstraintComponent", v)
+			}
+		}
+		for _, p := range s.properties {
+			x.validate(p, v, results)
+		}
+	}
+}
+
+// xsdDatatype returns the IRI of the XSD datatype of the given value.
+func xsdDatatype(v interface{}) string {
+	switch x := v.(type) {
+	case Literal:
+		return x.datatype()
+	case string:
+		if isBlankNode(v) {
+			return ""
+		}
+		return XSDNamespace + "string"
+	case bool:
+		return XSDNamespace + "boolean"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return XSDNamespace + "integer"
+	case float32:
+		return XSDNamespace + "float"
+	case float64:
+		return XSDNamespace + "double"
+	case time.Time:
+		return XSDNamespace + "dateTime"
+	}
+	return ""
+}
+
+// isBlankNode returns true if the given term is a blank node.
+func isBlankNode(t interface{}) bool {
+	s, ok := t.(string)
+	return ok && strings.HasPrefix(s, "_:")
+}
+
+// containsValue returns true if the given slice holds the given value.
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
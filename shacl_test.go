@@ -0,0 +1,316 @@
+package store4_test
+
+import (
+	"time"
+
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SHACLValidator", func() {
+
+	const (
+		sh  = SHACLNamespace
+		xsd = XSDNamespace
+	)
+
+	// newShapes returns a shapes graph, holding a PersonShape
+	// with the given property constraints on the "name" property.
+	newShapes := func(constraints ...[2]interface{}) *GraphView {
+		shapes := NewGraph([][3]string{
+			{"PersonShape", RDFType, sh + "NodeShape"},
+			{"PersonShape", sh + "targetClass", "Person"},
+			{"PersonShape", sh + "property", "_:name"},
+			{"_:name", sh + "path", "name"},
+		})
+		for _, c := range constraints {
+			shapes.Add("_:name", c[0].(string), c[1])
+		}
+		return shapes
+	}
+
+	validate := func(shapes, data *GraphView) *ValidationReport {
+		v, err := NewSHACLValidator(shapes)
+		Expect(err).NotTo(HaveOccurred())
+		return v.Validate(data)
+	}
+
+	components := func(r *ValidationReport) []string {
+		var out []string
+		for _, x := range r.Results {
+			out = append(out, x.SourceConstraintComponent)
+		}
+		return out
+	}
+
+	It("should report conforming data", func() {
+		data := NewGraph([][3]string{
+			{"alice", RDFType, "Person"},
+			{"alice", "name", "Alice"},
+		})
+		r := validate(newShapes([2]interface{}{sh + "minCount", 1}), data)
+		Expect(r.Conforms).To(BeTrue())
+		Expect(r.Results).To(BeEmpty())
+	})
+
+	It("should check cardinality", func() {
+		data := NewGraph([][3]string{
+			{"alice", RDFType, "Person"},
+			{"bob", RDFType, "Person"},
+			{"bob", "name", "Bob"},
+			{"bob", "name", "Robert"},
+		})
+		shapes := newShapes(
+			[2]interface{}{sh + "minCount", 1},
+			[2]interface{}{sh + "maxCount", 1},
+		)
+		r := validate(shapes, data)
+		Expect(r.Conforms).To(BeFalse())
+		Expect(r.Results).To(Equal([]ValidationResult{
+			{
+				FocusNode:                 "alice",
+				ResultPath:                "name",
+				SourceShape:               "_:name",
+				SourceConstraintComponent: sh + "MinCountConstraintComponent",
+				Severity:                  sh + "Violation",
+			},
+			{
+				FocusNode:                 "bob",
+				ResultPath:                "name",
+				SourceShape:               "_:name",
+				SourceConstraintComponent: sh + "MaxCountConstraintComponent",
+				Severity:                  sh + "Violation",
+			},
+		}))
+	})
+
+	It("should check datatypes", func() {
+		data := NewGraph([][3]string{
+			{"alice", RDFType, "Person"},
+			{"bob", RDFType, "Person"},
+		})
+		data.Add("alice", "name", "Alice")
+		data.Add("bob", "name", 42)
+		r := validate(newShapes([2]interface{}{sh + "datatype", xsd + "string"}), data)
+		Expect(r.Results).To(HaveLen(1))
+		Expect(r.Results[0].FocusNode).To(Equal("bob"))
+		Expect(r.Results[0].Value).To(Equal(42))
+		Expect(r.Results[0].SourceConstraintComponent).To(Equal(sh + "DatatypeConstraintComponent"))
+
+		data.Add("carol", RDFType, "Person")
+		data.Add("carol", "name", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+		r = validate(newShapes([2]interface{}{sh + "datatype", xsd + "dateTime"}), data)
+		Expect(r.Results).To(HaveLen(2))
+	})
+
+	It("should check the datatypes and lexical forms of Literals", func() {
+		data := NewGraph([][3]string{
+			{"alice", RDFType, "Person"},
+			{"bob", RDFType, "Person"},
+			{"carol", RDFType, "Person"},
+		})
+		data.Add("alice", "name", Literal{Value: "Alice"})
+		data.Add("bob", "name", Literal{Value: "bob", Language: "en"})
+		data.Add("carol", "name", Literal{Value: "7", Datatype: xsd + "integer"})
+		r := validate(newShapes([2]interface{}{sh + "datatype", xsd + "string"}), data)
+		Expect(r.Results).To(HaveLen(2))
+		r = validate(newShapes([2]interface{}{sh + "pattern", "^[A-Z]"}), data)
+		Expect(r.Results).To(HaveLen(2))
+		r = validate(newShapes([2]interface{}{sh + "pattern", "^[a-z]+$"}), data)
+		Expect(r.Results).To(HaveLen(2))
+		Expect([]interface{}{r.Results[0].FocusNode, r.Results[1].FocusNode}).To(ConsistOf("alice", "carol"))
+	})
+
+	It("should check classes, including subclasses", func() {
+		shapes := NewGraph([][3]string{
+			{"PetShape", sh + "targetSubjectsOf", "ownsPet"},
+			{"PetShape", sh + "property", "_:pet"},
+			{"_:pet", sh + "path", "ownsPet"},
+			{"_:pet", sh + "class", "Animal"},
+		})
+		data := NewGraph([][3]string{
+			{"Dog", RDFSSubClassOf, "Animal"},
+			{"rex", RDFType, "Dog"},
+			{"alice", "ownsPet", "rex"},
+			{"bob", "ownsPet", "rock"},
+		})
+		r := validate(shapes, data)
+		Expect(components(r)).To(Equal([]string{sh + "ClassConstraintComponent"}))
+		Expect(r.Results[0].FocusNode).To(Equal("bob"))
+		Expect(r.Results[0].Value).To(Equal("rock"))
+	})
+
+	It("should check patterns", func() {
+		data := NewGraph([][3]string{
+			{"alice", RDFType, "Person"},
+			{"alice", "name", "Alice"},
+			{"bob", RDFType, "Person"},
+			{"bob", "name", "bob"},
+		})
+		r := validate(newShapes([2]interface{}{sh + "pattern", "^[A-Z]"}), data)
+		Expect(r.Results).To(HaveLen(1))
+		Expect(r.Results[0].Value).To(Equal("bob"))
+		r = validate(newShapes(
+			[2]interface{}{sh + "pattern", "^[A-Z]"},
+			[2]interface{}{sh + "flags", "i"},
+		), data)
+		Expect(r.Conforms).To(BeTrue())
+	})
+
+	It("should check sh:in", func() {
+		shapes := newShapes([2]interface{}{sh + "in", "_:l1"})
+		shapes.Add("_:l1", RDFFirst, "Alice")
+		shapes.Add("_:l1", RDFRest, "_:l2")
+		shapes.Add("_:l2", RDFFirst, "Bob")
+		shapes.Add("_:l2", RDFRest, RDFNil)
+		data := NewGraph([][3]string{
+			{"alice", RDFType, "Person"},
+			{"alice", "name", "Alice"},
+			{"carol", RDFType, "Person"},
+			{"carol", "name", "Carol"},
+		})
+		r := validate(shapes, data)
+		Expect(components(r)).To(Equal([]string{sh + "InConstraintComponent"}))
+		Expect(r.Results[0].Value).To(Equal("Carol"))
+	})
+
+	It("should check value ranges", func() {
+		shapes := NewGraph([][3]string{
+			{"AgeShape", sh + "targetSubjectsOf", "age"},
+			{"AgeShape", sh + "property", "_:age"},
+			{"_:age", sh + "path", "age"},
+		})
+		shapes.Add("_:age", sh+"minInclusive", 0)
+		shapes.Add("_:age", sh+"maxExclusive", 150.0)
+		data := NewGraph()
+		data.Add("a", "age", 0)
+		data.Add("b", "age", 149.5)
+		data.Add("c", "age", -1)
+		data.Add("d", "age", 150)
+		data.Add("e", "age", "old")
+		r := validate(shapes, data)
+		Expect(components(r)).To(Equal([]string{
+			sh + "MinInclusiveConstraintComponent",
+			sh + "MaxExclusiveConstraintComponent",
+			sh + "MinInclusiveConstraintComponent",
+			sh + "MaxExclusiveConstraintComponent",
+		}))
+		Expect(r.Results[0].FocusNode).To(Equal("c"))
+		Expect(r.Results[1].FocusNode).To(Equal("d"))
+		Expect(r.Results[2].FocusNode).To(Equal("e"))
+	})
+
+	It("should check nested node shapes", func() {
+		shapes := NewGraph([][3]string{
+			{"PersonShape", sh + "targetNode", "alice"},
+			{"PersonShape", sh + "targetNode", "bob"},
+			{"PersonShape", sh + "property", "_:address"},
+			{"_:address", sh + "path", "address"},
+			{"_:address", sh + "node", "AddressShape"},
+			{"AddressShape", RDFType, sh + "NodeShape"},
+			{"AddressShape", sh + "property", "_:city"},
+			{"_:city", sh + "path", "city"},
+		})
+		shapes.Add("_:city", sh+"minCount", 1)
+		data := NewGraph([][3]string{
+			{"alice", "address", "_:a1"},
+			{"_:a1", "city", "Paris"},
+			{"bob", "address", "_:a2"},
+		})
+		r := validate(shapes, data)
+		Expect(components(r)).To(Equal([]string{sh + "NodeConstraintComponent"}))
+		Expect(r.Results[0].FocusNode).To(Equal("bob"))
+		Expect(r.Results[0].Value).To(Equal("_:a2"))
+	})
+
+	It("should terminate for recursive shapes", func() {
+		shapes := NewGraph([][3]string{
+			{"PersonShape", sh + "targetClass", "Person"},
+			{"PersonShape", sh + "property", "_:knows"},
+			{"_:knows", sh + "path", "knows"},
+			{"_:knows", sh + "node", "PersonShape"},
+		})
+		data := NewGraph([][3]string{
+			{"alice", RDFType, "Person"},
+			{"alice", "knows", "bob"},
+			{"bob", "knows", "alice"},
+		})
+		Expect(validate(shapes, data).Conforms).To(BeTrue())
+	})
+
+	It("should use the shape's severity and message, and skip deactivated shapes", func() {
+		shapes := newShapes([2]interface{}{sh + "minCount", 1})
+		shapes.Add("_:name", sh+"severity", sh+"Warning")
+		shapes.Add("_:name", sh+"message", "Name is missing")
+		shapes.Add("OtherShape", sh+"targetClass", "Person")
+		shapes.Add("OtherShape", sh+"maxCount", 0)
+		shapes.Add("OtherShape", sh+"deactivated", true)
+		data := NewGraph([][3]string{
+			{"alice", RDFType, "Person"},
+		})
+		r := validate(shapes, data)
+		Expect(r.Results).To(HaveLen(1))
+		Expect(r.Results[0].Severity).To(Equal(sh + "Warning"))
+		Expect(r.Results[0].Message).To(Equal("Name is missing"))
+	})
+
+	It("should validate a whole store", func() {
+		store := NewQuadStore([][4]string{
+			{"alice", RDFType, "Person", "g1"},
+			{"alice", "name", "Alice", "g2"},
+			{"bob", RDFType, "Person", "g2"},
+		})
+		v, err := NewSHACLValidator(newShapes([2]interface{}{sh + "minCount", 1}))
+		Expect(err).NotTo(HaveOccurred())
+		r := v.ValidateStore(store)
+		Expect(r.Results).To(HaveLen(1))
+		Expect(r.Results[0].FocusNode).To(Equal("bob"))
+	})
+
+	It("should return errors for malformed shapes", func() {
+		_, err := NewSHACLValidator(newShapes([2]interface{}{sh + "pattern", "("}))
+		Expect(err).To(MatchError(ContainSubstring("invalid SHACL shape _:name: invalid sh:pattern")))
+		_, err = NewSHACLValidator(newShapes([2]interface{}{sh + "minCount", -1}))
+		Expect(err).To(MatchError(ContainSubstring("must be a non-negative integer")))
+		_, err = NewSHACLValidator(newShapes([2]interface{}{sh + "in", "_:missing"}))
+		Expect(err).To(MatchError(ContainSubstring("malformed list")))
+		_, err = NewSHACLValidator(newShapes([2]interface{}{sh + "path", "other"}))
+		Expect(err).To(MatchError(ContainSubstring("more than one value")))
+		shapes := newShapes()
+		shapes.Add("PersonShape", sh+"property", "_:nopath")
+		shapes.Add("_:nopath", sh+"minCount", 1)
+		_, err = NewSHACLValidator(shapes)
+		Expect(err).To(MatchError(ContainSubstring("has no sh:path")))
+	})
+
+	Describe("ValidationReport", func() {
+
+		It("should be convertible to quads", func() {
+			data := NewGraph([][3]string{
+				{"alice", RDFType, "Person"},
+			})
+			shapes := newShapes([2]interface{}{sh + "minCount", 1})
+			shapes.Add("_:name", sh+"message", "Name is missing")
+			g := validate(shapes, data).Graph()
+			Expect(g.FindSubjects(RDFType, sh+"ValidationReport")).To(Equal([]string{"_:report"}))
+			Expect(g.FindObjects("_:report", sh+"conforms")).To(Equal([]interface{}{false}))
+			Expect(g.FindObjects("_:report", sh+"result")).To(Equal([]interface{}{"_:result1"}))
+			res := g.QuadStore.SubjectView("_:result1", "")
+			Expect(res.Map()).To(Equal(map[string][]interface{}{
+				RDFType:                          {sh + "ValidationResult"},
+				sh + "focusNode":                 {"alice"},
+				sh + "resultPath":                {"name"},
+				sh + "sourceShape":               {"_:name"},
+				sh + "sourceConstraintComponent": {sh + "MinCountConstraintComponent"},
+				sh + "resultSeverity":            {sh + "Violation"},
+				sh + "resultMessage":             {"Name is missing"},
+			}))
+
+			g = validate(newShapes(), data).Graph()
+			Expect(g.FindObjects("_:report", sh+"conforms")).To(Equal([]interface{}{true}))
+			Expect(g.Size()).To(Equal(uint64(2)))
+		})
+	})
+})
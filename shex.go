@@ -0,0 +1,759 @@
+package store4
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ShExSchema is a set of ShEx (Shape Expressions) shapes,
+// against which subjects can be validated.
+//
+// Returned by calls to ParseShExC.
+type ShExSchema struct {
+	shapes   map[string]*shexShape
+	prefixes map[string]string
+}
+
+// ShapeMapEntry associates a focus node with the shape it should conform to.
+type ShapeMapEntry struct {
+	Node  string
+	Shape string
+}
+
+// ShExResult is the result of validating a focus node against a shape.
+type ShExResult struct {
+	Node     string
+	Shape    string
+	Conforms bool
+	// Reasons holds a description of each failure, if the node does not conform.
+	Reasons []string
+}
+
+// shexShape is a parsed shape: a conjunction of triple constraints.
+type shexShape struct {
+	label       string
+	closed      bool
+	constraints []*shexConstraint
+}
+
+// shexConstraint is a triple constraint: a predicate, a value expression
+// and a cardinality.
+type shexConstraint struct {
+	predicate string
+	value     []shexAtom
+	min       int
+	// max is -1 for unbounded.
+	max int
+}
+
+// shexAtom is one part of a value expression, which holds if all of its atoms hold.
+type shexAtom struct {
+	kind     string // "any", "nodeKind", "datatype", "values", "pattern" or "shape"
+	text     string // node kind, datatype IRI, pattern source or shape label
+	values   []interface{}
+	re       *regexp.Regexp
+	describe string
+}
+
+// ParseShExC parses a ShEx schema written in the ShEx compact syntax (ShExC).
+//
+// A subset of ShExC is supported: PREFIX declarations, and shapes
+// holding triple constraints separated by ';' (an EachOf), optionally
+// marked CLOSED. For example:
+//
+//  PREFIX ex: <http://example.org/>
+//  PREFIX xsd: <http://www.w3.org/2001/XMLSchema#>
+//  ex:PersonShape CLOSED {
+//    a [ex:Person] ;
+//    ex:name xsd:string ;
+//    ex:age xsd:integer ? ;
+//    ex:email IRI /^mailto:/ * ;
+//    ex:knows @ex:PersonShape {0,5}
+//  }
+//
+// A value expression is a sequence of one or more of: '.' (any value),
+// a node kind (IRI, BNODE, LITERAL or NONLITERAL), a datatype, a value set
+// in square brackets, a /regex/, or a shape reference (@label) — all of which
+// must hold. Cardinalities are '?', '*', '+', {m}, {m,} or {m,n}, with a
+// default of exactly one. Comments start with a '#' and run to the end of the line.
+//
+// Values are mapped to datatypes by their Go type, as with SHACLValidator.
+// Because plain strings may hold either IRIs or string literals, they
+// satisfy both the IRI and LITERAL node kinds. Strings beginning with "_:"
+// are blank nodes.
+func ParseShExC(text string) (*ShExSchema, error) {
+	p := &shexParser{
+		shexLexer: &shexLexer{src: []rune(text), line: 1},
+		schema: &ShExSchema{
+			shapes:   make(map[string]*shexShape),
+			prefixes: make(map[string]string),
+		},
+		refs: make(map[string]shexToken),
+	}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.schema, nil
+}
+
+// Shapes returns the labels of all shapes in the schema, sorted.
+func (x *ShExSchema) Shapes() []string {
+	out := make([]string, 0, len(x.shapes))
+	for label := range x.shapes {
+		out = append(out, label)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ParseShapeMap parses a fixed shape map, using the prefixes declared in
+// the schema. Entries are separated by commas, and each is a node and
+// a shape label separated by '@', for example:
+//  ex:alice@ex:PersonShape, <http://example.org/bob>@ex:PersonShape
+func (x *ShExSchema) ParseShapeMap(text string) ([]ShapeMapEntry, error) {
+	p := &shexParser{
+		shexLexer: &shexLexer{src: []rune(text), line: 1},
+		schema:    x,
+	}
+	var out []ShapeMapEntry
+	for {
+		node, err := p.iri(p.next())
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); !tok.is("@") {
+			return nil, p.errorf(tok, "expected '@', got %v", tok)
+		}
+		shape, err := p.iri(p.next())
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ShapeMapEntry{Node: node, Shape: shape})
+		tok := p.next()
+		if tok.kind == shexEOF {
+			return out, nil
+		}
+		if !tok.is(",") {
+			return nil, p.errorf(tok, "expected ',', got %v", tok)
+		}
+	}
+}
+
+// Validate validates the focus nodes of the given shape map,
+// against subjects in the given graph, and returns a result for each entry.
+func (x *ShExSchema) Validate(g *GraphView, shapeMap []ShapeMapEntry) []ShExResult {
+	out := make([]ShExResult, len(shapeMap))
+	for i, e := range shapeMap {
+		out[i] = x.ValidateSubject(g.SubjectView(e.Node), e.Shape)
+	}
+	return out
+}
+
+// ValidateSubject validates the given subject against the given shape.
+// Shape references are checked against subjects in the same graph.
+func (x *ShExSchema) ValidateSubject(v *SubjectView, shape string) ShExResult {
+	r := ShExResult{Node: v.Subject, Shape: shape}
+	sh, ok := x.shapes[shape]
+	if !ok {
+		r.Reasons = []string{fmt.Sprintf("unknown shape <%s>", shape)}
+		return r
+	}
+	c := &shexValidation{
+		store:    v.QuadStore,
+		graph:    v.Graph,
+		schema:   x,
+		checking: make(map[[2]string]bool),
+	}
+	r.Reasons = c.check(v.Subject, sh)
+	r.Conforms = len(r.Reasons) == 0
+	return r
+}
+
+// shexValidation holds the state of a single validation.
+type shexValidation struct {
+	store  *QuadStore
+	graph  string
+	schema *ShExSchema
+	// checking holds the node-shape pairs being checked, so that
+	// recursive shapes terminate.
+	checking map[[2]string]bool
+}
+
+// check validates the given node against the given shape,
+// returning the reasons for any failure.
+func (c *shexValidation) check(node string, sh *shexShape) []string {
+	key := [2]string{node, sh.label}
+	if c.checking[key] {
+		// Assume recursive checks conform.
+		return nil
+	}
+	c.checking[key] = true
+	defer delete(c.checking, key)
+
+	// Group the node's triples and the shape's constraints by predicate.
+	objects := make(map[string][]interface{})
+	var predicates []string
+	c.store.ForEachWith(node, "*", "*", c.graph, func(s, p string, o interface{}, g string) {
+		if _, ok := objects[p]; !ok {
+			predicates = append(predicates, p)
+		}
+		objects[p] = append(objects[p], o)
+	})
+	constraints := make(map[string][]*shexConstraint)
+	for _, tc := range sh.constraints {
+		if _, ok := constraints[tc.predicate]; !ok {
+			predicates = append(predicates, tc.predicate)
+		}
+		constraints[tc.predicate] = append(constraints[tc.predicate], tc)
+	}
+	sort.Strings(predicates)
+	var reasons []string
+	done := make(map[string]bool)
+	for _, p := range predicates {
+		if done[p] {
+			continue
+		}
+		done[p] = true
+		os := objects[p]
+		sortObjects(os)
+		tcs := constraints[p]
+		if len(tcs) == 0 {
+			if sh.closed {
+				reasons = append(reasons, fmt.Sprintf("unexpected <%s> in closed shape", p))
+			}
+			continue
+		}
+		reasons = append(reasons, c.match(p, os, tcs)...)
+	}
+	return reasons
+}
+
+// match checks the given objects of a predicate against the constraints
+// on that predicate, returning the reasons for any failure.
+func (c *shexValidation) match(p string, os []interface{}, tcs []*shexConstraint) []string {
+	if len(tcs) == 1 {
+		// The common case, which can give precise reasons.
+		tc := tcs[0]
+		var reasons []string
+		for _, o := range os {
+			if reason := c.satisfies(o, tc); reason != "" {
+				reasons = append(reasons, fmt.Sprintf("value %v for <%s> %s", formatShExValue(o), p, reason))
+			}
+		}
+		if len(reasons) > 0 {
+			return reasons
+		}
+		if len(os) < tc.min || (tc.max >= 0 && len(os) > tc.max) {
+			return []string{fmt.Sprintf("expected %s <%s>, found %d", tc.cardinality(), p, len(os))}
+		}
+		return nil
+	}
+	// Several constraints on the same predicate: find an assignment
+	// of objects to constraints that satisfies every cardinality.
+	ok := make([][]bool, len(os))
+	for i, o := range os {
+		ok[i] = make([]bool, len(tcs))
+		for j, tc := range tcs {
+			ok[i][j] = c.satisfies(o, tc) == ""
+		}
+	}
+	counts := make([]int, len(tcs))
+	var assign func(i int) bool
+	assign = func(i int) bool {
+		if i == len(os) {
+			for j, tc := range tcs {
+				if counts[j] < tc.min {
+					return false
+				}
+			}
+			return true
+		}
+		for j, tc := range tcs {
+			if ok[i][j] && (tc.max < 0 || counts[j] < tc.max) {
+				counts[j]++
+				if assign(i + 1) {
+					return true
+				}
+				counts[j]--
+			}
+		}
+		return false
+	}
+	if assign(0) {
+		return nil
+	}
+	return []string{fmt.Sprintf("values for <%s> do not match its %d constraints", p, len(tcs))}
+}
+
+// satisfies checks a value against a constraint's value expression,
+// returning the reason for any failure, or "" if the value satisfies it.
+func (c *shexValidation) satisfies(o interface{}, tc *shexConstraint) string {
+	for _, a := range tc.value {
+		switch a.kind {
+		case "nodeKind":
+			s, isString := o.(string)
+			blank := isString && strings.HasPrefix(s, "_:")
+			var ok bool
+			switch a.text {
+			case "IRI":
+				ok = isString && !blank
+			case "BNODE":
+				ok = blank
+			case "LITERAL":
+				ok = !blank
+			case "NONLITERAL":
+				ok = isString
+			}
+			if !ok {
+				return "is not " + a.describe
+			}
+		case "datatype":
+			if xsdDatatype(o) != a.text {
+				return "is not " + a.describe
+			}
+		case "values":
+			if !containsValue(a.values, o) {
+				return "is not in " + a.describe
+			}
+		case "pattern":
+			if isBlankNode(o) || !a.re.MatchString(lexicalForm(o)) {
+				return "does not match " + a.describe
+			}
+		case "shape":
+			s, ok := o.(string)
+			if !ok {
+				return "is a literal, not " + a.describe
+			}
+			if reasons := c.check(s, c.schema.shapes[a.text]); len(reasons) > 0 {
+				return fmt.Sprintf("does not conform to %s: %s", a.describe, reasons[0])
+			}
+		}
+	}
+	return ""
+}
+
+// cardinality returns a description of the constraint's cardinality.
+func (tc *shexConstraint) cardinality() string {
+	switch {
+	case tc.min == tc.max:
+		return fmt.Sprintf("exactly %d", tc.min)
+	case tc.max < 0:
+		return fmt.Sprintf("at least %d", tc.min)
+	case tc.min == 0:
+		return fmt.Sprintf("at most %d", tc.max)
+	}
+	return fmt.Sprintf("between %d and %d", tc.min, tc.max)
+}
+
+// formatShExValue formats a value for use in failure reasons.
+func formatShExValue(o interface{}) string {
+	if s, ok := o.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(o)
+}
+
+type shexParser struct {
+	*shexLexer
+	schema *ShExSchema
+	// refs holds the first reference to each shape label, for error reporting.
+	refs map[string]shexToken
+}
+
+func (p *shexParser) parse() error {
+	for {
+		tok := p.next()
+		switch {
+		case tok.kind == shexEOF:
+			for _, label := range sortedKeys(p.refs) {
+				if _, ok := p.schema.shapes[label]; !ok {
+					return p.errorf(p.refs[label], "undefined shape <%s>", label)
+				}
+			}
+			return nil
+		case tok.kind == shexIdent && strings.EqualFold(tok.text, "PREFIX"):
+			if err := p.parsePrefix(); err != nil {
+				return err
+			}
+		default:
+			if err := p.parseShape(tok); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *shexParser) parsePrefix() error {
+	name := p.next()
+	if name.kind != shexIdent || !strings.HasSuffix(name.text, ":") {
+		return p.errorf(name, "expected prefix name, got %v", name)
+	}
+	iri := p.next()
+	if iri.kind != shexIRI {
+		return p.errorf(iri, "expected IRI, got %v", iri)
+	}
+	p.schema.prefixes[strings.TrimSuffix(name.text, ":")] = iri.text
+	return nil
+}
+
+func (p *shexParser) parseShape(labelTok shexToken) error {
+	label, err := p.iri(labelTok)
+	if err != nil {
+		return err
+	}
+	if _, ok := p.schema.shapes[label]; ok {
+		return p.errorf(labelTok, "duplicate shape <%s>", label)
+	}
+	sh := &shexShape{label: label}
+	tok := p.next()
+	if tok.kind == shexIdent && strings.EqualFold(tok.text, "CLOSED") {
+		sh.closed = true
+		tok = p.next()
+	}
+	if !tok.is("{") {
+		return p.errorf(tok, "expected '{', got %v", tok)
+	}
+	for {
+		tok = p.next()
+		if tok.is("}") {
+			break
+		}
+		tc, err := p.parseConstraint(tok)
+		if err != nil {
+			return err
+		}
+		sh.constraints = append(sh.constraints, tc)
+		tok = p.next()
+		if tok.is("}") {
+			break
+		}
+		if tok.is("|") {
+			return p.errorf(tok, "OneOf ('|') is not supported")
+		}
+		if !tok.is(";") {
+			return p.errorf(tok, "expected ';' or '}', got %v", tok)
+		}
+	}
+	p.schema.shapes[label] = sh
+	return nil
+}
+
+func (p *shexParser) parseConstraint(tok shexToken) (*shexConstraint, error) {
+	tc := &shexConstraint{min: 1, max: 1}
+	if tok.kind == shexIdent && tok.text == "a" {
+		tc.predicate = RDFType
+	} else {
+		pred, err := p.iri(tok)
+		if err != nil {
+			return nil, err
+		}
+		tc.predicate = pred
+	}
+	for {
+		tok := p.peek()
+		if tok.kind == shexEOF || (tok.kind == shexPunct && !tok.is(".") && !tok.is("@") && !tok.is("[")) {
+			// Not the start of an atom.
+			break
+		}
+		p.next()
+		a, err := p.parseAtom(tok)
+		if err != nil {
+			return nil, err
+		}
+		tc.value = append(tc.value, a)
+	}
+	if len(tc.value) == 0 {
+		return nil, p.errorf(p.peek(), "expected value expression, got %v", p.peek())
+	}
+	tok = p.peek()
+	switch {
+	case tok.is("?"):
+		tc.min, tc.max = 0, 1
+	case tok.is("*"):
+		tc.min, tc.max = 0, -1
+	case tok.is("+"):
+		tc.min, tc.max = 1, -1
+	case tok.is("{"):
+		p.next()
+		return tc, p.parseRepeat(tc)
+	default:
+		return tc, nil
+	}
+	p.next()
+	return tc, nil
+}
+
+// parseRepeat parses a {m}, {m,} or {m,n} cardinality, after the '{'.
+func (p *shexParser) parseRepeat(tc *shexConstraint) error {
+	number := func() (int, error) {
+		tok := p.next()
+		n, err := strconv.Atoi(tok.text)
+		if tok.kind != shexIdent || err != nil || n < 0 {
+			return 0, p.errorf(tok, "expected a non-negative integer, got %v", tok)
+		}
+		return n, nil
+	}
+	min, err := number()
+	if err != nil {
+		return err
+	}
+	tc.min, tc.max = min, min
+	tok := p.next()
+	if tok.is(",") {
+		tc.max = -1
+		if !p.peek().is("}") {
+			if tc.max, err = number(); err != nil {
+				return err
+			}
+			if tc.max < tc.min {
+				return p.errorf(p.last, "maximum cardinality is less than minimum")
+			}
+		}
+		tok = p.next()
+	}
+	if !tok.is("}") {
+		return p.errorf(tok, "expected '}', got %v", tok)
+	}
+	return nil
+}
+
+func (p *shexParser) parseAtom(tok shexToken) (shexAtom, error) {
+	switch {
+	case tok.is("."):
+		return shexAtom{kind: "any", describe: "any value"}, nil
+	case tok.is("@"):
+		ref := p.next()
+		label, err := p.iri(ref)
+		if err != nil {
+			return shexAtom{}, err
+		}
+		if _, ok := p.refs[label]; !ok {
+			p.refs[label] = ref
+		}
+		return shexAtom{kind: "shape", text: label, describe: "@<" + label + ">"}, nil
+	case tok.is("["):
+		a := shexAtom{kind: "values"}
+		var descs []string
+		for {
+			tok := p.next()
+			if tok.is("]") {
+				break
+			}
+			v, err := p.value(tok)
+			if err != nil {
+				return shexAtom{}, err
+			}
+			a.values = append(a.values, v)
+			descs = append(descs, formatShExValue(v))
+		}
+		a.describe = "[" + strings.Join(descs, " ") + "]"
+		return a, nil
+	case tok.kind == shexRegex:
+		re, err := regexp.Compile(tok.text)
+		if err != nil {
+			return shexAtom{}, p.errorf(tok, "invalid regular expression: %v", err)
+		}
+		return shexAtom{kind: "pattern", text: tok.text, re: re, describe: "/" + tok.text + "/"}, nil
+	case tok.kind == shexIdent:
+		switch kind := strings.ToUpper(tok.text); kind {
+		case "IRI":
+			return shexAtom{kind: "nodeKind", text: kind, describe: "an IRI"}, nil
+		case "BNODE", "LITERAL", "NONLITERAL":
+			return shexAtom{kind: "nodeKind", text: kind, describe: "a " + kind}, nil
+		}
+	}
+	dt, err := p.iri(tok)
+	if err != nil {
+		return shexAtom{}, err
+	}
+	return shexAtom{kind: "datatype", text: dt, describe: "<" + dt + ">"}, nil
+}
+
+// value parses a member of a value set.
+func (p *shexParser) value(tok shexToken) (interface{}, error) {
+	switch tok.kind {
+	case shexString:
+		return tok.text, nil
+	case shexIdent:
+		t := tok.text
+		if t == "true" || t == "false" {
+			return t == "true", nil
+		}
+		if n, ok := parseNumber(t); ok {
+			return n, nil
+		}
+	}
+	return p.iri(tok)
+}
+
+// iri parses an IRI, or a prefixed name.
+func (p *shexParser) iri(tok shexToken) (string, error) {
+	switch tok.kind {
+	case shexIRI:
+		return tok.text, nil
+	case shexIdent:
+		if strings.IndexByte(tok.text, ':') >= 0 {
+			iri, ok := expandPrefixedName(tok.text, p.schema.prefixes)
+			if !ok {
+				return "", p.errorf(tok, "undeclared prefix %q", prefixOf(tok.text))
+			}
+			return iri, nil
+		}
+	}
+	return "", p.errorf(tok, "expected IRI, got %v", tok)
+}
+
+// sortedKeys returns the keys of the given map, sorted.
+func sortedKeys(m map[string]shexToken) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+type shexTokenKind int
+
+const (
+	shexEOF shexTokenKind = iota
+	shexIdent
+	shexIRI
+	shexString
+	shexRegex
+	shexPunct
+)
+
+type shexToken struct {
+	kind shexTokenKind
+	text string
+	line int
+}
+
+// is returns true if the token is the given punctuation.
+func (t shexToken) is(punct string) bool {
+	return t.kind == shexPunct && t.text == punct
+}
+
+// String returns a description of the token, for use in error messages.
+func (t shexToken) String() string {
+	if t.kind == shexEOF {
+		return "end of input"
+	}
+	return strconv.Quote(t.text)
+}
+
+// shexLexer splits ShExC text into tokens.
+type shexLexer struct {
+	src    []rune
+	pos    int
+	line   int
+	peeked *shexToken
+	last   shexToken
+}
+
+func (l *shexLexer) errorf(tok shexToken, format string, args ...interface{}) error {
+	return fmt.Errorf("ShExC syntax error at line %d: %s", tok.line, fmt.Sprintf(format, args...))
+}
+
+func (l *shexLexer) peek() shexToken {
+	if l.peeked == nil {
+		t := l.scan()
+		l.peeked = &t
+	}
+	return *l.peeked
+}
+
+func (l *shexLexer) next() shexToken {
+	if l.peeked != nil {
+		t := *l.peeked
+		l.peeked = nil
+		l.last = t
+		return t
+	}
+	l.last = l.scan()
+	return l.last
+}
+
+// shexPunctuation holds the punctuation characters of ShExC.
+const shexPunctuation = "{}[]();|?*+@,"
+
+func (l *shexLexer) scan() shexToken {
+	pos, lines := skipSpace(l.src, l.pos)
+	l.pos, l.line = pos, l.line+lines
+	if l.pos >= len(l.src) {
+		return shexToken{kind: shexEOF, line: l.line}
+	}
+	line := l.line
+	c := l.src[l.pos]
+	switch {
+	case strings.ContainsRune(shexPunctuation, c):
+		l.pos++
+		return shexToken{shexPunct, string(c), line}
+	case c == '.' && (l.pos+1 >= len(l.src) || !isShExNameRune(l.src[l.pos+1])):
+		l.pos++
+		return shexToken{shexPunct, ".", line}
+	case c == '<' || c == '"' || c == '\'':
+		var text string
+		var err error
+		kind := shexString
+		if c == '<' {
+			text, pos, err = scanIRIRef(l.src, l.pos)
+			kind = shexIRI
+		} else {
+			text, pos, err = scanString(l.src, l.pos)
+		}
+		l.pos = pos
+		if err != nil {
+			return shexToken{shexPunct, string(c), line}
+		}
+		return shexToken{kind, text, line}
+	case c == '/':
+		// Regular expressions keep their escapes intact, other than "\/".
+		var buf strings.Builder
+		i := l.pos + 1
+		for i < len(l.src) && l.src[i] != '/' && l.src[i] != '\n' {
+			if l.src[i] == '\\' && i+1 < len(l.src) {
+				i++
+				if l.src[i] != '/' {
+					buf.WriteRune('\\')
+				}
+			}
+			buf.WriteRune(l.src[i])
+			i++
+		}
+		if i >= len(l.src) || l.src[i] != '/' {
+			l.pos = i
+			return shexToken{shexPunct, "/", line}
+		}
+		l.pos = i + 1
+		return shexToken{shexRegex, buf.String(), line}
+	}
+	start := l.pos
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == '.' && (l.pos+1 >= len(l.src) || !isShExNameRune(l.src[l.pos+1])) {
+			break
+		}
+		if !isShExNameRune(c) && c != '.' {
+			break
+		}
+		l.pos++
+	}
+	if l.pos == start {
+		// An unexpected character.
+		l.pos++
+		return shexToken{shexPunct, string(c), line}
+	}
+	return shexToken{shexIdent, string(l.src[start:l.pos]), line}
+}
+
+// isShExNameRune returns true if the given rune may appear
+// within a prefixed name, keyword or number.
+func isShExNameRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("_-:%", c)
+}
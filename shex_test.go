@@ -0,0 +1,205 @@
+package store4_test
+
+import (
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ShEx", func() {
+
+	const ex = "http://example.org/"
+
+	const schemaText = `
+		PREFIX ex: <http://example.org/>
+		PREFIX xsd: <http://www.w3.org/2001/XMLSchema#>
+
+		# A person.
+		ex:PersonShape {
+			a [ex:Person] ;
+			ex:name xsd:string ;
+			ex:age xsd:integer ? ;
+			ex:email IRI /^mailto:/ * ;
+			ex:knows @ex:PersonShape {0,2}
+		}
+
+		ex:AddressShape CLOSED {
+			ex:city LITERAL ;
+			ex:country [ "FR" "GB" ]
+		}
+	`
+
+	newStore := func() *QuadStore {
+		store := NewQuadStore([][3]string{
+			{ex + "alice", RDFType, ex + "Person"},
+			{ex + "alice", ex + "name", "Alice"},
+			{ex + "alice", ex + "email", "mailto:alice@example.org"},
+			{ex + "alice", ex + "knows", ex + "bob"},
+			{ex + "bob", RDFType, ex + "Person"},
+			{ex + "bob", ex + "name", "Bob"},
+			{ex + "bob", ex + "knows", ex + "alice"},
+		})
+		store.Add(ex+"alice", ex+"age", 30, "")
+		return store
+	}
+
+	It("should parse a schema", func() {
+		schema, err := ParseShExC(schemaText)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schema.Shapes()).To(Equal([]string{ex + "AddressShape", ex + "PersonShape"}))
+	})
+
+	It("should validate conforming nodes, including recursive shape references", func() {
+		schema, _ := ParseShExC(schemaText)
+		store := newStore()
+		shapeMap, err := schema.ParseShapeMap("ex:alice@ex:PersonShape, <http://example.org/bob>@ex:PersonShape")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schema.Validate(store.GraphView(""), shapeMap)).To(Equal([]ShExResult{
+			{Node: ex + "alice", Shape: ex + "PersonShape", Conforms: true},
+			{Node: ex + "bob", Shape: ex + "PersonShape", Conforms: true},
+		}))
+	})
+
+	It("should report failure reasons", func() {
+		schema, _ := ParseShExC(schemaText)
+		store := newStore()
+		v := store.SubjectView(ex+"carol", "")
+		v.Add(RDFType, ex+"Robot")
+		v.Add(ex+"age", 3.5)
+		v.Add(ex+"email", "carol@example.org")
+		v.Add(ex+"knows", ex+"alice")
+		v.Add(ex+"knows", ex+"bob")
+		v.Add(ex+"knows", ex+"dave")
+		r := schema.ValidateSubject(v, ex+"PersonShape")
+		Expect(r.Conforms).To(BeFalse())
+		Expect(r.Reasons).To(Equal([]string{
+			`value 3.5 for <http://example.org/age> is not <http://www.w3.org/2001/XMLSchema#integer>`,
+			`value "carol@example.org" for <http://example.org/email> does not match /^mailto:/`,
+			`value "http://example.org/dave" for <http://example.org/knows> does not conform to @<http://example.org/PersonShape>: ` +
+				`expected exactly 1 <http://example.org/name>, found 0`,
+			`expected exactly 1 <http://example.org/name>, found 0`,
+			`value "http://example.org/Robot" for <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> is not in ["http://example.org/Person"]`,
+		}))
+	})
+
+	It("should check cardinalities", func() {
+		schema, _ := ParseShExC(schemaText)
+		store := newStore()
+		alice := store.SubjectView(ex+"alice", "")
+		alice.Add(ex+"knows", ex+"carol")
+		alice.Add(ex+"knows", ex+"dave")
+		store.Add(ex+"carol", RDFType, ex+"Person", "")
+		store.Add(ex+"carol", ex+"name", "Carol", "")
+		store.Add(ex+"dave", RDFType, ex+"Person", "")
+		store.Add(ex+"dave", ex+"name", "Dave", "")
+		r := schema.ValidateSubject(alice, ex+"PersonShape")
+		Expect(r.Reasons).To(Equal([]string{`expected at most 2 <http://example.org/knows>, found 3`}))
+	})
+
+	It("should check closed shapes, node kinds and value sets", func() {
+		schema, _ := ParseShExC(schemaText)
+		store := NewQuadStore([][3]string{
+			{"a1", ex + "city", "Paris"},
+			{"a1", ex + "country", "FR"},
+			{"a2", ex + "city", "_:b1"},
+			{"a2", ex + "country", "DE"},
+			{"a2", ex + "zip", "12345"},
+		})
+		results := schema.Validate(store.GraphView(""), []ShapeMapEntry{
+			{"a1", ex + "AddressShape"},
+			{"a2", ex + "AddressShape"},
+			{"a1", ex + "MissingShape"},
+		})
+		Expect(results[0].Conforms).To(BeTrue())
+		Expect(results[1].Reasons).To(Equal([]string{
+			`value "_:b1" for <http://example.org/city> is not a LITERAL`,
+			`value "DE" for <http://example.org/country> is not in ["FR" "GB"]`,
+			`unexpected <http://example.org/zip> in closed shape`,
+		}))
+		Expect(results[2].Reasons).To(Equal([]string{"unknown shape <http://example.org/MissingShape>"}))
+	})
+
+	It("should decode escapes in IRIs and strings", func() {
+		schema, err := ParseShExC(`<S> { <\u0070> ["a\tb" 'c\u00E9'] + }`)
+		Expect(err).NotTo(HaveOccurred())
+		store := NewQuadStore([][3]string{
+			{"x", "p", "a\tb"},
+			{"x", "p", "cé"},
+		})
+		results := schema.Validate(store.GraphView(""), []ShapeMapEntry{{"x", "S"}})
+		Expect(results[0].Conforms).To(BeTrue(), "%v", results[0].Reasons)
+	})
+
+	It("should match patterns against the lexical forms of Literals", func() {
+		schema, err := ParseShExC(`<S> { <p> /^[a-z]+$/ }`)
+		Expect(err).NotTo(HaveOccurred())
+		store := NewQuadStore()
+		store.Add("x", "p", Literal{Value: "chat", Language: "fr"}, "")
+		store.Add("y", "p", Literal{Value: "Chat", Language: "fr"}, "")
+		Expect(schema.ValidateSubject(store.SubjectView("x", ""), "S").Conforms).To(BeTrue())
+		Expect(schema.ValidateSubject(store.SubjectView("y", ""), "S").Conforms).To(BeFalse())
+	})
+
+	It("should match several constraints on the same predicate", func() {
+		schema, err := ParseShExC(`
+			<S> {
+				<p> xsd:integer + ;
+				<p> [<a> <b>] {1,} ;
+				<q> . {2} ;
+				<r> NONLITERAL* ;
+				<s> BNODE?
+			}
+			PREFIX xsd: <http://www.w3.org/2001/XMLSchema#>
+		`)
+		Expect(err).To(MatchError(ContainSubstring("undeclared prefix")))
+		schema, err = ParseShExC(`
+			PREFIX xsd: <http://www.w3.org/2001/XMLSchema#>
+			<S> {
+				<p> xsd:integer + ;
+				<p> [<a> <b> 1.5 true] {1,} ;
+				<q> . {2} ;
+			}
+		`)
+		Expect(err).NotTo(HaveOccurred())
+		store := NewQuadStore()
+		store.Add("x", "p", 1, "")
+		store.Add("x", "p", "a", "")
+		store.Add("x", "q", 1, "")
+		store.Add("x", "q", 2, "")
+		Expect(schema.ValidateSubject(store.SubjectView("x", ""), "S").Conforms).To(BeTrue())
+		store.Add("x", "p", "c", "")
+		Expect(schema.ValidateSubject(store.SubjectView("x", ""), "S").Reasons).To(Equal([]string{
+			"values for <p> do not match its 2 constraints",
+		}))
+	})
+
+	It("should report syntax errors", func() {
+		errs := map[string]string{
+			"<S> { <p> }":             "line 1: expected value expression",
+			"<S> { <p> . {3,1} }":     "maximum cardinality is less than minimum",
+			"<S> { <p> . | <q> . }":   "OneOf ('|') is not supported",
+			"<S> { <p> . ; ; }":       "expected IRI, got \";\"",
+			"<S> { <p> @<T> }":        "undefined shape <T>",
+			"<S> { }\n<S> { }":        "line 2: duplicate shape <S>",
+			"<S> { <p> /(/ }":         "invalid regular expression",
+			"<S> { <p> . {x} }":       "expected a non-negative integer",
+			"PREFIX ex <http://ex/>":  "expected prefix name",
+			"PREFIX ex: http":         "expected IRI",
+			"<S> [":                   "expected '{'",
+			"<S> { <p> . ; <q> . ] }": "expected ';' or '}'",
+			"<S> { <p> . {1 ; }":      "expected '}'",
+		}
+		for text, msg := range errs {
+			_, err := ParseShExC(text)
+			Expect(err).To(MatchError(ContainSubstring(msg)), text)
+		}
+		schema, _ := ParseShExC("<S> { }")
+		_, err := schema.ParseShapeMap("<a> <S>")
+		Expect(err).To(MatchError(ContainSubstring("expected '@'")))
+		_, err = schema.ParseShapeMap("<a>@<S> <b>@<S>")
+		Expect(err).To(MatchError(ContainSubstring("expected ','")))
+		_, err = schema.ParseShapeMap("<a>@")
+		Expect(err).To(MatchError(ContainSubstring("expected IRI, got end of input")))
+	})
+})
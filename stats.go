@@ -0,0 +1,247 @@
+package store4
+
+import (
+	"expvar"
+	"unsafe"
+)
+
+// Stats holds memory and cardinality statistics for a QuadStore.
+//
+// Returned by calls to QuadStore.Stats.
+type Stats struct {
+	// Quads is the total count of quads in the store.
+	Quads uint64
+	// Subjects is the count of distinct subject terms, across all graphs.
+	Subjects uint64
+	// Predicates is the count of distinct predicate terms, across all graphs.
+	Predicates uint64
+	// Objects is the count of distinct object terms, across all graphs.
+	Objects uint64
+	// Strings is the count of strings held in the pool.
+	Strings uint64
+	// Items is the count of non-string items held in the pool.
+	Items uint64
+	// PoolBytes is an estimate of the memory used by the pool.
+	PoolBytes uint64
+	// IndexBytes is an estimate of the memory used by all indexes.
+	IndexBytes uint64
+	// Graphs holds per-graph statistics, keyed by graph name.
+	Graphs map[string]*GraphStats
+}
+
+// GraphStats holds memory and cardinality statistics for a single graph.
+//
+// Returned by calls to GraphView.Stats, and as part of Stats.
+type GraphStats struct {
+	// Quads is the count of quads in the graph.
+	Quads uint64
+	// Subjects is the count of distinct subject terms in the graph.
+	Subjects uint64
+	// Predicates is the count of distinct predicate terms in the graph.
+	Predicates uint64
+	// Objects is the count of distinct object terms in the graph.
+	Objects uint64
+	// SPOBytes is an estimate of the memory used by the SPO index.
+	SPOBytes uint64
+	// POSBytes is an estimate of the memory used by the POS index.
+	POSBytes uint64
+	// OSPBytes is an estimate of the memory used by the OSP index.
+	OSPBytes uint64
+	// PredicateStats holds per-predicate statistics, keyed by predicate.
+	PredicateStats map[string]*PredicateStats
+}
+
+// PredicateStats holds cardinality statistics for a single predicate
+// within a graph.
+type PredicateStats struct {
+	// Triples is the count of triples using the predicate.
+	Triples uint64
+	// Subjects is the count of distinct subjects using the predicate.
+	Subjects uint64
+	// Objects is the count of distinct objects used with the predicate.
+	Objects uint64
+}
+
+// The following are rough per-entry costs, used to estimate memory usage.
+// They approximate the runtime's map bucket layout, and are not exact.
+const (
+	// mapHeaderBytes approximates the fixed cost of a map.
+	mapHeaderBytes = 48
+	// mapEntryOverhead approximates the per-entry cost of a map,
+	// excluding the size of its keys and values.
+	mapEntryOverhead = 8
+	// stringHeaderBytes is the size of a string header.
+	stringHeaderBytes = uint64(unsafe.Sizeof(""))
+	// interfaceBytes is the size of an interface value.
+	interfaceBytes = uint64(unsafe.Sizeof(interface{}(nil)))
+	// pointerBytes is the size of a pointer.
+	pointerBytes = uint64(unsafe.Sizeof(uintptr(0)))
+)
+
+// Stats returns memory and cardinality statistics for the store.
+//
+// Note that Stats walks every index, so its cost is proportional
+// to the size of the store.
+func (s *QuadStore) Stats() *Stats {
+	st := &Stats{
+		Quads:   s.size,
+		Strings: uint64(len(s.pool.idToStrInfo)),
+		Items:   uint64(len(s.pool.idToItemInfo)),
+		Graphs:  make(map[string]*GraphStats, len(s.graphs)),
+	}
+	st.PoolBytes = s.pool.estimateBytes()
+	subjects := make(map[uint64]struct{})
+	predicates := make(map[uint64]struct{})
+	objects := make(map[uint64]struct{})
+	for name, g := range s.graphs {
+		gs := s.graphStats(g)
+		st.Graphs[name] = gs
+		st.IndexBytes += gs.SPOBytes + gs.POSBytes + gs.OSPBytes
+		for sid := range g.spoIndex {
+			subjects[sid] = struct{}{}
+		}
+		for pid := range g.posIndex {
+			predicates[pid] = struct{}{}
+		}
+		for oid := range g.ospIndex {
+			objects[oid] = struct{}{}
+		}
+	}
+	st.Subjects = uint64(len(subjects))
+	st.Predicates = uint64(len(predicates))
+	st.Objects = uint64(len(objects))
+	return st
+}
+
+// Stats returns memory and cardinality statistics for the graph.
+//
+// If the graph does not exist, the returned stats are all zero.
+// If the GraphView's Graph is "*" (an asterisk) then the returned stats
+// are aggregated across all graphs, with distinct counts
+// computed across the whole store.
+func (g *GraphView) Stats() *GraphStats {
+	s := g.QuadStore
+	if g.Graph != "*" {
+		ig, ok := s.graphs[g.Graph]
+		if !ok {
+			return &GraphStats{PredicateStats: make(map[string]*PredicateStats)}
+		}
+		return s.graphStats(ig)
+	}
+	st := s.Stats()
+	gs := &GraphStats{
+		Quads:          st.Quads,
+		Subjects:       st.Subjects,
+		Predicates:     st.Predicates,
+		Objects:        st.Objects,
+		PredicateStats: make(map[string]*PredicateStats),
+	}
+	// Merging per-predicate distinct counts requires the underlying sets.
+	subjects := make(map[uint64]map[uint64]struct{})
+	objects := make(map[uint64]map[uint64]struct{})
+	for _, ig := range s.graphs {
+		gs.SPOBytes += estimateIndexBytes(ig.spoIndex)
+		gs.POSBytes += estimateIndexBytes(ig.posIndex)
+		gs.OSPBytes += estimateIndexBytes(ig.ospIndex)
+		for pid, index1 := range ig.posIndex {
+			p := s.pool.idToString(pid)
+			ps, ok := gs.PredicateStats[p]
+			if !ok {
+				ps = &PredicateStats{}
+				gs.PredicateStats[p] = ps
+				subjects[pid] = make(map[uint64]struct{})
+				objects[pid] = make(map[uint64]struct{})
+			}
+			for oid, index2 := range index1 {
+				objects[pid][oid] = struct{}{}
+				ps.Triples += uint64(len(index2))
+				for sid := range index2 {
+					subjects[pid][sid] = struct{}{}
+				}
+			}
+		}
+	}
+	for pid, set := range subjects {
+		ps := gs.PredicateStats[s.pool.idToString(pid)]
+		ps.Subjects = uint64(len(set))
+		ps.Objects = uint64(len(objects[pid]))
+	}
+	return gs
+}
+
+// graphStats computes statistics for a single indexed graph.
+func (s *QuadStore) graphStats(g *indexedGraph) *GraphStats {
+	gs := &GraphStats{
+		Quads:          g.size,
+		Subjects:       uint64(len(g.spoIndex)),
+		Predicates:     uint64(len(g.posIndex)),
+		Objects:        uint64(len(g.ospIndex)),
+		SPOBytes:       estimateIndexBytes(g.spoIndex),
+		POSBytes:       estimateIndexBytes(g.posIndex),
+		OSPBytes:       estimateIndexBytes(g.ospIndex),
+		PredicateStats: make(map[string]*PredicateStats, len(g.posIndex)),
+	}
+	for pid, index1 := range g.posIndex {
+		ps := &PredicateStats{
+			Objects: uint64(len(index1)),
+		}
+		subjects := make(map[uint64]struct{})
+		for _, index2 := range index1 {
+			ps.Triples += uint64(len(index2))
+			for sid := range index2 {
+				subjects[sid] = struct{}{}
+			}
+		}
+		ps.Subjects = uint64(len(subjects))
+		gs.PredicateStats[s.pool.idToString(pid)] = ps
+	}
+	return gs
+}
+
+// estimateIndexBytes returns an estimate of the memory used by an index.
+func estimateIndexBytes(index0 indexRoot) uint64 {
+	// Each layer holds uint64 keys, with the upper layers
+	// holding map values (which are pointers).
+	n := uint64(mapHeaderBytes)
+	for _, index1 := range index0 {
+		n += 8 + pointerBytes + mapEntryOverhead + mapHeaderBytes
+		for _, index2 := range index1 {
+			n += 8 + pointerBytes + mapEntryOverhead + mapHeaderBytes
+			n += uint64(len(index2)) * (8 + mapEntryOverhead)
+		}
+	}
+	return n
+}
+
+// estimateBytes returns an estimate of the memory used by the pool.
+func (s *pool) estimateBytes() uint64 {
+	n := uint64(4 * mapHeaderBytes)
+	for str := range s.strToID {
+		// Both maps hold an entry, and the string data is shared.
+		n += uint64(len(str)) + stringHeaderBytes + 8 + 2*mapEntryOverhead
+	}
+	for range s.idToStrInfo {
+		n += 8 + pointerBytes + uint64(unsafe.Sizeof(strInfo{}))
+	}
+	for range s.itemToID {
+		n += interfaceBytes + 8 + 2*mapEntryOverhead
+	}
+	for range s.idToItemInfo {
+		n += 8 + pointerBytes + uint64(unsafe.Sizeof(itemInfo{}))
+	}
+	return n
+}
+
+// PublishExpvar publishes the store's statistics through package expvar,
+// under the given name. The statistics are recomputed each time
+// the variable is read.
+//
+// As with expvar.Publish, this method will panic if the name is
+// already registered. Note that the store is not concurrency safe
+// while being modified, so reading the variable must be coordinated
+// with any writes to the store.
+func (s *QuadStore) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return s.Stats()
+	}))
+}
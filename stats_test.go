@@ -0,0 +1,122 @@
+package store4_test
+
+import (
+	"encoding/json"
+	"expvar"
+
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stats", func() {
+
+	Describe("An empty QuadStore", func() {
+		store := NewQuadStore()
+		st := store.Stats()
+
+		It("should have zero counts", func() {
+			Expect(st.Quads).To(BeZero())
+			Expect(st.Subjects).To(BeZero())
+			Expect(st.Predicates).To(BeZero())
+			Expect(st.Objects).To(BeZero())
+			Expect(st.Strings).To(BeZero())
+			Expect(st.Items).To(BeZero())
+			Expect(st.IndexBytes).To(BeZero())
+			Expect(st.Graphs).To(BeEmpty())
+		})
+	})
+
+	Describe("A QuadStore with several graphs", func() {
+		store := NewQuadStore([][4]string{
+			{"s1", "p1", "o1", ""},
+			{"s1", "p1", "o2", ""},
+			{"s1", "p2", "o2", ""},
+			{"s2", "p1", "o1", ""},
+			{"s1", "p2", "o3", "g1"},
+		})
+		store.Add("s3", "age", 23, "g1")
+		st := store.Stats()
+
+		It("should count all quads", func() {
+			Expect(st.Quads).To(Equal(uint64(6)))
+		})
+
+		It("should count distinct terms across all graphs", func() {
+			Expect(st.Subjects).To(Equal(uint64(3)))
+			Expect(st.Predicates).To(Equal(uint64(3)))
+			Expect(st.Objects).To(Equal(uint64(4)))
+		})
+
+		It("should count pool entries", func() {
+			// s1 s2 s3 p1 p2 age o1 o2 o3 (graph names are not pooled).
+			Expect(st.Strings).To(Equal(uint64(9)))
+			Expect(st.Items).To(Equal(uint64(1)))
+			Expect(st.PoolBytes).To(BeNumerically(">", 0))
+		})
+
+		It("should have stats for each graph", func() {
+			Expect(st.Graphs).To(HaveLen(2))
+			g := st.Graphs[""]
+			Expect(g.Quads).To(Equal(uint64(4)))
+			Expect(g.Subjects).To(Equal(uint64(2)))
+			Expect(g.Predicates).To(Equal(uint64(2)))
+			Expect(g.Objects).To(Equal(uint64(2)))
+			Expect(g.SPOBytes).To(BeNumerically(">", 0))
+			Expect(g.POSBytes).To(BeNumerically(">", 0))
+			Expect(g.OSPBytes).To(BeNumerically(">", 0))
+		})
+
+		It("should have per-predicate stats", func() {
+			ps := st.Graphs[""].PredicateStats
+			Expect(ps).To(HaveLen(2))
+			Expect(*ps["p1"]).To(Equal(PredicateStats{Triples: 3, Subjects: 2, Objects: 2}))
+			Expect(*ps["p2"]).To(Equal(PredicateStats{Triples: 1, Subjects: 1, Objects: 1}))
+		})
+
+		It("should sum index estimates", func() {
+			var n uint64
+			for _, g := range st.Graphs {
+				n += g.SPOBytes + g.POSBytes + g.OSPBytes
+			}
+			Expect(st.IndexBytes).To(Equal(n))
+		})
+
+		Context("GraphView", func() {
+			It("should return graph-scoped stats", func() {
+				gs := store.GraphView("g1").Stats()
+				Expect(gs.Quads).To(Equal(uint64(2)))
+				Expect(gs.Subjects).To(Equal(uint64(2)))
+				Expect(gs.PredicateStats).To(HaveKey("age"))
+			})
+
+			It("should return zero stats for a missing graph", func() {
+				gs := store.GraphView("missing").Stats()
+				Expect(gs.Quads).To(BeZero())
+				Expect(gs.PredicateStats).To(BeEmpty())
+			})
+
+			It("should aggregate stats for a wildcard graph", func() {
+				gs := store.GraphView("*").Stats()
+				Expect(gs.Quads).To(Equal(uint64(6)))
+				Expect(gs.Subjects).To(Equal(uint64(3)))
+				Expect(*gs.PredicateStats["p2"]).To(Equal(PredicateStats{Triples: 2, Subjects: 1, Objects: 2}))
+			})
+		})
+	})
+
+	Describe("PublishExpvar", func() {
+		store := NewQuadStore([4]string{"s1", "p1", "o1", ""})
+		store.PublishExpvar("store4_stats_test")
+
+		It("should publish the store's stats", func() {
+			v := expvar.Get("store4_stats_test")
+			Expect(v).NotTo(BeNil())
+			var st Stats
+			Expect(json.Unmarshal([]byte(v.String()), &st)).To(Succeed())
+			Expect(st.Quads).To(Equal(uint64(1)))
+		})
+	})
+
+})
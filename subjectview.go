@@ -197,6 +197,17 @@ func (v *SubjectView) Add(predicate string, object interface{}) bool {
 	return v.QuadStore.Add(v.Subject, predicate, object, v.Graph)
 }
 
+// TryAdd adds a quad to the underlying QuadStore,
+// with the given predicate and object values
+// and this SubjectView's Subject and Graph values.
+// Returns true if the quad was a new quad,
+// or false if the quad already existed.
+//
+// Unlike Add, TryAdd does not panic, see QuadStore.TryAdd.
+func (v *SubjectView) TryAdd(predicate string, object interface{}) (bool, error) {
+	return v.QuadStore.TryAdd(v.Subject, predicate, object, v.Graph)
+}
+
 // Count returns a count of tuples in the SubjectView that match the given pattern.
 //
 // Passing "*" (an asterisk) for any parameter acts as a
@@ -310,6 +321,16 @@ func (v *SubjectView) Remove(predicate string, object interface{}) uint64 {
 	return v.QuadStore.Remove(v.Subject, predicate, object, v.Graph)
 }
 
+// TryRemove removes quads from the underlying QuadStore,
+// with the given predicate and object values
+// and this SubjectView's Subject and Graph values.
+// Returns the number of quads removed.
+//
+// Unlike Remove, TryRemove does not panic, see QuadStore.TryRemove.
+func (v *SubjectView) TryRemove(predicate string, object interface{}) (uint64, error) {
+	return v.QuadStore.TryRemove(v.Subject, predicate, object, v.Graph)
+}
+
 // Size returns the total count of tuples in the SubjectView.
 func (v *SubjectView) Size() uint64 {
 	return v.QuadStore.Count(v.Subject, "*", "*", v.Graph)
@@ -0,0 +1,134 @@
+package store4
+
+// ChangeOp identifies the kind of change made to a QuadStore.
+type ChangeOp int
+
+const (
+	// Added indicates that a quad was added to the store.
+	Added ChangeOp = iota + 1
+	// Removed indicates that a quad was removed from the store.
+	Removed
+)
+
+// String returns a human-readable name for the ChangeOp.
+func (op ChangeOp) String() string {
+	switch op {
+	case Added:
+		return "Added"
+	case Removed:
+		return "Removed"
+	}
+	return "Unknown"
+}
+
+// Change describes a single quad that was added to,
+// or removed from, a QuadStore.
+type Change struct {
+	Op        ChangeOp
+	Subject   string
+	Predicate string
+	Object    interface{}
+	Graph     string
+}
+
+// ChangeCallbackFn is the function signature used to implement
+// callback functions that receive a change.
+//
+// Used with calls to QuadStore's Subscribe.
+type ChangeCallbackFn func(c Change)
+
+// subscription holds a registered change handler and its pattern.
+type subscription struct {
+	subject   string
+	predicate string
+	object    interface{}
+	graph     string
+	fn        ChangeCallbackFn
+	// active is cleared on unsubscribe, so that a handler removed
+	// during dispatch is not called again.
+	active bool
+}
+
+// matches returns true if the given change matches the subscription's pattern.
+func (sub *subscription) matches(c *Change) bool {
+	return (sub.subject == "*" || sub.subject == c.Subject) &&
+		(sub.predicate == "*" || sub.predicate == c.Predicate) &&
+		(sub.object == "*" || sub.object == c.Object) &&
+		(sub.graph == "*" || sub.graph == c.Graph)
+}
+
+// Subscribe registers a handler that is called once for every quad
+// added to or removed from the store, that matches the given pattern.
+// Returns a function that cancels the subscription.
+//
+// Passing "*" (an asterisk) for any parameter acts as a
+// match-everything wildcard for that term.
+//
+// Handlers are called after the OnAdd and OnRemove callbacks, in the order in
+// which they were registered, and with the same guarantees: the store's indexes
+// are in a consistent state, so it is safe to add or remove further quads
+// from within a handler. Any number of handlers may be registered.
+func (s *QuadStore) Subscribe(subject, predicate string, object interface{}, graph string, fn ChangeCallbackFn) (unsubscribe func()) {
+	sub := &subscription{
+		subject:   subject,
+		predicate: predicate,
+		object:    object,
+		graph:     graph,
+		fn:        fn,
+		active:    true,
+	}
+	s.subscriptions = append(s.subscriptions, sub)
+	return func() {
+		if !sub.active {
+			return
+		}
+		sub.active = false
+		// Copy on write, so that any dispatch in progress is not disturbed.
+		subs := make([]*subscription, 0, len(s.subscriptions)-1)
+		for _, x := range s.subscriptions {
+			if x != sub {
+				subs = append(subs, x)
+			}
+		}
+		s.subscriptions = subs
+	}
+}
+
+// SubscribeChan registers a buffered channel that receives a Change
+// for every quad added to or removed from the store, that matches the
+// given pattern. Returns the channel, and a function that cancels the
+// subscription and closes the channel.
+//
+// Passing "*" (an asterisk) for any parameter acts as a
+// match-everything wildcard for that term.
+//
+// SubscribeChan is intended for consumers in other goroutines.
+// Changes are sent synchronously while the store is being modified,
+// so once the channel's buffer is full, any further modification of the
+// store will block until the consumer catches up. The returned
+// cancel function must be called from the goroutine that modifies
+// the store.
+func (s *QuadStore) SubscribeChan(subject, predicate string, object interface{}, graph string, size int) (<-chan Change, func()) {
+	ch := make(chan Change, size)
+	unsubscribe := s.Subscribe(subject, predicate, object, graph, func(c Change) {
+		ch <- c
+	})
+	closed := false
+	return ch, func() {
+		if closed {
+			return
+		}
+		closed = true
+		unsubscribe()
+		close(ch)
+	}
+}
+
+// notify calls all subscriptions that match the given change.
+func (s *QuadStore) notify(c Change) {
+	for _, sub := range s.subscriptions {
+		if sub.active && sub.matches(&c) {
+			sub.fn(c)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package store4_test
+
+import (
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Subscribe", func() {
+
+	newStore := func() *QuadStore {
+		return NewQuadStore([]*Quad{
+			{"s1", "p1", "o1", ""},
+			{"s1", "p2", "o2", ""},
+			{"s2", "p1", "o1", "g1"},
+		})
+	}
+
+	It("should call handlers for added and removed quads", func() {
+		store := newStore()
+		var changes []Change
+		store.Subscribe("*", "*", "*", "*", func(c Change) {
+			changes = append(changes, c)
+		})
+		store.Add("s3", "p3", 3, "g2")
+		store.Add("s3", "p3", 3, "g2")
+		store.Remove("s1", "*", "*", "")
+		Expect(changes).To(HaveLen(3))
+		Expect(changes[0]).To(Equal(Change{Added, "s3", "p3", 3, "g2"}))
+		Expect(changes[1:]).To(ConsistOf(
+			Change{Removed, "s1", "p1", "o1", ""},
+			Change{Removed, "s1", "p2", "o2", ""},
+		))
+	})
+
+	It("should only call handlers for matching quads", func() {
+		store := newStore()
+		var changes []Change
+		store.Subscribe("*", "p1", "*", "g1", func(c Change) {
+			changes = append(changes, c)
+		})
+		store.Add("s3", "p1", "o3", "")
+		store.Add("s3", "p2", "o3", "g1")
+		store.Add("s3", "p1", "o3", "g1")
+		store.Remove("*", "*", "*", "*")
+		Expect(changes).To(ConsistOf(
+			Change{Added, "s3", "p1", "o3", "g1"},
+			Change{Removed, "s3", "p1", "o3", "g1"},
+			Change{Removed, "s2", "p1", "o1", "g1"},
+		))
+	})
+
+	It("should match non-string objects", func() {
+		store := newStore()
+		count := 0
+		store.Subscribe("*", "*", 42, "*", func(c Change) {
+			count++
+		})
+		store.Add("s3", "p1", 42, "")
+		store.Add("s3", "p1", "42", "")
+		Expect(count).To(Equal(1))
+	})
+
+	It("should call handlers in registration order, after OnAdd", func() {
+		store := newStore()
+		var calls []string
+		store.OnAdd = func(s, p string, o interface{}, g string) {
+			calls = append(calls, "OnAdd")
+		}
+		store.Subscribe("*", "*", "*", "*", func(c Change) {
+			calls = append(calls, "first")
+		})
+		store.Subscribe("*", "*", "*", "*", func(c Change) {
+			calls = append(calls, "second")
+		})
+		store.Subscribe("*", "*", "*", "*", func(c Change) {
+			calls = append(calls, "third")
+		})
+		store.Add("s3", "p3", "o3", "")
+		Expect(calls).To(Equal([]string{"OnAdd", "first", "second", "third"}))
+	})
+
+	It("should stop calling a handler once unsubscribed", func() {
+		store := newStore()
+		var calls []string
+		unsubscribe := store.Subscribe("*", "*", "*", "*", func(c Change) {
+			calls = append(calls, "first")
+		})
+		store.Subscribe("*", "*", "*", "*", func(c Change) {
+			calls = append(calls, "second")
+		})
+		store.Add("s3", "p3", "o3", "")
+		unsubscribe()
+		unsubscribe()
+		store.Add("s4", "p4", "o4", "")
+		Expect(calls).To(Equal([]string{"first", "second", "second"}))
+	})
+
+	It("should not call a handler unsubscribed during dispatch", func() {
+		store := newStore()
+		var calls []string
+		var unsubscribe func()
+		store.Subscribe("*", "*", "*", "*", func(c Change) {
+			calls = append(calls, "first")
+			unsubscribe()
+		})
+		unsubscribe = store.Subscribe("*", "*", "*", "*", func(c Change) {
+			calls = append(calls, "second")
+		})
+		store.Add("s3", "p3", "o3", "")
+		Expect(calls).To(Equal([]string{"first"}))
+	})
+
+	It("should allow modifying the store from within a handler", func() {
+		store := newStore()
+		store.Subscribe("*", "knows", "*", "*", func(c Change) {
+			if c.Op == Added {
+				store.Add(c.Object.(string), "knows", c.Subject, c.Graph)
+			}
+		})
+		store.Add("Alice", "knows", "Bob", "")
+		Expect(store.Count("Bob", "knows", "Alice", "")).To(Equal(uint64(1)))
+	})
+
+	Describe("SubscribeChan", func() {
+
+		It("should deliver matching changes to the channel", func() {
+			store := newStore()
+			ch, cancel := store.SubscribeChan("*", "*", "*", "g1", 10)
+			store.Add("s3", "p3", "o3", "g1")
+			store.Add("s3", "p3", "o3", "")
+			store.Remove("s2", "*", "*", "*")
+			cancel()
+			var changes []Change
+			for c := range ch {
+				changes = append(changes, c)
+			}
+			Expect(changes).To(Equal([]Change{
+				{Added, "s3", "p3", "o3", "g1"},
+				{Removed, "s2", "p1", "o1", "g1"},
+			}))
+		})
+
+		It("should deliver changes to another goroutine", func() {
+			store := NewQuadStore()
+			ch, cancel := store.SubscribeChan("*", "*", "*", "*", 1)
+			done := make(chan int)
+			go func() {
+				n := 0
+				for range ch {
+					n++
+				}
+				done <- n
+			}()
+			for i := 0; i < 100; i++ {
+				store.Add("s", "p", i, "")
+			}
+			cancel()
+			cancel()
+			Eventually(done).Should(Receive(Equal(100)))
+		})
+	})
+
+	Describe("ChangeOp", func() {
+		It("should have a string representation", func() {
+			Expect(Added.String()).To(Equal("Added"))
+			Expect(Removed.String()).To(Equal("Removed"))
+			Expect(ChangeOp(0).String()).To(Equal("Unknown"))
+		})
+	})
+})
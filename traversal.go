@@ -0,0 +1,476 @@
+package store4
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+)
+
+// Edge is a triple, followed as an edge between two nodes
+// during a graph traversal.
+type Edge struct {
+	Subject   string
+	Predicate string
+	Object    interface{}
+}
+
+// Direction selects which edges a traversal follows from each node.
+type Direction int
+
+const (
+	// Outgoing follows edges from subject to object.
+	Outgoing Direction = iota
+	// Incoming follows edges from object to subject.
+	Incoming
+	// Both follows edges in both directions.
+	Both
+)
+
+// TraversalOptions configure a graph traversal.
+// A nil *TraversalOptions follows all outgoing edges, without limits.
+type TraversalOptions struct {
+	// Predicates restricts the traversal to edges with the given predicates.
+	// If empty, edges with any predicate are followed.
+	Predicates []string
+	// Direction selects which edges are followed from each node.
+	Direction Direction
+	// MaxDepth limits the traversal to nodes within the given count
+	// of edges of the start node. Zero means no limit.
+	MaxDepth int
+	// MaxNodes limits the count of nodes visited, including the start node.
+	// Zero means no limit.
+	MaxNodes int
+}
+
+// TraversalFn is the function signature used to implement callback
+// functions that visit the nodes of a graph traversal.
+//
+// The callback receives the node, its depth (the count of edges from
+// the start node, along the traversal), and the edge by which it was
+// reached, which is nil for the start node. Returning false halts
+// the traversal.
+//
+// Used with calls to BFS and DFS.
+type TraversalFn func(node interface{}, depth int, via *Edge) bool
+
+// WeightFn is the function signature used to implement callback
+// functions that return the weight of an edge, for weighted shortest paths.
+// Returning false excludes the edge from the traversal.
+//
+// Weights are typically taken from numeric objects, such as the
+// object of a triple that annotates the edge's subject.
+//
+// Used with calls to ShortestWeightedPath.
+type WeightFn func(e Edge) (weight float64, ok bool)
+
+// ErrNegativeWeight is returned by ShortestWeightedPath when
+// an edge has a negative (or NaN) weight.
+var ErrNegativeWeight = errors.New("negative edge weight")
+
+// BFS visits the nodes reachable from the given start node
+// in the graph, breadth-first, calling the given function once
+// for each node, starting with the start node itself.
+//
+// Nodes are subjects and objects, so may be of any object type,
+// although only strings can have outgoing edges.
+// The order in which the edges of each node are followed is unspecified.
+func (g *GraphView) BFS(start interface{}, opts *TraversalOptions, fn TraversalFn) {
+	g.QuadStore.BFS(start, g.Graph, opts, fn)
+}
+
+// DFS visits the nodes reachable from the given start node
+// in the graph, depth-first (pre-order), calling the given function
+// once for each node, starting with the start node itself.
+//
+// The order in which the edges of each node are followed is unspecified.
+func (g *GraphView) DFS(start interface{}, opts *TraversalOptions, fn TraversalFn) {
+	g.QuadStore.DFS(start, g.Graph, opts, fn)
+}
+
+// ShortestPath returns a path with the fewest edges from one node to
+// another in the graph, as a list of the edges followed. Returns an empty
+// path if from and to are equal, or nil if there is no path.
+func (g *GraphView) ShortestPath(from, to interface{}, opts *TraversalOptions) []Edge {
+	return g.QuadStore.ShortestPath(from, to, g.Graph, opts)
+}
+
+// ShortestWeightedPath returns a path with the least total weight from
+// one node to another in the graph, as a list of the edges followed,
+// together with its total weight. Returns an empty path if from and to are
+// equal, or a nil path and +Inf if there is no path.
+//
+// ErrNegativeWeight is returned if any edge encountered
+// has a negative weight.
+func (g *GraphView) ShortestWeightedPath(from, to interface{}, opts *TraversalOptions, weight WeightFn) ([]Edge, float64, error) {
+	return g.QuadStore.ShortestWeightedPath(from, to, g.Graph, opts, weight)
+}
+
+// WeightByPredicate returns a WeightFn that weighs each edge by the numeric
+// object of the given predicate on the edge's subject, in the graph.
+// See QuadStore.WeightByPredicate.
+func (g *GraphView) WeightByPredicate(predicate string) WeightFn {
+	return g.QuadStore.WeightByPredicate(predicate, g.Graph)
+}
+
+// WeightByPredicate returns a WeightFn that weighs each edge by the numeric
+// object of the given predicate on the edge's subject, in the given graph.
+// Numeric objects are values of Go's integer and float types, and Literals
+// of the XSD numeric datatypes.
+//
+// Edges are excluded if their subject does not have exactly one
+// numeric object for the predicate.
+//
+// Passing "*" (an asterisk) for the graph reads weights from all graphs.
+func (s *QuadStore) WeightByPredicate(predicate, graph string) WeightFn {
+	return func(e Edge) (float64, bool) {
+		var weight float64
+		n := 0
+		s.ForObjects(e.Subject, predicate, graph, func(o interface{}) {
+			if f, ok := numericValue(o); ok {
+				weight = f
+				n++
+			}
+		})
+		return weight, n == 1
+	}
+}
+
+// BFS visits the nodes reachable from the given start node
+// in the given graph, breadth-first. See GraphView.BFS.
+//
+// Passing "*" (an asterisk) for the graph follows edges in all graphs.
+func (s *QuadStore) BFS(start interface{}, graph string, opts *TraversalOptions, fn TraversalFn) {
+	t := newTraversal(s, graph, opts)
+	startID, ok := s.pool.anyToID(start)
+	if !ok || startID == 0 {
+		fn(start, 0, nil)
+		return
+	}
+	visited := map[uint64]struct{}{startID: {}}
+	if !fn(start, 0, nil) {
+		return
+	}
+	frontier := []uint64{startID}
+	for depth := 1; len(frontier) > 0 && !t.tooDeep(depth); depth++ {
+		var next []uint64
+		halted := false
+		for _, node := range frontier {
+			halted = t.edges(node, func(e [3]uint64, n uint64) bool {
+				if _, ok := visited[n]; ok {
+					return false
+				}
+				if t.full(len(visited)) {
+					return true
+				}
+				visited[n] = struct{}{}
+				next = append(next, n)
+				return !fn(s.pool.idToAny(n), depth, t.edge(e))
+			})
+			if halted {
+				return
+			}
+		}
+		frontier = next
+	}
+}
+
+// DFS visits the nodes reachable from the given start node
+// in the given graph, depth-first. See GraphView.DFS.
+//
+// Passing "*" (an asterisk) for the graph follows edges in all graphs.
+func (s *QuadStore) DFS(start interface{}, graph string, opts *TraversalOptions, fn TraversalFn) {
+	t := newTraversal(s, graph, opts)
+	startID, ok := s.pool.anyToID(start)
+	if !ok || startID == 0 {
+		fn(start, 0, nil)
+		return
+	}
+	visited := make(map[uint64]struct{})
+	var visit func(node uint64, depth int, via *[3]uint64) bool
+	visit = func(node uint64, depth int, via *[3]uint64) bool {
+		if t.full(len(visited)) {
+			return true
+		}
+		visited[node] = struct{}{}
+		var e *Edge
+		if via != nil {
+			e = t.edge(*via)
+		}
+		if !fn(s.pool.idToAny(node), depth, e) {
+			return true
+		}
+		if t.tooDeep(depth + 1) {
+			return false
+		}
+		return t.edges(node, func(e [3]uint64, n uint64) bool {
+			if _, ok := visited[n]; ok {
+				return false
+			}
+			return visit(n, depth+1, &e)
+		})
+	}
+	visit(startID, 0, nil)
+}
+
+// ShortestPath returns a path with the fewest edges from one node to
+// another in the given graph. See GraphView.ShortestPath.
+//
+// Passing "*" (an asterisk) for the graph follows edges in all graphs.
+func (s *QuadStore) ShortestPath(from, to interface{}, graph string, opts *TraversalOptions) []Edge {
+	if from == to {
+		return []Edge{}
+	}
+	t := newTraversal(s, graph, opts)
+	fromID, fok := s.pool.anyToID(from)
+	toID, tok := s.pool.anyToID(to)
+	if !fok || !tok || fromID == 0 || toID == 0 {
+		return nil
+	}
+	parents := map[uint64][3]uint64{fromID: {}}
+	frontier := []uint64{fromID}
+	for depth := 1; len(frontier) > 0 && !t.tooDeep(depth); depth++ {
+		var next []uint64
+		found := false
+		for _, node := range frontier {
+			found = t.edges(node, func(e [3]uint64, n uint64) bool {
+				if _, ok := parents[n]; ok {
+					return false
+				}
+				if t.full(len(parents)) {
+					return false
+				}
+				parents[n] = e
+				next = append(next, n)
+				return n == toID
+			})
+			if found {
+				return t.path(parents, fromID, toID)
+			}
+		}
+		frontier = next
+	}
+	return nil
+}
+
+// ShortestWeightedPath returns a path with the least total weight from
+// one node to another in the given graph. See GraphView.ShortestWeightedPath.
+//
+// Passing "*" (an asterisk) for the graph follows edges in all graphs.
+func (s *QuadStore) ShortestWeightedPath(from, to interface{}, graph string, opts *TraversalOptions, weight WeightFn) ([]Edge, float64, error) {
+	if from == to {
+		return []Edge{}, 0, nil
+	}
+	t := newTraversal(s, graph, opts)
+	fromID, fok := s.pool.anyToID(from)
+	toID, tok := s.pool.anyToID(to)
+	if !fok || !tok || fromID == 0 || toID == 0 {
+		return nil, math.Inf(1), nil
+	}
+	// With a depth limit, the same node may be reached with different
+	// counts of edges, so each (node, depth) pair is a separate state.
+	type state struct {
+		node  uint64
+		depth int
+	}
+	type entry struct {
+		dist   float64
+		parent state
+		edge   [3]uint64
+		done   bool
+	}
+	stateOf := func(node uint64, depth int) state {
+		if t.maxDepth == 0 {
+			depth = 0
+		}
+		return state{node, depth}
+	}
+	start := stateOf(fromID, 0)
+	entries := map[state]*entry{start: {}}
+	settled := make(map[uint64]struct{})
+	q := &weightQueue{}
+	heap.Push(q, weightItem{start.node, start.depth, 0})
+	for q.Len() > 0 {
+		item := heap.Pop(q).(weightItem)
+		cur := stateOf(item.node, item.depth)
+		ent := entries[cur]
+		if ent.done {
+			continue
+		}
+		ent.done = true
+		if cur.node == toID {
+			// Rebuild the path by following parents.
+			var path []Edge
+			for st := cur; st != start; st = entries[st].parent {
+				path = append(path, *t.edge(entries[st].edge))
+			}
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return path, ent.dist, nil
+		}
+		if _, ok := settled[cur.node]; !ok {
+			if t.full(len(settled)) {
+				continue
+			}
+			settled[cur.node] = struct{}{}
+		}
+		if t.tooDeep(item.depth + 1) {
+			continue
+		}
+		var err error
+		t.edges(cur.node, func(e [3]uint64, n uint64) bool {
+			w, ok := weight(*t.edge(e))
+			if !ok {
+				return false
+			}
+			if w < 0 || math.IsNaN(w) {
+				err = ErrNegativeWeight
+				return true
+			}
+			d := ent.dist + w
+			next := stateOf(n, item.depth+1)
+			if nent, ok := entries[next]; ok && (nent.done || nent.dist <= d) {
+				return false
+			}
+			entries[next] = &entry{dist: d, parent: cur, edge: e}
+			heap.Push(q, weightItem{n, item.depth + 1, d})
+			return false
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return nil, math.Inf(1), nil
+}
+
+// traversal holds the state needed to follow edges during a traversal.
+type traversal struct {
+	store  *QuadStore
+	graphs []*indexedGraph
+	// pids holds the IDs of the predicates to follow, or nil for all.
+	pids      map[uint64]struct{}
+	direction Direction
+	maxDepth  int
+	maxNodes  int
+}
+
+// newTraversal returns a traversal of the given graph,
+// or of all graphs if graph is "*" (an asterisk).
+func newTraversal(s *QuadStore, graph string, opts *TraversalOptions) *traversal {
+	t := &traversal{
+		store:  s,
+		graphs: graphOperand(s, graph).graphs,
+	}
+	if opts == nil {
+		return t
+	}
+	t.direction = opts.Direction
+	t.maxDepth = opts.MaxDepth
+	t.maxNodes = opts.MaxNodes
+	if len(opts.Predicates) > 0 {
+		t.pids = make(map[uint64]struct{}, len(opts.Predicates))
+		for _, p := range opts.Predicates {
+			if pid, ok := s.pool.stringToID(p); ok && pid != 0 {
+				t.pids[pid] = struct{}{}
+			}
+		}
+	}
+	return t
+}
+
+// tooDeep returns true if the given depth is beyond the depth limit.
+func (t *traversal) tooDeep(depth int) bool {
+	return t.maxDepth > 0 && depth > t.maxDepth
+}
+
+// full returns true if the given count of visited nodes
+// has reached the node limit.
+func (t *traversal) full(visited int) bool {
+	return t.maxNodes > 0 && visited >= t.maxNodes
+}
+
+// follows returns true if edges with the given predicate are followed.
+func (t *traversal) follows(pid uint64) bool {
+	if t.pids == nil {
+		return true
+	}
+	_, ok := t.pids[pid]
+	return ok
+}
+
+// edges calls the given function for each edge followed from the given node,
+// as the IDs of its triple and the node it leads to, halting and returning
+// true as soon as the function returns true.
+func (t *traversal) edges(node uint64, fn func(e [3]uint64, next uint64) bool) bool {
+	for _, g := range t.graphs {
+		if t.direction != Incoming {
+			for pid, objects := range g.spoIndex[node] {
+				if !t.follows(pid) {
+					continue
+				}
+				for oid := range objects {
+					if fn([3]uint64{node, pid, oid}, oid) {
+						return true
+					}
+				}
+			}
+		}
+		if t.direction != Outgoing {
+			for sid, predicates := range g.ospIndex[node] {
+				for pid := range predicates {
+					if t.follows(pid) && fn([3]uint64{sid, pid, node}, sid) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// edge returns the edge for the triple with the given IDs.
+func (t *traversal) edge(e [3]uint64) *Edge {
+	p := t.store.pool
+	return &Edge{p.idToString(e[0]), p.idToString(e[1]), p.idToAny(e[2])}
+}
+
+// path returns the path to the given node, following
+// the given map of the edges by which each node was reached.
+func (t *traversal) path(parents map[uint64][3]uint64, from, to uint64) []Edge {
+	var path []Edge
+	for n := to; n != from; {
+		e := parents[n]
+		path = append(path, *t.edge(e))
+		// Step back to the other end of the edge.
+		if e[0] == n {
+			n = e[2]
+		} else {
+			n = e[0]
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// weightItem is an entry in a weightQueue.
+type weightItem struct {
+	node  uint64
+	depth int
+	dist  float64
+}
+
+// weightQueue is a priority queue of nodes, ordered by distance.
+type weightQueue []weightItem
+
+func (q weightQueue) Len() int            { return len(q) }
+func (q weightQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q weightQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *weightQueue) Push(x interface{}) { *q = append(*q, x.(weightItem)) }
+func (q *weightQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,237 @@
+package store4_test
+
+import (
+	"math"
+
+	. "github.com/jimsmart/store4"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Traversal", func() {
+
+	var g *GraphView
+
+	BeforeEach(func() {
+		// a -> b -> c -> d, a -> e, e -> d (via "road"), and d -> a (via "back").
+		g = NewGraph([][3]string{
+			{"a", "knows", "b"},
+			{"b", "knows", "c"},
+			{"c", "knows", "d"},
+			{"a", "knows", "e"},
+			{"e", "road", "d"},
+			{"d", "back", "a"},
+		})
+		g.Add("a", "age", 42)
+	})
+
+	type visit struct {
+		Node  interface{}
+		Depth int
+	}
+
+	collect := func(walk func(TraversalFn)) ([]visit, []*Edge) {
+		var visits []visit
+		var edges []*Edge
+		walk(func(node interface{}, depth int, via *Edge) bool {
+			visits = append(visits, visit{node, depth})
+			edges = append(edges, via)
+			return true
+		})
+		return visits, edges
+	}
+
+	Describe("BFS", func() {
+
+		It("should visit nodes in breadth-first order", func() {
+			visits, edges := collect(func(fn TraversalFn) {
+				g.BFS("a", &TraversalOptions{Predicates: []string{"knows"}}, fn)
+			})
+			Expect(visits[0]).To(Equal(visit{"a", 0}))
+			Expect(visits[1:3]).To(ConsistOf(visit{"b", 1}, visit{"e", 1}))
+			Expect(visits[3:]).To(Equal([]visit{{"c", 2}, {"d", 3}}))
+			Expect(edges[0]).To(BeNil())
+			Expect(edges[4]).To(Equal(&Edge{"c", "knows", "d"}))
+		})
+
+		It("should visit non-string objects", func() {
+			visits, _ := collect(func(fn TraversalFn) {
+				g.BFS("a", &TraversalOptions{MaxDepth: 1}, fn)
+			})
+			Expect(visits).To(ConsistOf(visit{"a", 0}, visit{"b", 1}, visit{"e", 1}, visit{42, 1}))
+		})
+
+		It("should follow incoming edges", func() {
+			visits, edges := collect(func(fn TraversalFn) {
+				g.BFS("d", &TraversalOptions{Direction: Incoming, Predicates: []string{"road"}}, fn)
+			})
+			Expect(visits).To(Equal([]visit{{"d", 0}, {"e", 1}}))
+			Expect(edges[1]).To(Equal(&Edge{"e", "road", "d"}))
+		})
+
+		It("should follow edges in both directions", func() {
+			visits, _ := collect(func(fn TraversalFn) {
+				g.BFS("e", &TraversalOptions{Direction: Both, MaxDepth: 1}, fn)
+			})
+			Expect(visits).To(ConsistOf(visit{"e", 0}, visit{"a", 1}, visit{"d", 1}))
+		})
+
+		It("should respect the node limit", func() {
+			visits, _ := collect(func(fn TraversalFn) {
+				g.BFS("a", &TraversalOptions{MaxNodes: 3}, fn)
+			})
+			Expect(visits).To(HaveLen(3))
+		})
+
+		It("should halt when the callback returns false", func() {
+			n := 0
+			g.BFS("a", nil, func(node interface{}, depth int, via *Edge) bool {
+				n++
+				return n < 2
+			})
+			Expect(n).To(Equal(2))
+		})
+
+		It("should visit an unknown start node", func() {
+			visits, _ := collect(func(fn TraversalFn) {
+				g.BFS("z", nil, fn)
+			})
+			Expect(visits).To(Equal([]visit{{"z", 0}}))
+		})
+	})
+
+	Describe("DFS", func() {
+
+		It("should visit nodes in depth-first order", func() {
+			visits, _ := collect(func(fn TraversalFn) {
+				g.DFS("b", nil, fn)
+			})
+			Expect(visits[:4]).To(Equal([]visit{{"b", 0}, {"c", 1}, {"d", 2}, {"a", 3}}))
+			Expect(visits[4:]).To(ConsistOf(visit{"e", 4}, visit{42, 4}))
+		})
+
+		It("should respect the depth limit", func() {
+			visits, _ := collect(func(fn TraversalFn) {
+				g.DFS("b", &TraversalOptions{MaxDepth: 2}, fn)
+			})
+			Expect(visits).To(Equal([]visit{{"b", 0}, {"c", 1}, {"d", 2}}))
+		})
+	})
+
+	Describe("ShortestPath", func() {
+
+		It("should return the path with the fewest edges", func() {
+			Expect(g.ShortestPath("a", "d", nil)).To(Equal([]Edge{
+				{"a", "knows", "e"},
+				{"e", "road", "d"},
+			}))
+			Expect(g.ShortestPath("a", "d", &TraversalOptions{Predicates: []string{"knows"}})).To(Equal([]Edge{
+				{"a", "knows", "b"},
+				{"b", "knows", "c"},
+				{"c", "knows", "d"},
+			}))
+		})
+
+		It("should follow incoming edges", func() {
+			Expect(g.ShortestPath("d", "a", &TraversalOptions{Direction: Incoming})).To(Equal([]Edge{
+				{"e", "road", "d"},
+				{"a", "knows", "e"},
+			}))
+		})
+
+		It("should return nil when there is no path", func() {
+			Expect(g.ShortestPath("a", "d", &TraversalOptions{MaxDepth: 1})).To(BeNil())
+			Expect(g.ShortestPath("a", "z", nil)).To(BeNil())
+			Expect(g.ShortestPath(42, "a", nil)).To(BeNil())
+		})
+
+		It("should return an empty path to itself", func() {
+			Expect(g.ShortestPath("a", "a", nil)).To(BeEmpty())
+		})
+	})
+
+	Describe("ShortestWeightedPath", func() {
+
+		// Edge weights are held as "cost" triples on each edge's subject.
+		var costs *GraphView
+
+		BeforeEach(func() {
+			costs = NewGraph()
+			costs.Add("a", "cost", 1)
+			costs.Add("b", "cost", 1)
+			costs.Add("c", "cost", 1)
+			costs.Add("e", "cost", 5)
+		})
+
+		weight := func(e Edge) (float64, bool) {
+			objects := costs.FindObjects(e.Subject, "cost")
+			if len(objects) != 1 {
+				return 0, false
+			}
+			return float64(objects[0].(int)), true
+		}
+
+		It("should return the path with the least total weight", func() {
+			path, w, err := g.ShortestWeightedPath("a", "d", nil, weight)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(w).To(Equal(3.0))
+			Expect(path).To(Equal([]Edge{
+				{"a", "knows", "b"},
+				{"b", "knows", "c"},
+				{"c", "knows", "d"},
+			}))
+		})
+
+		It("should respect the depth limit", func() {
+			path, w, err := g.ShortestWeightedPath("a", "d", &TraversalOptions{MaxDepth: 2}, weight)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(w).To(Equal(6.0))
+			Expect(path).To(HaveLen(2))
+		})
+
+		It("should report no path", func() {
+			path, w, err := g.ShortestWeightedPath("d", "b", nil, weight)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path).To(BeNil())
+			Expect(math.IsInf(w, 1)).To(BeTrue())
+		})
+
+		It("should take weights from the numeric objects of a predicate", func() {
+			weight := costs.WeightByPredicate("cost")
+			path, w, err := g.ShortestWeightedPath("a", "d", nil, weight)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(w).To(Equal(3.0))
+			Expect(path).To(HaveLen(3))
+			costs.Remove("b", "cost", 1)
+			costs.Add("b", "cost", Literal{Value: "0.5", Datatype: XSDNamespace + "decimal"})
+			costs.Add("c", "cost", "cheap")
+			_, w, _ = g.ShortestWeightedPath("a", "d", nil, weight)
+			Expect(w).To(Equal(2.5))
+			// Edges from subjects with more than one weight are excluded.
+			costs.Add("c", "cost", 2)
+			path, w, _ = g.ShortestWeightedPath("a", "d", nil, weight)
+			Expect(w).To(Equal(6.0))
+			Expect(path).To(Equal([]Edge{
+				{"a", "knows", "e"},
+				{"e", "road", "d"},
+			}))
+		})
+
+		It("should reject negative weights", func() {
+			costs.Add("a", "cost", -1)
+			costs.Remove("a", "cost", 1)
+			_, _, err := g.ShortestWeightedPath("a", "d", nil, weight)
+			Expect(err).To(MatchError(ErrNegativeWeight))
+		})
+	})
+
+	It("should traverse all graphs of a store", func() {
+		store := NewQuadStore([]*Quad{
+			{"a", "p", "b", "g1"},
+			{"b", "p", "c", "g2"},
+		})
+		Expect(store.ShortestPath("a", "c", "*", nil)).To(HaveLen(2))
+		Expect(store.ShortestPath("a", "c", "g1", nil)).To(BeNil())
+	})
+})
@@ -3,6 +3,8 @@ package store4
 import (
 	"fmt"
 	"sort"
+	"strings"
+	"time"
 )
 
 // objectSlice implements sort.Interface for []interface{}
@@ -24,6 +26,92 @@ func sortObjects(slice []interface{}) {
 	sort.Sort(objectSlice(slice))
 }
 
+// toFloat64 converts a numeric value to a float64.
+// Returns false if the value is not numeric.
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// numericValue converts a numeric object value to a float64, as toFloat64,
+// but also converting Literals of the XSD numeric datatypes.
+// Returns false if the value is not numeric.
+func numericValue(v interface{}) (float64, bool) {
+	if l, ok := v.(Literal); ok {
+		if l.Datatype == XSDNamespace+"decimal" && !strings.ContainsAny(l.Value, "eE") {
+			v, _ = parseNumber(l.Value)
+		} else {
+			v = literalValue(l.Value, l.Datatype, l.Language)
+		}
+	}
+	return toFloat64(v)
+}
+
+// compareValues compares two object values, returning -1, 0 or +1.
+// Numbers compare numerically, strings lexically, and times chronologically.
+// Returns false if the values are not comparable with each other.
+func compareValues(a, b interface{}) (int, bool) {
+	if fa, ok := toFloat64(a); ok {
+		fb, ok := toFloat64(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	case time.Time:
+		y, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x.Before(y):
+			return -1, true
+		case x.After(y):
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
 // // quadSlice implements sort.Interface for []*Quad
 // type quadSlice []*Quad
 
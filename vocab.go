@@ -0,0 +1,30 @@
+package store4
+
+// Well-known IRIs from the RDF and RDFS vocabularies.
+const (
+	RDFType           = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+	RDFFirst          = "http://www.w3.org/1999/02/22-rdf-syntax-ns#first"
+	RDFRest           = "http://www.w3.org/1999/02/22-rdf-syntax-ns#rest"
+	RDFNil            = "http://www.w3.org/1999/02/22-rdf-syntax-ns#nil"
+	RDFSSubClassOf    = "http://www.w3.org/2000/01/rdf-schema#subClassOf"
+	RDFSSubPropertyOf = "http://www.w3.org/2000/01/rdf-schema#subPropertyOf"
+	RDFSDomain        = "http://www.w3.org/2000/01/rdf-schema#domain"
+	RDFSRange         = "http://www.w3.org/2000/01/rdf-schema#range"
+)
+
+// Well-known IRIs from the OWL vocabulary.
+const (
+	OWLSameAs                    = "http://www.w3.org/2002/07/owl#sameAs"
+	OWLDifferentFrom             = "http://www.w3.org/2002/07/owl#differentFrom"
+	OWLInverseOf                 = "http://www.w3.org/2002/07/owl#inverseOf"
+	OWLTransitiveProperty        = "http://www.w3.org/2002/07/owl#TransitiveProperty"
+	OWLSymmetricProperty         = "http://www.w3.org/2002/07/owl#SymmetricProperty"
+	OWLFunctionalProperty        = "http://www.w3.org/2002/07/owl#FunctionalProperty"
+	OWLInverseFunctionalProperty = "http://www.w3.org/2002/07/owl#InverseFunctionalProperty"
+)
+
+// Namespaces of other well-known vocabularies.
+const (
+	SHACLNamespace = "http://www.w3.org/ns/shacl#"
+	XSDNamespace   = "http://www.w3.org/2001/XMLSchema#"
+)